@@ -6,18 +6,103 @@ package main
 
 import (
 	"log"
+	"os"
+	"strconv"
 
-	"github.com/hyperledger/fabric-contract-api-go/v2/contractapi"
+	"github.com/hyperledger/fabric-chaincode-go/v2/shim"
 	"github.com/hyperledger/fabric-samples/asset-transfer-basic/chaincode-go/chaincode"
 )
 
+type serverConfig struct {
+	CCID    string
+	Address string
+}
+
 func main() {
-	assetChaincode, err := contractapi.NewChaincode(&chaincode.SmartContract{})
+	assetChaincode, err := chaincode.NewChaincode()
 	if err != nil {
 		log.Panicf("Error creating asset-transfer-basic chaincode: %v", err)
 	}
 
-	if err := assetChaincode.Start(); err != nil {
-		log.Panicf("Error starting asset-transfer-basic chaincode: %v", err)
+	// The chaincode runs as an external service when a server address is
+	// given, and is otherwise launched by the peer.
+	address := os.Getenv("CHAINCODE_SERVER_ADDRESS")
+	if address == "" {
+		if err := assetChaincode.Start(); err != nil {
+			log.Panicf("Error starting asset-transfer-basic chaincode: %v", err)
+		}
+		return
+	}
+
+	config := serverConfig{
+		CCID:    os.Getenv("CHAINCODE_ID"),
+		Address: address,
+	}
+	if config.CCID == "" {
+		config.CCID = os.Getenv("CORE_CHAINCODE_ID_NAME")
+	}
+
+	server := &shim.ChaincodeServer{
+		CCID:     config.CCID,
+		Address:  config.Address,
+		CC:       assetChaincode,
+		TLSProps: getTLSProperties(),
+	}
+
+	if err := server.Start(); err != nil {
+		log.Panicf("Error starting asset-transfer-basic chaincode server: %v", err)
+	}
+}
+
+// getTLSProperties reads the chaincode server TLS material. TLS is disabled
+// unless CHAINCODE_TLS_DISABLED is set to false.
+func getTLSProperties() shim.TLSProperties {
+	tlsDisabledStr := getEnvOrDefault("CHAINCODE_TLS_DISABLED", "true")
+	tlsDisabled := getBoolOrDefault(tlsDisabledStr, true)
+	if tlsDisabled {
+		return shim.TLSProperties{Disabled: true}
+	}
+
+	key := readFileOrPanic(os.Getenv("CHAINCODE_TLS_KEY"))
+	cert := readFileOrPanic(os.Getenv("CHAINCODE_TLS_CERT"))
+
+	// The peer's client certificate is only verified when a CA is given.
+	var clientCACerts []byte
+	if clientCACertPath := os.Getenv("CHAINCODE_CLIENT_CA_CERT"); clientCACertPath != "" {
+		clientCACerts = readFileOrPanic(clientCACertPath)
+	}
+
+	return shim.TLSProperties{
+		Disabled:      false,
+		Key:           key,
+		Cert:          cert,
+		ClientCACerts: clientCACerts,
+	}
+}
+
+func getEnvOrDefault(env, defaultVal string) string {
+	value, ok := os.LookupEnv(env)
+	if !ok {
+		value = defaultVal
+	}
+	return value
+}
+
+func getBoolOrDefault(value string, defaultVal bool) bool {
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		return defaultVal
+	}
+	return parsed
+}
+
+func readFileOrPanic(path string) []byte {
+	if path == "" {
+		log.Panicf("TLS is enabled but a required key or certificate path is not set")
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		log.Panicf("Error reading %s: %v", path, err)
 	}
+	return data
 }
@@ -0,0 +1,68 @@
+package chaincode
+
+import (
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/v2/contractapi"
+)
+
+const (
+	adminRole     = "admin"
+	observerRole  = "observer"
+	candidateRole = "candidate"
+)
+
+// assertAdmin checks that the submitting client is an administrator, either
+// through the admin node OU in its certificate or a role=admin attribute
+// issued by the Fabric CA.
+func assertAdmin(ctx contractapi.TransactionContextInterface) error {
+	cert, err := ctx.GetClientIdentity().GetX509Certificate()
+	if err != nil {
+		return fmt.Errorf("failed to read client certificate: %v", err)
+	}
+	if cert != nil {
+		for _, ou := range cert.Subject.OrganizationalUnit {
+			if ou == adminRole {
+				return nil
+			}
+		}
+	}
+
+	role, found, err := ctx.GetClientIdentity().GetAttributeValue("role")
+	if err != nil {
+		return fmt.Errorf("failed to read client role: %v", err)
+	}
+	if found && role == adminRole {
+		return nil
+	}
+
+	return fmt.Errorf("client is not authorized to perform this operation")
+}
+
+// assertRole checks that the submitting client carries one of roles in the
+// role attribute of its certificate.
+func assertRole(ctx contractapi.TransactionContextInterface, roles ...string) error {
+	role, found, err := ctx.GetClientIdentity().GetAttributeValue("role")
+	if err != nil {
+		return fmt.Errorf("failed to read client role: %v", err)
+	}
+	if found && containsString(roles, role) {
+		return nil
+	}
+
+	return fmt.Errorf("client must have one of the roles %v", roles)
+}
+
+// clientActor identifies the submitting client for audit records.
+func clientActor(ctx contractapi.TransactionContextInterface) (string, error) {
+	mspID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return "", fmt.Errorf("failed to read client MSP ID: %v", err)
+	}
+	id, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return "", fmt.Errorf("failed to read client ID: %v", err)
+	}
+
+	return mspID + "/" + id, nil
+}
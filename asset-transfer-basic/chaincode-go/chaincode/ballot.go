@@ -0,0 +1,434 @@
+package chaincode
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/v2/contractapi"
+)
+
+const ballotDefinitionDocType = "ballotDefinition"
+
+// Voting methods a ballot definition can declare.
+const (
+	VotingMethodPlurality = "plurality"
+	VotingMethodApproval  = "approval"
+	VotingMethodRanked    = "ranked"
+)
+
+// BallotContest is a single race on the ballot, such as mayor, council or a
+// referendum question. CandidateIDs are listed in the order they are
+// presented to voters, and each candidate runs in at most one contest.
+type BallotContest struct {
+	ID            string   `json:"id"`
+	Title         string   `json:"title"`
+	CandidateIDs  []string `json:"candidateIDs"`
+	MinSelections int      `json:"minSelections"`
+	MaxSelections int      `json:"maxSelections"`
+	// ConstituencyID scopes the contest to the voters registered in that
+	// constituency. It is left out of the encoding when empty, so the hash
+	// of a ballot without scoped contests does not depend on it.
+	ConstituencyID string `json:"constituencyID,omitempty" metadata:",optional"`
+}
+
+// BallotDocument is the canonical content of a ballot definition. Its hash is
+// computed over its JSON encoding, whose field order is fixed by this type.
+// ElectionID and Version are assigned by the contract, so callers can leave
+// them out.
+type BallotDocument struct {
+	ElectionID   string           `json:"electionID" metadata:",optional"`
+	Version      int              `json:"version" metadata:",optional"`
+	VotingMethod string           `json:"votingMethod"`
+	Contests     []*BallotContest `json:"contests"`
+}
+
+// BallotDefinition is a version of an election's ballot. The version in
+// force when the election opens is locked, and every vote records its hash.
+type BallotDefinition struct {
+	DocType       string         `json:"docType"`
+	SchemaVersion int            `json:"schemaVersion"`
+	Document      BallotDocument `json:"document"`
+	Hash          string         `json:"hash"`
+	Locked        bool           `json:"locked"`
+	SubmittedBy   string         `json:"submittedBy"`
+	CreatedAt     time.Time      `json:"createdAt"`
+}
+
+// SetBallotDefinition stores a new version of the election's ballot. The
+// election and version in document are assigned by the contract. Definitions
+// can only be changed before the election opens.
+func (c *ElectionContract) SetBallotDefinition(ctx *ElectionContext, electionID string, document BallotDocument) (*BallotDefinition, error) {
+	err := ctx.AssertAdmin()
+	if err != nil {
+		return nil, err
+	}
+
+	election, err := ctx.ReadElection(electionID)
+	if err != nil {
+		return nil, err
+	}
+	err = assertNotPaused(election)
+	if err != nil {
+		return nil, err
+	}
+	if election.Status != ElectionStatusCreated {
+		return nil, fmt.Errorf("election %s is %s, the ballot can only be defined before it opens", electionID, election.Status)
+	}
+
+	latest, err := getLatestBallotDefinition(ctx, electionID)
+	if err != nil {
+		return nil, err
+	}
+
+	return putNextBallotDefinition(ctx, electionID, document, latest)
+}
+
+// putNextBallotDefinition validates document and stores it as the version of
+// the election's ballot following latest.
+func putNextBallotDefinition(ctx contractapi.TransactionContextInterface, electionID string, document BallotDocument, latest *BallotDefinition) (*BallotDefinition, error) {
+	document.ElectionID = electionID
+	document.Version = 1
+	if latest != nil {
+		document.Version = latest.Document.Version + 1
+	}
+
+	err := validateBallotDocument(ctx, &document)
+	if err != nil {
+		return nil, err
+	}
+
+	hash, err := ballotDocumentHash(&document)
+	if err != nil {
+		return nil, err
+	}
+	timestamp, err := txTimestamp(ctx)
+	if err != nil {
+		return nil, err
+	}
+	actor, err := clientActor(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	definition := &BallotDefinition{
+		DocType:       ballotDefinitionDocType,
+		SchemaVersion: currentSchemaVersion,
+		Document:      document,
+		Hash:          hash,
+		SubmittedBy:   actor,
+		CreatedAt:     timestamp,
+	}
+
+	err = putBallotDefinition(ctx, definition)
+	if err != nil {
+		return nil, err
+	}
+
+	return definition, nil
+}
+
+// ReadBallotDefinition returns the election's current ballot definition: the
+// locked version once the election has opened, the latest one before.
+func (c *ElectionContract) ReadBallotDefinition(ctx *ElectionContext, electionID string) (*BallotDefinition, error) {
+	definition, err := getLatestBallotDefinition(ctx, electionID)
+	if err != nil {
+		return nil, err
+	}
+	if definition == nil {
+		return nil, fmt.Errorf("election %s has no ballot definition", electionID)
+	}
+
+	return definition, nil
+}
+
+// ReadBallotDefinitionVersion returns a specific version of the election's
+// ballot definition.
+func (c *ElectionContract) ReadBallotDefinitionVersion(ctx *ElectionContext, electionID string, version int) (*BallotDefinition, error) {
+	definition, err := getBallotDefinition(ctx, electionID, version)
+	if err != nil {
+		return nil, err
+	}
+	if definition == nil {
+		return nil, fmt.Errorf("version %d of the ballot of election %s does not exist", version, electionID)
+	}
+
+	return definition, nil
+}
+
+func validateBallotDocument(ctx contractapi.TransactionContextInterface, document *BallotDocument) error {
+	switch document.VotingMethod {
+	case VotingMethodPlurality, VotingMethodApproval, VotingMethodRanked:
+	default:
+		return fmt.Errorf("unknown voting method %q", document.VotingMethod)
+	}
+	if len(document.Contests) == 0 {
+		return fmt.Errorf("the ballot must have at least one contest")
+	}
+
+	contestIDs := make(map[string]bool)
+	contested := make(map[string]string)
+	for _, contest := range document.Contests {
+		if contest == nil {
+			return fmt.Errorf("contests cannot contain null entries")
+		}
+		if len(contest.ID) == 0 {
+			return fmt.Errorf("contest ID cannot be empty")
+		}
+		if contestIDs[contest.ID] {
+			return fmt.Errorf("contest %s appears more than once", contest.ID)
+		}
+		contestIDs[contest.ID] = true
+
+		if len(contest.CandidateIDs) == 0 {
+			return fmt.Errorf("contest %s has no candidates", contest.ID)
+		}
+		if contest.MaxSelections < 1 || contest.MaxSelections > len(contest.CandidateIDs) {
+			return fmt.Errorf("contest %s must allow between 1 and %d selections", contest.ID, len(contest.CandidateIDs))
+		}
+		if contest.MinSelections < 0 || contest.MinSelections > contest.MaxSelections {
+			return fmt.Errorf("contest %s requires between 0 and %d selections", contest.ID, contest.MaxSelections)
+		}
+		if document.VotingMethod == VotingMethodPlurality && contest.MaxSelections != 1 {
+			return fmt.Errorf("plurality contest %s must allow exactly one selection", contest.ID)
+		}
+		if contest.ConstituencyID != "" {
+			constituency, err := getConstituency(ctx, contest.ConstituencyID)
+			if err != nil {
+				return err
+			}
+			if constituency == nil {
+				return fmt.Errorf("constituency %s does not exist", contest.ConstituencyID)
+			}
+		}
+
+		for _, candidateID := range contest.CandidateIDs {
+			if other, ok := contested[candidateID]; ok {
+				if other == contest.ID {
+					return fmt.Errorf("candidate %s appears more than once in contest %s", candidateID, contest.ID)
+				}
+				return fmt.Errorf("candidate %s already runs in contest %s", candidateID, other)
+			}
+			contested[candidateID] = contest.ID
+
+			candidate, err := activeCandidate(ctx, candidateID)
+			if err != nil {
+				return err
+			}
+			if candidate.ElectionID != document.ElectionID {
+				return fmt.Errorf("candidate %s does not run in election %s", candidateID, document.ElectionID)
+			}
+		}
+	}
+
+	return nil
+}
+
+// lockBallotDefinition freezes the latest ballot definition when the election
+// opens and records its hash on the election. Elections without a definition
+// open as before.
+func lockBallotDefinition(ctx contractapi.TransactionContextInterface, election *Election) error {
+	definition, err := getLatestBallotDefinition(ctx, election.ID)
+	if err != nil {
+		return err
+	}
+	if definition == nil {
+		return nil
+	}
+
+	definition.Locked = true
+	election.BallotDefinitionHash = definition.Hash
+
+	return putBallotDefinition(ctx, definition)
+}
+
+// assertBallotDefinition checks vote against the election's locked ballot
+// definition and returns the definition hash to record on the vote. A ballot
+// with selections must fill every contest on the voter's ballot within the
+// contest's minimum and maximum number of selections. A vote for a single
+// candidate is only accepted when that candidate's contest is the only one on
+// the voter's ballot. Ranked ballots are flagged so that only their first
+// preferences are counted for the candidates. An empty definitionHash accepts
+// the locked definition. voter is nil for anonymous ballots, whose district
+// is not known.
+func assertBallotDefinition(ctx contractapi.TransactionContextInterface, election *Election, voter *Voter, vote *Vote, definitionHash string) (string, error) {
+	if election == nil || election.BallotDefinitionHash == "" {
+		if definitionHash != "" {
+			return "", fmt.Errorf("election has no locked ballot definition")
+		}
+		return "", nil
+	}
+	if definitionHash != "" && definitionHash != election.BallotDefinitionHash {
+		return "", fmt.Errorf("ballot was cast against definition %s, but election %s uses %s", definitionHash, election.ID, election.BallotDefinitionHash)
+	}
+
+	definition, err := getLatestBallotDefinition(ctx, election.ID)
+	if err != nil {
+		return "", err
+	}
+	contests, err := voterContests(ctx, definition.Document.Contests, voter)
+	if err != nil {
+		return "", err
+	}
+
+	if len(vote.Selections) == 0 {
+		// Write-ins are not on the ballot.
+		if vote.CandidateID == "" {
+			return election.BallotDefinitionHash, nil
+		}
+		contest := candidateContest(definition.Document.Contests, vote.CandidateID)
+		if contest == nil {
+			return "", fmt.Errorf("candidate %s is not on the ballot of election %s", vote.CandidateID, election.ID)
+		}
+		if !containsContest(contests, contest.ID) {
+			return "", fmt.Errorf("contest %s is outside the district of voter %s", contest.ID, voter.ID)
+		}
+		if len(contests) > 1 || contest.MinSelections > 1 {
+			return "", fmt.Errorf("the ballot of election %s has more to fill in, it must be cast with CastContestBallot", election.ID)
+		}
+		return election.BallotDefinitionHash, nil
+	}
+
+	bySelection := make(map[string]*ContestSelection)
+	for _, selection := range vote.Selections {
+		if !containsContest(contests, selection.ContestID) {
+			return "", fmt.Errorf("contest %s is not on the voter's ballot of election %s", selection.ContestID, election.ID)
+		}
+		bySelection[selection.ContestID] = selection
+	}
+	for _, contest := range contests {
+		selection, ok := bySelection[contest.ID]
+		if !ok {
+			return "", fmt.Errorf("the ballot has no selection for contest %s", contest.ID)
+		}
+		err = validateContestSelection(ctx, contest, selection)
+		if err != nil {
+			return "", err
+		}
+	}
+	vote.Ranked = definition.Document.VotingMethod == VotingMethodRanked
+
+	return election.BallotDefinitionHash, nil
+}
+
+// voterContests returns the contests on the ballot of voter: those not
+// scoped to a constituency and those of the voter's constituency. Anonymous
+// ballots may fill in any contest.
+func voterContests(ctx contractapi.TransactionContextInterface, contests []*BallotContest, voter *Voter) ([]*BallotContest, error) {
+	if voter == nil {
+		return contests, nil
+	}
+
+	constituencyID := ""
+	for _, contest := range contests {
+		if contest.ConstituencyID != "" {
+			var err error
+			constituencyID, err = voterConstituency(ctx, voter)
+			if err != nil {
+				return nil, err
+			}
+			break
+		}
+	}
+
+	var scoped []*BallotContest
+	for _, contest := range contests {
+		if inDistrict(contest, constituencyID) {
+			scoped = append(scoped, contest)
+		}
+	}
+
+	return scoped, nil
+}
+
+// candidateContest returns the contest candidateID runs in, or nil.
+func candidateContest(contests []*BallotContest, candidateID string) *BallotContest {
+	for _, contest := range contests {
+		if containsString(contest.CandidateIDs, candidateID) {
+			return contest
+		}
+	}
+
+	return nil
+}
+
+func ballotDocumentHash(document *BallotDocument) (string, error) {
+	documentJSON, err := json.Marshal(document)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal ballot document: %v", err)
+	}
+
+	hash := sha256.Sum256(documentJSON)
+	return hex.EncodeToString(hash[:]), nil
+}
+
+func ballotDefinitionKey(ctx contractapi.TransactionContextInterface, electionID string, version int) (string, error) {
+	// Versions are zero padded so that keys sort in version order.
+	key, err := ctx.GetStub().CreateCompositeKey(ballotDefinitionDocType, []string{electionID, fmt.Sprintf("%06d", version)})
+	if err != nil {
+		return "", fmt.Errorf("failed to create ballot definition key: %v", err)
+	}
+
+	return key, nil
+}
+
+func getBallotDefinition(ctx contractapi.TransactionContextInterface, electionID string, version int) (*BallotDefinition, error) {
+	key, err := ballotDefinitionKey(ctx, electionID, version)
+	if err != nil {
+		return nil, err
+	}
+
+	definitionJSON, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read ballot definition: %v", err)
+	}
+	if definitionJSON == nil {
+		return nil, nil
+	}
+
+	var definition BallotDefinition
+	err = unmarshalAsset(definitionJSON, &definition)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal ballot definition: %v", err)
+	}
+
+	return &definition, nil
+}
+
+func getLatestBallotDefinition(ctx contractapi.TransactionContextInterface, electionID string) (*BallotDefinition, error) {
+	values, err := getPartialCompositeKeyValues(ctx, ballotDefinitionDocType, []string{electionID})
+	if err != nil {
+		return nil, err
+	}
+	if len(values) == 0 {
+		return nil, nil
+	}
+
+	var definition BallotDefinition
+	err = unmarshalAsset(values[len(values)-1], &definition)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal ballot definition: %v", err)
+	}
+
+	return &definition, nil
+}
+
+func putBallotDefinition(ctx contractapi.TransactionContextInterface, definition *BallotDefinition) error {
+	key, err := ballotDefinitionKey(ctx, definition.Document.ElectionID, definition.Document.Version)
+	if err != nil {
+		return err
+	}
+
+	definitionJSON, err := json.Marshal(definition)
+	if err != nil {
+		return fmt.Errorf("failed to marshal ballot definition: %v", err)
+	}
+	err = ctx.GetStub().PutState(key, definitionJSON)
+	if err != nil {
+		return fmt.Errorf("failed to put ballot definition: %v", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,41 @@
+package chaincode
+
+import (
+	"github.com/hyperledger/fabric-contract-api-go/v2/contractapi"
+)
+
+const ballotContractName = "ballot"
+
+// BallotContext is the transaction context of the ballot contract.
+type BallotContext struct {
+	contractContext
+}
+
+// BallotContract holds the transactions voters cast and delegate ballots with.
+type BallotContract struct {
+	contractapi.Contract
+}
+
+func (c *BallotContract) GetName() string {
+	return ballotContractName
+}
+
+func (c *BallotContract) GetTransactionContextHandler() contractapi.SettableTransactionContextInterface {
+	return new(BallotContext)
+}
+
+func (c *BallotContract) GetBeforeTransaction() interface{} {
+	return c.beforeTransaction
+}
+
+func (c *BallotContract) GetUnknownTransaction() interface{} {
+	return c.unknownTransaction
+}
+
+func (c *BallotContract) beforeTransaction(ctx *BallotContext) error {
+	return ctx.RejectWhenPaused()
+}
+
+func (c *BallotContract) unknownTransaction(ctx *BallotContext) error {
+	return ctx.unknownTransaction(ballotContractName)
+}
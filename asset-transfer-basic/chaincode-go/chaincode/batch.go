@@ -0,0 +1,105 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/v2/contractapi"
+)
+
+// maxVoterBatchSize bounds the number of voters registered by a single
+// transaction, keeping the write set well below the peer's size limits.
+const maxVoterBatchSize = 500
+
+// VoterRecord is a single entry of a voter registration batch.
+type VoterRecord struct {
+	ID         string `json:"id"`
+	Name       string `json:"name"`
+	PrecinctID string `json:"precinctID,omitempty" metadata:",optional"`
+}
+
+// BatchResult reports the outcome of registering one record of a batch.
+type BatchResult struct {
+	ID      string `json:"id"`
+	Success bool   `json:"success"`
+	Error   string `json:"error,omitempty" metadata:",optional"`
+}
+
+// RegisterVotersBatch registers a chunk of voters in a single transaction,
+// which only an admin can submit.
+// Records that fail validation are reported in the results and do not stop
+// the rest of the batch from being registered.
+func (c *RegistryContract) RegisterVotersBatch(ctx *RegistryContext, voters []*VoterRecord) ([]*BatchResult, error) {
+	err := ctx.AssertAdmin()
+	if err != nil {
+		return nil, err
+	}
+	if len(voters) == 0 {
+		return nil, fmt.Errorf("batch cannot be empty")
+	}
+	if len(voters) > maxVoterBatchSize {
+		return nil, fmt.Errorf("batch of %d voters exceeds the limit of %d", len(voters), maxVoterBatchSize)
+	}
+
+	results := make([]*BatchResult, 0, len(voters))
+	seen := make(map[string]bool, len(voters))
+	for _, record := range voters {
+		result := &BatchResult{ID: record.ID}
+		results = append(results, result)
+
+		err := registerVoterRecord(ctx, record, seen)
+		if err != nil {
+			result.Error = err.Error()
+			continue
+		}
+		result.Success = true
+	}
+
+	return results, nil
+}
+
+func registerVoterRecord(ctx contractapi.TransactionContextInterface, record *VoterRecord, seen map[string]bool) error {
+	if len(record.ID) == 0 {
+		return fmt.Errorf("voter ID cannot be empty")
+	}
+	if len(record.Name) == 0 {
+		return fmt.Errorf("voter name cannot be empty")
+	}
+	if seen[record.ID] {
+		return fmt.Errorf("voter %s appears more than once in the batch", record.ID)
+	}
+	seen[record.ID] = true
+
+	err := assertPrecinctExists(ctx, record.PrecinctID)
+	if err != nil {
+		return err
+	}
+
+	existing, err := ctx.GetStub().GetState(record.ID)
+	if err != nil {
+		return fmt.Errorf("failed to read voter: %v", err)
+	}
+	if existing != nil {
+		return fmt.Errorf("voter %s already exists", record.ID)
+	}
+
+	voterJSON, err := json.Marshal(Voter{
+		DocType:       voterDocType,
+		SchemaVersion: currentSchemaVersion,
+		ID:            record.ID,
+		Name:          record.Name,
+		Status:        VoterStatusRegistered,
+		HasVoted:      false,
+		PrecinctID:    record.PrecinctID,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal voter: %v", err)
+	}
+
+	err = ctx.GetStub().PutState(record.ID, voterJSON)
+	if err != nil {
+		return fmt.Errorf("failed to put voter: %v", err)
+	}
+
+	return putVoterIndex(ctx, record.ID)
+}
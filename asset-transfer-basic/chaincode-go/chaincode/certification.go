@@ -0,0 +1,372 @@
+package chaincode
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/v2/contractapi"
+)
+
+const attestationDocType = "attestation"
+
+// CandidateTally is the number of votes counted for a single candidate.
+// VoidedVotes holds the votes of a withdrawn candidate that were not counted.
+type CandidateTally struct {
+	CandidateID string `json:"candidateID"`
+	Votes       int    `json:"votes"`
+	Withdrawn   bool   `json:"withdrawn,omitempty" metadata:",optional"`
+	VoidedVotes int    `json:"voidedVotes,omitempty" metadata:",optional"`
+}
+
+// Attestation records the tally hash one organization computed for a closed
+// election.
+type Attestation struct {
+	DocType       string    `json:"docType"`
+	SchemaVersion int       `json:"schemaVersion"`
+	ElectionID    string    `json:"electionID"`
+	MSPID         string    `json:"mspID"`
+	TallyHash     string    `json:"tallyHash"`
+	TxID          string    `json:"txId"`
+	Timestamp     time.Time `json:"timestamp"`
+}
+
+// ElectionResult is the certified outcome of an election.
+type ElectionResult struct {
+	DocType       string            `json:"docType"`
+	SchemaVersion int               `json:"schemaVersion"`
+	ElectionID    string            `json:"electionID"`
+	TallyHash     string            `json:"tallyHash"`
+	Tally         []*CandidateTally `json:"tally,omitempty" metadata:",optional"`
+	Organizations []string          `json:"organizations,omitempty" metadata:",optional"`
+	CertifiedAt   time.Time         `json:"certifiedAt"`
+}
+
+// GetTally returns the vote count of every candidate in the election, ordered
+// by candidate ID, followed by the write-in votes still awaiting adjudication.
+// Withdrawn candidates are flagged and tallied according to the election's
+// WithdrawnVotes rule; reported separately means they are listed last.
+func (c *ElectionContract) GetTally(ctx *ElectionContext, electionID string) ([]*CandidateTally, error) {
+	return getTally(ctx, electionID)
+}
+
+func getTally(ctx contractapi.TransactionContextInterface, electionID string) ([]*CandidateTally, error) {
+	if len(electionID) == 0 {
+		return nil, fmt.Errorf("electionID cannot be empty")
+	}
+
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(candidateElectionIndex, []string{electionID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read candidate index: %v", err)
+	}
+	defer resultsIterator.Close()
+
+	candidates, err := candidatesFromIndex(ctx, resultsIterator)
+	if err != nil {
+		return nil, err
+	}
+
+	election, err := getElection(ctx, electionID)
+	if err != nil {
+		return nil, err
+	}
+	policy := withdrawnVotesPolicy(election)
+
+	tally := make([]*CandidateTally, 0, len(candidates))
+	var separate []*CandidateTally
+	for _, candidate := range candidates {
+		entry := &CandidateTally{CandidateID: candidate.ID, Votes: candidate.Votes}
+		if applyWithdrawnVotes(policy, entry, candidate.Status == CandidateStatusWithdrawn) {
+			separate = append(separate, entry)
+		} else {
+			tally = append(tally, entry)
+		}
+	}
+	sort.Slice(tally, func(i, j int) bool {
+		return tally[i].CandidateID < tally[j].CandidateID
+	})
+	sort.Slice(separate, func(i, j int) bool {
+		return separate[i].CandidateID < separate[j].CandidateID
+	})
+
+	writeInVotes, err := unadjudicatedWriteInVotes(ctx, electionID)
+	if err != nil {
+		return nil, err
+	}
+	if writeInVotes > 0 {
+		tally = append(tally, &CandidateTally{CandidateID: WriteInTallyID, Votes: writeInVotes})
+	}
+
+	return append(tally, separate...), nil
+}
+
+func withdrawnVotesPolicy(election *Election) string {
+	if election == nil {
+		return WithdrawnVotesKeep
+	}
+	return election.Rules.WithdrawnVotes
+}
+
+// applyWithdrawnVotes flags the tally entry of a withdrawn candidate and
+// tallies its votes according to policy. It reports whether the entry is
+// listed separately, after the other candidates and the write-ins.
+func applyWithdrawnVotes(policy string, entry *CandidateTally, withdrawn bool) bool {
+	if !withdrawn {
+		return false
+	}
+
+	entry.Withdrawn = true
+	switch policy {
+	case WithdrawnVotesVoid:
+		entry.VoidedVotes = entry.Votes
+		entry.Votes = 0
+	case WithdrawnVotesSeparate:
+		return true
+	}
+	return false
+}
+
+// ComputeTallyHash returns the hex encoded SHA-256 hash of the election tally,
+// which each organization evaluates on its own peer before certifying.
+func (c *ElectionContract) ComputeTallyHash(ctx *ElectionContext, electionID string) (string, error) {
+	_, hash, err := hashedTally(ctx, electionID)
+	return hash, err
+}
+
+// hashedTally returns the tally of the election together with its hash.
+func hashedTally(ctx contractapi.TransactionContextInterface, electionID string) ([]*CandidateTally, string, error) {
+	tally, err := getTally(ctx, electionID)
+	if err != nil {
+		return nil, "", err
+	}
+	hash, err := tallyHash(tally)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return tally, hash, nil
+}
+
+// CertifyResults records the submitting organization's attestation of the
+// tally hash. The election becomes Certified once a quorum of organizations
+// agree on the same hash, and Disputed as soon as an attestation differs from
+// another one or from the tally on the endorsing peer.
+func (c *ElectionContract) CertifyResults(ctx *ElectionContext, electionID string, tallyHash string) error {
+	if len(tallyHash) == 0 {
+		return fmt.Errorf("tallyHash cannot be empty")
+	}
+
+	election, err := ctx.ReadElection(electionID)
+	if err != nil {
+		return err
+	}
+	err = assertNotPaused(election)
+	if err != nil {
+		return err
+	}
+	if election.Status != ElectionStatusClosed {
+		return fmt.Errorf("election %s is %s, results can only be certified once it is closed", electionID, election.Status)
+	}
+
+	mspID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return fmt.Errorf("failed to read client MSP ID: %v", err)
+	}
+	if !containsString(election.Organizations, mspID) {
+		return fmt.Errorf("organization %s does not participate in election %s", mspID, electionID)
+	}
+
+	tally, computed, err := hashedTally(ctx, electionID)
+	if err != nil {
+		return err
+	}
+
+	key, err := ctx.GetStub().CreateCompositeKey(attestationDocType, []string{electionID, mspID})
+	if err != nil {
+		return fmt.Errorf("failed to create attestation key: %v", err)
+	}
+	existing, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return fmt.Errorf("failed to read attestation: %v", err)
+	}
+	if existing != nil {
+		return fmt.Errorf("organization %s has already certified election %s", mspID, electionID)
+	}
+
+	timestamp, err := txTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	attestation := Attestation{
+		DocType:       attestationDocType,
+		SchemaVersion: currentSchemaVersion,
+		ElectionID:    electionID,
+		MSPID:         mspID,
+		TallyHash:     tallyHash,
+		TxID:          ctx.GetStub().GetTxID(),
+		Timestamp:     timestamp,
+	}
+	attestationJSON, err := json.Marshal(attestation)
+	if err != nil {
+		return fmt.Errorf("failed to marshal attestation: %v", err)
+	}
+	err = ctx.GetStub().PutState(key, attestationJSON)
+	if err != nil {
+		return fmt.Errorf("failed to put attestation: %v", err)
+	}
+
+	attestations, err := getAttestations(ctx, electionID)
+	if err != nil {
+		return err
+	}
+	// The attestation written above is not visible to reads in the same
+	// transaction, so account for it explicitly.
+	attestations = append(attestations, &attestation)
+
+	if computed != tallyHash {
+		election.Status = ElectionStatusDisputed
+		return putElection(ctx, election)
+	}
+	var organizations []string
+	for _, other := range attestations {
+		if other.TallyHash != tallyHash {
+			election.Status = ElectionStatusDisputed
+			return putElection(ctx, election)
+		}
+		organizations = append(organizations, other.MSPID)
+	}
+
+	if len(organizations) < requiredApprovals(election) {
+		return nil
+	}
+
+	sort.Strings(organizations)
+
+	result := ElectionResult{
+		DocType:       resultDocType,
+		SchemaVersion: currentSchemaVersion,
+		ElectionID:    electionID,
+		TallyHash:     tallyHash,
+		Tally:         tally,
+		Organizations: organizations,
+		CertifiedAt:   timestamp,
+	}
+	resultJSON, err := json.Marshal(result)
+	if err != nil {
+		return fmt.Errorf("failed to marshal result: %v", err)
+	}
+	key, err = resultKey(ctx, electionID)
+	if err != nil {
+		return err
+	}
+	err = ctx.GetStub().PutState(key, resultJSON)
+	if err != nil {
+		return fmt.Errorf("failed to put result: %v", err)
+	}
+
+	election.Status = ElectionStatusCertified
+	err = putElection(ctx, election)
+	if err != nil {
+		return err
+	}
+
+	policy, err := endorsementPolicy(election.Organizations, election.EndorsementQuorum)
+	if err != nil {
+		return err
+	}
+	err = ctx.GetStub().SetStateValidationParameter(key, policy)
+	if err != nil {
+		return fmt.Errorf("failed to set result endorsement policy: %v", err)
+	}
+
+	return nil
+}
+
+// GetAttestations returns the attestations submitted so far for the election.
+func (c *ElectionContract) GetAttestations(ctx *ElectionContext, electionID string) ([]*Attestation, error) {
+	return getAttestations(ctx, electionID)
+}
+
+func getAttestations(ctx contractapi.TransactionContextInterface, electionID string) ([]*Attestation, error) {
+	values, err := getPartialCompositeKeyValues(ctx, attestationDocType, []string{electionID})
+	if err != nil {
+		return nil, err
+	}
+
+	var attestations []*Attestation
+	for _, value := range values {
+		var attestation Attestation
+		err = unmarshalAsset(value, &attestation)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal attestation: %v", err)
+		}
+		attestations = append(attestations, &attestation)
+	}
+
+	return attestations, nil
+}
+
+// ReadResult returns the certified result of the election.
+func (c *ElectionContract) ReadResult(ctx *ElectionContext, electionID string) (*ElectionResult, error) {
+	key, err := resultKey(ctx, electionID)
+	if err != nil {
+		return nil, err
+	}
+
+	resultJSON, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read result: %v", err)
+	}
+	if resultJSON == nil {
+		return nil, fmt.Errorf("election %s has no certified result", electionID)
+	}
+
+	var result ElectionResult
+	err = unmarshalAsset(resultJSON, &result)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal result: %v", err)
+	}
+
+	return &result, nil
+}
+
+// requiredApprovals is the number of organizations that have to agree, using
+// the same quorum as the election's endorsement policy.
+func requiredApprovals(election *Election) int {
+	if election.EndorsementQuorum > 0 {
+		return election.EndorsementQuorum
+	}
+	return len(election.Organizations)
+}
+
+func tallyHash(tally []*CandidateTally) (string, error) {
+	tallyJSON, err := json.Marshal(tally)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal tally: %v", err)
+	}
+
+	hash := sha256.Sum256(tallyJSON)
+	return hex.EncodeToString(hash[:]), nil
+}
+
+func txTimestamp(ctx contractapi.TransactionContextInterface) (time.Time, error) {
+	timestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to read transaction timestamp: %v", err)
+	}
+
+	return timestamp.AsTime(), nil
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
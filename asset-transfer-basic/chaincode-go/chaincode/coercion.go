@@ -0,0 +1,393 @@
+package chaincode
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/v2/contractapi"
+)
+
+// Elections in ElectionModeCoercionResistant borrow the credentials of the JCJ
+// scheme. The registrar posts an ElGamal encryption of each voter's real
+// credential to the credential roll and hands the plaintext credential to
+// the voter, who can generate any number of fake credentials that look the
+// same. Ballots carry a fresh encryption of a credential and no voter ID.
+// After the election closes the tallying authority uses plaintext-equivalence
+// tests to drop ballots whose credential is not on the roll, keeps only the
+// last ballot per credential, and submits the surviving ballot IDs with
+// SubmitFilteredTally.
+//
+// Despite the mode's name this does not make the election coercion-resistant.
+// A single authority holds the whole key and runs the filter, so it can link
+// each counted ballot to the voter on the roll, and the ledger takes its list
+// of accepted ballots without any proof that the filter was run honestly.
+const (
+	ElectionModeStandard          = ""
+	ElectionModeCoercionResistant = "coercion-resistant"
+)
+
+const (
+	credentialDocType       = "credential"
+	credentialBallotDocType = "credentialBallot"
+	filteredTallyDocType    = "filteredTally"
+)
+
+// CredentialRecord is a voter's encrypted real credential on the roll.
+type CredentialRecord struct {
+	DocType             string `json:"docType"`
+	SchemaVersion       int    `json:"schemaVersion"`
+	ElectionID          string `json:"electionID"`
+	VoterID             string `json:"voterID"`
+	EncryptedCredential string `json:"encryptedCredential"`
+}
+
+// CredentialBallot is a ballot cast in a credential election.
+type CredentialBallot struct {
+	DocType             string    `json:"docType"`
+	SchemaVersion       int       `json:"schemaVersion"`
+	ID                  string    `json:"id"`
+	ElectionID          string    `json:"electionID"`
+	CandidateID         string    `json:"candidateID"`
+	EncryptedCredential string    `json:"encryptedCredential"`
+	Timestamp           time.Time `json:"timestamp"`
+	DefinitionHash      string    `json:"definitionHash,omitempty" metadata:",optional"`
+}
+
+// FilteredTally records which ballots survived credential filtering.
+type FilteredTally struct {
+	DocType         string   `json:"docType"`
+	SchemaVersion   int      `json:"schemaVersion"`
+	ElectionID      string   `json:"electionID"`
+	AcceptedBallots []string `json:"acceptedBallots,omitempty" metadata:",optional"`
+	SubmittedBy     string   `json:"submittedBy"`
+	TxID            string   `json:"txId"`
+}
+
+// RegisterVoterCredential adds the encryption of a voter's real credential
+// to the roll of a credential election.
+func (c *RegistryContract) RegisterVoterCredential(ctx *RegistryContext, electionID string, voterID string, encryptedCredential string) error {
+	err := ctx.AssertAdmin()
+	if err != nil {
+		return err
+	}
+
+	election, err := coercionResistantElection(ctx, electionID)
+	if err != nil {
+		return err
+	}
+	err = assertNotPaused(election)
+	if err != nil {
+		return err
+	}
+	if election.Status != ElectionStatusCreated && election.Status != ElectionStatusOpen {
+		return fmt.Errorf("election %s is %s, credentials can no longer be registered", electionID, election.Status)
+	}
+
+	voter, err := ctx.ReadVoter(voterID)
+	if err != nil {
+		return err
+	}
+	err = assertEligible(ctx, voter, election)
+	if err != nil {
+		return err
+	}
+
+	err = validateCiphertext(encryptedCredential)
+	if err != nil {
+		return err
+	}
+
+	key, err := ctx.GetStub().CreateCompositeKey(credentialDocType, []string{electionID, voterID})
+	if err != nil {
+		return fmt.Errorf("failed to create credential key: %v", err)
+	}
+	existing, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return fmt.Errorf("failed to read credential: %v", err)
+	}
+	if existing != nil {
+		return fmt.Errorf("voter %s already has a credential for election %s", voterID, electionID)
+	}
+
+	record := CredentialRecord{
+		DocType:             credentialDocType,
+		SchemaVersion:       currentSchemaVersion,
+		ElectionID:          electionID,
+		VoterID:             voterID,
+		EncryptedCredential: encryptedCredential,
+	}
+	recordJSON, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to marshal credential: %v", err)
+	}
+
+	return ctx.GetStub().PutState(key, recordJSON)
+}
+
+// GetCredentialRoll returns the encrypted real credentials of the election.
+func (c *RegistryContract) GetCredentialRoll(ctx *RegistryContext, electionID string) ([]*CredentialRecord, error) {
+	values, err := getPartialCompositeKeyValues(ctx, credentialDocType, []string{electionID})
+	if err != nil {
+		return nil, err
+	}
+
+	var records []*CredentialRecord
+	for _, value := range values {
+		var record CredentialRecord
+		err = unmarshalAsset(value, &record)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal credential: %v", err)
+		}
+		records = append(records, &record)
+	}
+
+	return records, nil
+}
+
+// CastCoercionResistantBallot records a ballot carrying an encrypted
+// credential. The ballot is accepted whether the credential is real or fake;
+// fake ones are removed when the tally is filtered.
+func (c *BallotContract) CastCoercionResistantBallot(ctx *BallotContext, electionID string, candidateID string, encryptedCredential string) error {
+	election, err := coercionResistantElection(ctx, electionID)
+	if err != nil {
+		return err
+	}
+	err = assertNotPaused(election)
+	if err != nil {
+		return err
+	}
+	if election.Status != ElectionStatusOpen {
+		return fmt.Errorf("election %s is not open for voting", electionID)
+	}
+
+	candidateJSON, err := ctx.GetStub().GetState(candidateID)
+	if err != nil {
+		return fmt.Errorf("failed to read candidate: %v", err)
+	}
+	if candidateJSON == nil {
+		return fmt.Errorf("candidate %s does not exist", candidateID)
+	}
+	var candidate Candidate
+	err = unmarshalAsset(candidateJSON, &candidate)
+	if err != nil {
+		return fmt.Errorf("failed to unmarshal candidate: %v", err)
+	}
+	if candidate.ElectionID != electionID {
+		return fmt.Errorf("candidate %s does not run in election %s", candidateID, electionID)
+	}
+	definitionHash, err := assertBallotDefinition(ctx, election, nil, &Vote{CandidateID: candidateID}, "")
+	if err != nil {
+		return err
+	}
+
+	err = validateCiphertext(encryptedCredential)
+	if err != nil {
+		return err
+	}
+
+	timestamp, err := txTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	ballot := CredentialBallot{
+		DocType:             credentialBallotDocType,
+		SchemaVersion:       currentSchemaVersion,
+		ID:                  ctx.GetStub().GetTxID(),
+		ElectionID:          electionID,
+		CandidateID:         candidateID,
+		EncryptedCredential: encryptedCredential,
+		Timestamp:           timestamp,
+		DefinitionHash:      definitionHash,
+	}
+	ballotJSON, err := json.Marshal(ballot)
+	if err != nil {
+		return fmt.Errorf("failed to marshal ballot: %v", err)
+	}
+
+	key, err := ctx.GetStub().CreateCompositeKey(credentialBallotDocType, []string{electionID, ballot.ID})
+	if err != nil {
+		return fmt.Errorf("failed to create ballot key: %v", err)
+	}
+
+	return ctx.GetStub().PutState(key, ballotJSON)
+}
+
+// GetCoercionResistantBallots returns every ballot cast in the election,
+// ordered by the time it was cast.
+func (c *ElectionContract) GetCoercionResistantBallots(ctx *ElectionContext, electionID string) ([]*CredentialBallot, error) {
+	values, err := getPartialCompositeKeyValues(ctx, credentialBallotDocType, []string{electionID})
+	if err != nil {
+		return nil, err
+	}
+
+	var ballots []*CredentialBallot
+	for _, value := range values {
+		var ballot CredentialBallot
+		err = unmarshalAsset(value, &ballot)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal ballot: %v", err)
+		}
+		ballots = append(ballots, &ballot)
+	}
+	sort.SliceStable(ballots, func(i, j int) bool {
+		return ballots[i].Timestamp.Before(ballots[j].Timestamp)
+	})
+
+	return ballots, nil
+}
+
+// SubmitFilteredTally counts the ballots that survived credential filtering
+// into the candidates' vote totals. It can only be submitted once per
+// election, after the election is closed. The list is taken on the word of
+// the submitting admin: the ledger cannot check which ballots the filter
+// should have kept.
+func (c *ElectionContract) SubmitFilteredTally(ctx *ElectionContext, electionID string, acceptedBallots []string) error {
+	err := ctx.AssertAdmin()
+	if err != nil {
+		return err
+	}
+
+	election, err := coercionResistantElection(ctx, electionID)
+	if err != nil {
+		return err
+	}
+	err = assertNotPaused(election)
+	if err != nil {
+		return err
+	}
+	if election.Status != ElectionStatusClosed {
+		return fmt.Errorf("election %s is %s, the tally can only be submitted once it is closed", electionID, election.Status)
+	}
+
+	tallyKey, err := ctx.GetStub().CreateCompositeKey(filteredTallyDocType, []string{electionID})
+	if err != nil {
+		return fmt.Errorf("failed to create filtered tally key: %v", err)
+	}
+	existing, err := ctx.GetStub().GetState(tallyKey)
+	if err != nil {
+		return fmt.Errorf("failed to read filtered tally: %v", err)
+	}
+	if existing != nil {
+		return fmt.Errorf("the tally of election %s has already been submitted", electionID)
+	}
+
+	// Writes are not visible to later reads in the same transaction, so
+	// accumulate the counts before updating each candidate once.
+	counts := make(map[string]int)
+	seen := make(map[string]bool)
+	for _, ballotID := range acceptedBallots {
+		if seen[ballotID] {
+			return fmt.Errorf("ballot %s is listed more than once", ballotID)
+		}
+		seen[ballotID] = true
+
+		key, err := ctx.GetStub().CreateCompositeKey(credentialBallotDocType, []string{electionID, ballotID})
+		if err != nil {
+			return fmt.Errorf("failed to create ballot key: %v", err)
+		}
+		ballotJSON, err := ctx.GetStub().GetState(key)
+		if err != nil {
+			return fmt.Errorf("failed to read ballot: %v", err)
+		}
+		if ballotJSON == nil {
+			return fmt.Errorf("ballot %s does not exist", ballotID)
+		}
+
+		var ballot CredentialBallot
+		err = unmarshalAsset(ballotJSON, &ballot)
+		if err != nil {
+			return fmt.Errorf("failed to unmarshal ballot: %v", err)
+		}
+		counts[ballot.CandidateID]++
+	}
+
+	candidateIDs := make([]string, 0, len(counts))
+	for candidateID := range counts {
+		candidateIDs = append(candidateIDs, candidateID)
+	}
+	sort.Strings(candidateIDs)
+
+	for _, candidateID := range candidateIDs {
+		candidateJSON, err := ctx.GetStub().GetState(candidateID)
+		if err != nil {
+			return fmt.Errorf("failed to read candidate: %v", err)
+		}
+		if candidateJSON == nil {
+			return fmt.Errorf("candidate %s does not exist", candidateID)
+		}
+
+		var candidate Candidate
+		err = unmarshalAsset(candidateJSON, &candidate)
+		if err != nil {
+			return fmt.Errorf("failed to unmarshal candidate: %v", err)
+		}
+
+		candidate.Votes += counts[candidateID]
+		candidateJSON, err = json.Marshal(candidate)
+		if err != nil {
+			return fmt.Errorf("failed to marshal candidate: %v", err)
+		}
+		err = ctx.GetStub().PutState(candidateID, candidateJSON)
+		if err != nil {
+			return fmt.Errorf("failed to update candidate: %v", err)
+		}
+	}
+
+	actor, err := clientActor(ctx)
+	if err != nil {
+		return err
+	}
+	tally := FilteredTally{
+		DocType:         filteredTallyDocType,
+		SchemaVersion:   currentSchemaVersion,
+		ElectionID:      electionID,
+		AcceptedBallots: acceptedBallots,
+		SubmittedBy:     actor,
+		TxID:            ctx.GetStub().GetTxID(),
+	}
+	tallyJSON, err := json.Marshal(tally)
+	if err != nil {
+		return fmt.Errorf("failed to marshal filtered tally: %v", err)
+	}
+
+	return ctx.GetStub().PutState(tallyKey, tallyJSON)
+}
+
+func coercionResistantElection(ctx contractapi.TransactionContextInterface, electionID string) (*Election, error) {
+	election, err := getElection(ctx, electionID)
+	if err != nil {
+		return nil, err
+	}
+	if election == nil {
+		return nil, fmt.Errorf("election %s does not exist", electionID)
+	}
+	if election.Rules.Mode != ElectionModeCoercionResistant {
+		return nil, fmt.Errorf("election %s is not a coercion-resistant election", electionID)
+	}
+
+	return election, nil
+}
+
+// validateCiphertext checks that value has the "<hex>.<hex>" form produced by
+// the tally tooling. The ciphertext itself can only be checked off-chain.
+func validateCiphertext(value string) error {
+	parts := strings.Split(value, ".")
+	if len(parts) != 2 {
+		return fmt.Errorf("malformed encrypted credential")
+	}
+	for _, part := range parts {
+		if len(part) == 0 {
+			return fmt.Errorf("malformed encrypted credential")
+		}
+		if _, err := hex.DecodeString(part); err != nil {
+			return fmt.Errorf("malformed encrypted credential: %v", err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,369 @@
+package chaincode
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/hyperledger/fabric-contract-api-go/v2/contractapi"
+)
+
+// ContestSelection is a voter's choice in one contest. An empty list of
+// candidates is a blank vote, allowed when the contest requires no selection.
+// On a ranked ballot the candidates are listed in order of preference.
+type ContestSelection struct {
+	ContestID    string   `json:"contestID"`
+	CandidateIDs []string `json:"candidateIDs,omitempty" metadata:",optional"`
+}
+
+// ContestResult is the tally of one contest. Rounds holds the instant-runoff
+// count of a ranked contest, one tally per round.
+type ContestResult struct {
+	ContestID string            `json:"contestID"`
+	Title     string            `json:"title"`
+	Tally     []*CandidateTally `json:"tally"`
+	Rounds    []*ContestRound   `json:"rounds,omitempty" metadata:",optional"`
+}
+
+// ContestRound is one round of an instant-runoff count. Eliminated is the
+// candidate dropped after the round, empty in the final round.
+type ContestRound struct {
+	Tally      []*CandidateTally `json:"tally"`
+	Eliminated string            `json:"eliminated,omitempty" metadata:",optional"`
+}
+
+// CreateContest adds a contest to the ballot definition of an election that
+// has not opened yet, as a new version of the definition. Pass an empty
+// constituencyID for a contest every voter takes part in. An election without
+// a ballot definition gets a plurality ballot, or an approval ballot when the
+// contest allows several selections. Once the ballot has several contests,
+// voters cast a single ballot covering all of them with CastContestBallot.
+func (c *ElectionContract) CreateContest(ctx *ElectionContext, electionID string, contestID string, title string, constituencyID string, candidateIDs []string, minSelections int, maxSelections int) error {
+	err := ctx.AssertAdmin()
+	if err != nil {
+		return err
+	}
+
+	election, err := ctx.ReadElection(electionID)
+	if err != nil {
+		return err
+	}
+	err = assertNotPaused(election)
+	if err != nil {
+		return err
+	}
+	if election.Status != ElectionStatusCreated {
+		return fmt.Errorf("election %s is %s, contests can only be added before it opens", electionID, election.Status)
+	}
+
+	latest, err := getLatestBallotDefinition(ctx, electionID)
+	if err != nil {
+		return err
+	}
+	document := BallotDocument{VotingMethod: VotingMethodPlurality}
+	if maxSelections > 1 {
+		document.VotingMethod = VotingMethodApproval
+	}
+	if latest != nil {
+		document.VotingMethod = latest.Document.VotingMethod
+		document.Contests = append(document.Contests, latest.Document.Contests...)
+	}
+	for _, contest := range document.Contests {
+		if contest.ID == contestID {
+			return fmt.Errorf("contest %s already exists in election %s", contestID, electionID)
+		}
+	}
+	document.Contests = append(document.Contests, &BallotContest{
+		ID:             contestID,
+		Title:          title,
+		CandidateIDs:   candidateIDs,
+		MinSelections:  minSelections,
+		MaxSelections:  maxSelections,
+		ConstituencyID: constituencyID,
+	})
+
+	_, err = putNextBallotDefinition(ctx, electionID, document, latest)
+	return err
+}
+
+// GetContests returns the contests of the election's current ballot
+// definition, in ballot order.
+func (c *ElectionContract) GetContests(ctx *ElectionContext, electionID string) ([]*BallotContest, error) {
+	if len(electionID) == 0 {
+		return nil, fmt.Errorf("electionID cannot be empty")
+	}
+
+	return getContests(ctx, electionID)
+}
+
+// CastContestBallot casts the voter's ballot for every contest of the
+// election in the voter's district in one transaction. The ballot must hold
+// exactly one selection per such contest; either all of them are counted or
+// none is.
+func (c *BallotContract) CastContestBallot(ctx *BallotContext, voterID string, electionID string, selections []*ContestSelection) error {
+	return castContestBallot(ctx, voterID, electionID, selections, &Vote{}, "")
+}
+
+func castContestBallot(ctx contractapi.TransactionContextInterface, voterID string, electionID string, selections []*ContestSelection, vote *Vote, definitionHash string) error {
+	all, err := getContests(ctx, electionID)
+	if err != nil {
+		return err
+	}
+	if len(all) == 0 {
+		return fmt.Errorf("election %s has no contests", electionID)
+	}
+
+	voter, err := readVoter(ctx, voterID)
+	if err != nil {
+		return err
+	}
+	contests, err := voterContests(ctx, all, voter)
+	if err != nil {
+		return err
+	}
+
+	bySelection := make(map[string]*ContestSelection)
+	for _, selection := range selections {
+		if selection == nil {
+			return fmt.Errorf("selections cannot contain null entries")
+		}
+		if !containsContest(all, selection.ContestID) {
+			return fmt.Errorf("contest %s does not exist in election %s", selection.ContestID, electionID)
+		}
+		if !containsContest(contests, selection.ContestID) {
+			return fmt.Errorf("contest %s is outside the district of voter %s", selection.ContestID, voterID)
+		}
+		if _, ok := bySelection[selection.ContestID]; ok {
+			return fmt.Errorf("contest %s is selected more than once", selection.ContestID)
+		}
+		bySelection[selection.ContestID] = selection
+	}
+
+	// Selections are stored in contest order so that every endorsing peer
+	// writes the same vote. The ballot definition check in castBallot
+	// validates each of them.
+	ordered := make([]*ContestSelection, 0, len(contests))
+	for _, contest := range contests {
+		selection, ok := bySelection[contest.ID]
+		if !ok {
+			return fmt.Errorf("the ballot has no selection for contest %s", contest.ID)
+		}
+		ordered = append(ordered, selection)
+	}
+
+	vote.Selections = ordered
+	return castBallot(ctx, voterID, electionID, vote, definitionHash)
+}
+
+// GetContestResults returns the tally of each contest of the election,
+// following the same withdrawal rules as GetTally. Ranked contests also
+// report their instant-runoff rounds, in which withdrawn candidates are
+// skipped on every ballot. Results of weighted and delegation elections are
+// only available once the election closes.
+func (c *ElectionContract) GetContestResults(ctx *ElectionContext, electionID string) ([]*ContestResult, error) {
+	election, err := getElection(ctx, electionID)
+	if err != nil {
+		return nil, err
+	}
+	err = assertResultsPublished(election)
+	if err != nil {
+		return nil, err
+	}
+
+	definition, err := getLatestBallotDefinition(ctx, electionID)
+	if err != nil {
+		return nil, err
+	}
+	if definition == nil || len(definition.Document.Contests) == 0 {
+		return nil, fmt.Errorf("election %s has no contests", electionID)
+	}
+
+	tally, err := getTally(ctx, electionID)
+	if err != nil {
+		return nil, err
+	}
+
+	var ballots []*rankedBallot
+	if definition.Document.VotingMethod == VotingMethodRanked {
+		ballots, err = getRankedBallots(ctx, electionID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	results := make([]*ContestResult, 0, len(definition.Document.Contests))
+	for _, contest := range definition.Document.Contests {
+		result := &ContestResult{ContestID: contest.ID, Title: contest.Title, Tally: []*CandidateTally{}}
+		withdrawn := make(map[string]bool)
+		for _, entry := range tally {
+			if containsString(contest.CandidateIDs, entry.CandidateID) {
+				result.Tally = append(result.Tally, entry)
+				withdrawn[entry.CandidateID] = entry.Withdrawn
+			}
+		}
+		if definition.Document.VotingMethod == VotingMethodRanked {
+			result.Rounds = instantRunoff(contest, ballots, withdrawn)
+		}
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
+// rankedBallot is a counted ballot with its preferences in each contest.
+type rankedBallot struct {
+	weight      int
+	preferences map[string][]string
+}
+
+// getRankedBallots returns the ballots of the election that currently count.
+// A vote for a single candidate ranks only that candidate.
+func getRankedBallots(ctx contractapi.TransactionContextInterface, electionID string) ([]*rankedBallot, error) {
+	election, err := getElection(ctx, electionID)
+	if err != nil {
+		return nil, err
+	}
+	definition, err := getLatestBallotDefinition(ctx, electionID)
+	if err != nil {
+		return nil, err
+	}
+	values, err := getPartialCompositeKeyValues(ctx, voteDocType, []string{electionID})
+	if err != nil {
+		return nil, err
+	}
+
+	var ballots []*rankedBallot
+	for _, value := range values {
+		var vote Vote
+		err = unmarshalAsset(value, &vote)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal vote: %v", err)
+		}
+		if vote.SupersededBy != "" {
+			continue
+		}
+
+		weight, err := voteWeight(ctx, election, &vote)
+		if err != nil {
+			return nil, err
+		}
+		ballot := &rankedBallot{weight: weight, preferences: make(map[string][]string)}
+		for _, selection := range vote.Selections {
+			ballot.preferences[selection.ContestID] = selection.CandidateIDs
+		}
+		if len(vote.Selections) == 0 && vote.CandidateID != "" {
+			if contest := candidateContest(definition.Document.Contests, vote.CandidateID); contest != nil {
+				ballot.preferences[contest.ID] = []string{vote.CandidateID}
+			}
+		}
+		ballots = append(ballots, ballot)
+	}
+
+	return ballots, nil
+}
+
+// instantRunoff counts the ranked ballots of contest in rounds. Each ballot
+// counts for its highest ranked candidate still in the race. The count stops
+// once a candidate holds a majority of the counted ballots or a single
+// candidate remains; otherwise the candidate with the fewest votes is
+// eliminated, ties going against the greatest candidate ID.
+func instantRunoff(contest *BallotContest, ballots []*rankedBallot, withdrawn map[string]bool) []*ContestRound {
+	remaining := make(map[string]bool)
+	for _, candidateID := range contest.CandidateIDs {
+		if !withdrawn[candidateID] {
+			remaining[candidateID] = true
+		}
+	}
+
+	var rounds []*ContestRound
+	for len(remaining) > 0 {
+		votes := make(map[string]int)
+		total := 0
+		for _, ballot := range ballots {
+			for _, candidateID := range ballot.preferences[contest.ID] {
+				if remaining[candidateID] {
+					votes[candidateID] += ballot.weight
+					total += ballot.weight
+					break
+				}
+			}
+		}
+
+		candidateIDs := make([]string, 0, len(remaining))
+		for candidateID := range remaining {
+			candidateIDs = append(candidateIDs, candidateID)
+		}
+		sort.Strings(candidateIDs)
+
+		round := &ContestRound{}
+		last := ""
+		elected := len(remaining) == 1
+		for _, candidateID := range candidateIDs {
+			round.Tally = append(round.Tally, &CandidateTally{CandidateID: candidateID, Votes: votes[candidateID]})
+			if votes[candidateID]*2 > total {
+				elected = true
+			}
+			if last == "" || votes[candidateID] <= votes[last] {
+				last = candidateID
+			}
+		}
+		if elected {
+			return append(rounds, round)
+		}
+
+		round.Eliminated = last
+		rounds = append(rounds, round)
+		delete(remaining, last)
+	}
+
+	return rounds
+}
+
+// validateContestSelection checks a selection against its contest's number
+// of selections and candidates.
+func validateContestSelection(ctx contractapi.TransactionContextInterface, contest *BallotContest, selection *ContestSelection) error {
+	count := len(selection.CandidateIDs)
+	if count < contest.MinSelections || count > contest.MaxSelections {
+		return fmt.Errorf("contest %s requires between %d and %d selections, got %d", contest.ID, contest.MinSelections, contest.MaxSelections, count)
+	}
+
+	seen := make(map[string]bool)
+	for _, candidateID := range selection.CandidateIDs {
+		if seen[candidateID] {
+			return fmt.Errorf("candidate %s is selected more than once in contest %s", candidateID, contest.ID)
+		}
+		seen[candidateID] = true
+
+		if !containsString(contest.CandidateIDs, candidateID) {
+			return fmt.Errorf("candidate %s does not run in contest %s", candidateID, contest.ID)
+		}
+		_, err := activeCandidate(ctx, candidateID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func containsContest(contests []*BallotContest, contestID string) bool {
+	for _, contest := range contests {
+		if contest.ID == contestID {
+			return true
+		}
+	}
+	return false
+}
+
+// getContests returns the contests of the election's current ballot
+// definition, which is the locked one once the election has opened.
+func getContests(ctx contractapi.TransactionContextInterface, electionID string) ([]*BallotContest, error) {
+	definition, err := getLatestBallotDefinition(ctx, electionID)
+	if err != nil {
+		return nil, err
+	}
+	if definition == nil {
+		return []*BallotContest{}, nil
+	}
+
+	return definition.Document.Contests, nil
+}
@@ -0,0 +1,142 @@
+package chaincode
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"unicode"
+
+	"github.com/hyperledger/fabric-chaincode-go/v2/shim"
+	"github.com/hyperledger/fabric-contract-api-go/v2/contractapi"
+	"github.com/hyperledger/fabric-protos-go-apiv2/peer"
+)
+
+// NewContracts returns the contracts of the chaincode. SmartContract comes
+// first so that it stays the default contract for the transactions that
+// span the whole ledger.
+func NewContracts() []contractapi.ContractInterface {
+	return []contractapi.ContractInterface{
+		&SmartContract{},
+		&RegistryContract{},
+		&ElectionContract{},
+		&BallotContract{},
+	}
+}
+
+// Chaincode runs the contracts of NewContracts. Transactions invoked without
+// a namespace that belong to one of the registry:, election: or ballot:
+// contracts are routed to it, so clients written before the split keep
+// working.
+type Chaincode struct {
+	*contractapi.ContractChaincode
+	routes map[string]string
+}
+
+// NewChaincode creates the chaincode from NewContracts.
+func NewChaincode() (*Chaincode, error) {
+	contracts := NewContracts()
+	cc, err := contractapi.NewChaincode(contracts...)
+	if err != nil {
+		return nil, err
+	}
+
+	// Methods promoted from contractapi.Contract are not transactions.
+	excluded := make(map[string]bool)
+	base := reflect.TypeOf(&contractapi.Contract{})
+	for i := 0; i < base.NumMethod(); i++ {
+		excluded[base.Method(i).Name] = true
+	}
+
+	routes := make(map[string]string)
+	for _, contract := range contracts[1:] {
+		contractType := reflect.TypeOf(contract)
+		for i := 0; i < contractType.NumMethod(); i++ {
+			name := contractType.Method(i).Name
+			if !excluded[name] {
+				routes[name] = contract.GetName() + ":" + name
+			}
+		}
+	}
+
+	return &Chaincode{ContractChaincode: cc, routes: routes}, nil
+}
+
+// Invoke routes un-namespaced transactions to the contract that owns them.
+func (cc *Chaincode) Invoke(stub shim.ChaincodeStubInterface) *peer.Response {
+	function, params := stub.GetFunctionAndParameters()
+	if !strings.Contains(function, ":") && function != "" {
+		name := []rune(function)
+		name[0] = unicode.ToUpper(name[0])
+		if route, ok := cc.routes[string(name)]; ok {
+			stub = &routedStub{ChaincodeStubInterface: stub, function: route, params: params}
+		}
+	}
+
+	return cc.ContractChaincode.Invoke(stub)
+}
+
+// Start starts the chaincode in the peer.
+func (cc *Chaincode) Start() error {
+	return shim.Start(cc)
+}
+
+// routedStub replaces the function name of the invocation.
+type routedStub struct {
+	shim.ChaincodeStubInterface
+	function string
+	params   []string
+}
+
+func (s *routedStub) GetFunctionAndParameters() (string, []string) {
+	return s.function, s.params
+}
+
+func (s *routedStub) GetStringArgs() []string {
+	return append([]string{s.function}, s.params...)
+}
+
+func (s *routedStub) GetArgs() [][]byte {
+	args := [][]byte{[]byte(s.function)}
+	for _, param := range s.params {
+		args = append(args, []byte(param))
+	}
+	return args
+}
+
+// contractContext holds the validation shared by the transaction contexts of
+// the namespaced contracts.
+type contractContext struct {
+	contractapi.TransactionContext
+}
+
+// Function returns the name of the invoked transaction without the contract
+// namespace.
+func (ctx *contractContext) Function() string {
+	return transactionName(ctx)
+}
+
+// RejectWhenPaused applies the global pause switch to the transaction.
+func (ctx *contractContext) RejectWhenPaused() error {
+	return rejectWhenPaused(ctx)
+}
+
+// AssertAdmin checks that the submitting client is an administrator.
+func (ctx *contractContext) AssertAdmin() error {
+	return assertAdmin(ctx)
+}
+
+// ReadElection loads an election that has to exist on the ledger.
+func (ctx *contractContext) ReadElection(electionID string) (*Election, error) {
+	return readElection(ctx, electionID)
+}
+
+// ReadVoter loads a registered voter.
+func (ctx *contractContext) ReadVoter(voterID string) (*Voter, error) {
+	return readVoter(ctx, voterID)
+}
+
+// unknownTransaction is the error returned for a function a contract does
+// not have.
+func (ctx *contractContext) unknownTransaction(contractName string) error {
+	return fmt.Errorf("function %s is not part of the %s contract", ctx.Function(), contractName)
+}
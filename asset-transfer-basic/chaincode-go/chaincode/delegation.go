@@ -0,0 +1,526 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/v2/contractapi"
+)
+
+const (
+	delegationDocType = "delegation"
+	// delegateIndex maps an election and delegate to the voters who
+	// delegated their vote to that delegate.
+	delegateIndex = "election~delegate"
+)
+
+const defaultMaxDelegationDepth = 3
+
+// Delegation records that From lets To vote on their behalf in an election.
+type Delegation struct {
+	DocType       string    `json:"docType"`
+	SchemaVersion int       `json:"schemaVersion"`
+	ElectionID    string    `json:"electionID"`
+	From          string    `json:"from"`
+	To            string    `json:"to"`
+	Timestamp     time.Time `json:"timestamp"`
+}
+
+// DelegationPayload is the content of a voter-signed delegation, which From
+// signs like a ballot. To names the delegate, or is empty to revoke From's
+// delegation. KeyVersion is From's key version when signing, so the
+// signature is no longer accepted once the voter registers a new key.
+type DelegationPayload struct {
+	ElectionID string `json:"electionID"`
+	From       string `json:"from"`
+	To         string `json:"to,omitempty" metadata:",optional"`
+	KeyVersion int    `json:"keyVersion"`
+	// Nonce is chosen by the voter and must differ between their ballots
+	// and delegations.
+	Nonce string `json:"nonce"`
+}
+
+// DelegateVote lets from transfer their vote, including any votes delegated
+// to them, to another voter. If the delegate has already voted, the ballot
+// they cast gains the delegated weight immediately. Elections that require
+// signed ballots only accept delegations submitted with
+// SubmitSignedDelegation.
+func (c *BallotContract) DelegateVote(ctx *BallotContext, from string, to string, electionID string) error {
+	election, err := unsignedDelegationElection(ctx, electionID)
+	if err != nil {
+		return err
+	}
+
+	return delegate(ctx, election, from, to)
+}
+
+func delegate(ctx contractapi.TransactionContextInterface, election *Election, from string, to string) error {
+	electionID := election.ID
+	err := assertNotPaused(election)
+	if err != nil {
+		return err
+	}
+	if from == to {
+		return fmt.Errorf("voter %s cannot delegate to themselves", from)
+	}
+	for _, voterID := range []string{from, to} {
+		_, err := readVoter(ctx, voterID)
+		if err != nil {
+			return err
+		}
+	}
+
+	vote, err := getVoterVote(ctx, electionID, from)
+	if err != nil {
+		return err
+	}
+	if vote != nil {
+		return fmt.Errorf("voter %s has already voted in election %s", from, electionID)
+	}
+	existing, err := getDelegation(ctx, electionID, from)
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		return fmt.Errorf("voter %s has already delegated to %s", from, existing.To)
+	}
+
+	maxDepth := maxDelegationDepth(election)
+
+	// Walk the chain starting at the delegate. Reaching from again would
+	// close a cycle.
+	hops := 0
+	for current := to; ; hops++ {
+		if current == from {
+			return fmt.Errorf("delegating from %s to %s would create a cycle", from, to)
+		}
+		if hops > maxDepth {
+			return fmt.Errorf("delegation chain exceeds the maximum depth of %d", maxDepth)
+		}
+		next, err := getDelegation(ctx, election.ID, current)
+		if err != nil {
+			return err
+		}
+		if next == nil {
+			break
+		}
+		current = next.To
+	}
+
+	incoming, err := incomingDepth(ctx, electionID, from, maxDepth)
+	if err != nil {
+		return err
+	}
+	if incoming+1+hops > maxDepth {
+		return fmt.Errorf("delegation chain exceeds the maximum depth of %d", maxDepth)
+	}
+
+	timestamp, err := txTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+	delegation := Delegation{
+		DocType:       delegationDocType,
+		SchemaVersion: currentSchemaVersion,
+		ElectionID:    electionID,
+		From:          from,
+		To:            to,
+		Timestamp:     timestamp,
+	}
+	err = putDelegation(ctx, &delegation)
+	if err != nil {
+		return err
+	}
+
+	weight, err := delegatedWeight(ctx, election, from, maxDepth)
+	if err != nil {
+		return err
+	}
+	deltas := make(map[voteTarget]int)
+	err = adjustDelegateVote(ctx, election, to, weight, maxDepth, deltas)
+	if err != nil {
+		return err
+	}
+
+	return applyVoteDeltas(ctx, election, electionID, deltas)
+}
+
+// RevokeDelegation returns the vote of from, and of everyone who delegated
+// to them, to from. Elections that require signed ballots only accept
+// revocations submitted with SubmitSignedDelegation.
+func (c *BallotContract) RevokeDelegation(ctx *BallotContext, from string, electionID string) error {
+	election, err := unsignedDelegationElection(ctx, electionID)
+	if err != nil {
+		return err
+	}
+
+	return revokeDelegation(ctx, election, from)
+}
+
+func revokeDelegation(ctx contractapi.TransactionContextInterface, election *Election, from string) error {
+	electionID := election.ID
+	err := assertNotPaused(election)
+	if err != nil {
+		return err
+	}
+
+	delegation, err := getDelegation(ctx, electionID, from)
+	if err != nil {
+		return err
+	}
+	if delegation == nil {
+		return fmt.Errorf("voter %s has not delegated their vote in election %s", from, electionID)
+	}
+
+	maxDepth := maxDelegationDepth(election)
+	weight, err := delegatedWeight(ctx, election, from, maxDepth)
+	if err != nil {
+		return err
+	}
+	deltas := make(map[voteTarget]int)
+	err = adjustDelegateVote(ctx, election, delegation.To, -weight, maxDepth, deltas)
+	if err != nil {
+		return err
+	}
+	err = applyVoteDeltas(ctx, election, electionID, deltas)
+	if err != nil {
+		return err
+	}
+
+	return deleteDelegation(ctx, delegation)
+}
+
+// ComputeDelegationPayload returns the canonical encoding of payload, which
+// is the exact text the voter signs.
+func (c *BallotContract) ComputeDelegationPayload(ctx *BallotContext, payload DelegationPayload) (string, error) {
+	return canonicalPayload(payload, "delegation")
+}
+
+// SubmitSignedDelegation delegates or revokes the vote of the voter named in
+// payload after verifying signature, the base64 encoded signature of payload
+// by the voter's registered key. Payload must be the canonical encoding
+// returned by ComputeDelegationPayload.
+func (c *BallotContract) SubmitSignedDelegation(ctx *BallotContext, payload string, signature string) error {
+	var delegation DelegationPayload
+	err := decodeSignedPayload(payload, &delegation, "delegation")
+	if err != nil {
+		return err
+	}
+	if len(signature) == 0 {
+		return fmt.Errorf("signature cannot be empty")
+	}
+
+	election, err := delegationElection(ctx, delegation.ElectionID)
+	if err != nil {
+		return err
+	}
+	voter, err := ctx.ReadVoter(delegation.From)
+	if err != nil {
+		return err
+	}
+	if delegation.KeyVersion != voter.KeyVersion {
+		return fmt.Errorf("delegation is signed for key version %d, voter %s has key version %d", delegation.KeyVersion, voter.ID, voter.KeyVersion)
+	}
+	err = useVoterNonce(ctx, election.ID, voter.ID, delegation.Nonce)
+	if err != nil {
+		return err
+	}
+	err = verifyVoterSignature(ctx, voter, payload, signature, "delegation")
+	if err != nil {
+		return err
+	}
+
+	if delegation.To == "" {
+		return revokeDelegation(ctx, election, voter.ID)
+	}
+	return delegate(ctx, election, voter.ID, delegation.To)
+}
+
+func (c *BallotContract) ReadDelegation(ctx *BallotContext, from string, electionID string) (*Delegation, error) {
+	delegation, err := getDelegation(ctx, electionID, from)
+	if err != nil {
+		return nil, err
+	}
+	if delegation == nil {
+		return nil, fmt.Errorf("voter %s has not delegated their vote in election %s", from, electionID)
+	}
+
+	return delegation, nil
+}
+
+// votingWeight is the number of votes a ballot cast by voterID counts for.
+func votingWeight(ctx contractapi.TransactionContextInterface, election *Election, voterID string) (int, error) {
+	if election == nil || !election.Rules.AllowDelegation {
+		return registeredWeight(ctx, election, voterID)
+	}
+
+	return delegatedWeight(ctx, election, voterID, maxDelegationDepth(election))
+}
+
+// delegatedWeight is the vote of voterID plus the votes of everyone who
+// delegated to them, directly or transitively, and has not voted themselves.
+func delegatedWeight(ctx contractapi.TransactionContextInterface, election *Election, voterID string, depth int) (int, error) {
+	weight, err := registeredWeight(ctx, election, voterID)
+	if err != nil {
+		return 0, err
+	}
+	if depth == 0 {
+		return weight, nil
+	}
+
+	delegators, err := getDelegators(ctx, election.ID, voterID)
+	if err != nil {
+		return 0, err
+	}
+	for _, delegator := range delegators {
+		vote, err := getVoterVote(ctx, election.ID, delegator)
+		if err != nil {
+			return 0, err
+		}
+		if vote != nil {
+			continue
+		}
+
+		delegated, err := delegatedWeight(ctx, election, delegator, depth-1)
+		if err != nil {
+			return 0, err
+		}
+		weight += delegated
+	}
+
+	return weight, nil
+}
+
+// reclaimDelegatedWeight takes weight back from the ballot that was cast on
+// behalf of voterID and removes the voter's delegation.
+func reclaimDelegatedWeight(ctx contractapi.TransactionContextInterface, election *Election, voterID string, weight int, deltas map[voteTarget]int) error {
+	delegation, err := getDelegation(ctx, election.ID, voterID)
+	if err != nil {
+		return err
+	}
+	if delegation == nil {
+		return nil
+	}
+
+	err = adjustDelegateVote(ctx, election, delegation.To, -weight, maxDelegationDepth(election), deltas)
+	if err != nil {
+		return err
+	}
+
+	return deleteDelegation(ctx, delegation)
+}
+
+// adjustDelegateVote follows the delegation chain from delegate to the first
+// voter who has cast a ballot and changes that ballot's weight by delta.
+// Nothing changes if nobody along the chain has voted yet.
+func adjustDelegateVote(ctx contractapi.TransactionContextInterface, election *Election, delegate string, delta int, maxDepth int, deltas map[voteTarget]int) error {
+	vote, err := delegateVote(ctx, election, delegate, maxDepth)
+	if err != nil || vote == nil {
+		return err
+	}
+
+	weight, err := voteWeight(ctx, election, vote)
+	if err != nil {
+		return err
+	}
+	err = putVoteWeight(ctx, election, vote, weight+delta)
+	if err != nil {
+		return err
+	}
+	for _, target := range targetsOf(vote) {
+		deltas[target] += delta
+	}
+
+	return nil
+}
+
+// delegateVote returns the ballot of the first voter along the delegation
+// chain from delegate who has voted, or nil if nobody has.
+func delegateVote(ctx contractapi.TransactionContextInterface, election *Election, delegate string, maxDepth int) (*Vote, error) {
+	current := delegate
+	for hops := 0; hops <= maxDepth; hops++ {
+		vote, err := getVoterVote(ctx, election.ID, current)
+		if err != nil {
+			return nil, err
+		}
+		if vote != nil {
+			return vote, nil
+		}
+
+		next, err := getDelegation(ctx, election.ID, current)
+		if err != nil {
+			return nil, err
+		}
+		if next == nil {
+			return nil, nil
+		}
+		current = next.To
+	}
+
+	return nil, nil
+}
+
+// incomingDepth is the length of the longest delegation chain ending at voterID.
+func incomingDepth(ctx contractapi.TransactionContextInterface, electionID string, voterID string, maxDepth int) (int, error) {
+	if maxDepth < 0 {
+		return 0, fmt.Errorf("delegation chain exceeds the maximum depth")
+	}
+
+	delegators, err := getDelegators(ctx, electionID, voterID)
+	if err != nil {
+		return 0, err
+	}
+
+	depth := 0
+	for _, delegator := range delegators {
+		d, err := incomingDepth(ctx, electionID, delegator, maxDepth-1)
+		if err != nil {
+			return 0, err
+		}
+		if d+1 > depth {
+			depth = d + 1
+		}
+	}
+
+	return depth, nil
+}
+
+func delegationElection(ctx contractapi.TransactionContextInterface, electionID string) (*Election, error) {
+	election, err := getElection(ctx, electionID)
+	if err != nil {
+		return nil, err
+	}
+	if election == nil {
+		return nil, fmt.Errorf("election %s does not exist", electionID)
+	}
+	if !election.Rules.AllowDelegation {
+		return nil, fmt.Errorf("election %s does not allow delegated voting", electionID)
+	}
+	if election.Status != ElectionStatusCreated && election.Status != ElectionStatusOpen {
+		return nil, fmt.Errorf("election %s is %s, delegations can no longer be changed", electionID, election.Status)
+	}
+
+	return election, nil
+}
+
+// unsignedDelegationElection returns the election of a delegation submitted
+// without the voter's signature, which elections requiring signed ballots
+// reject: the delegate's signed ballot would otherwise carry a vote its
+// owner never signed.
+func unsignedDelegationElection(ctx contractapi.TransactionContextInterface, electionID string) (*Election, error) {
+	election, err := delegationElection(ctx, electionID)
+	if err != nil {
+		return nil, err
+	}
+	if election.Rules.RequireSignedBallots {
+		return nil, fmt.Errorf("election %s only accepts delegations signed by the voter", electionID)
+	}
+
+	return election, nil
+}
+
+func maxDelegationDepth(election *Election) int {
+	if election.Rules.MaxDelegationDepth > 0 {
+		return election.Rules.MaxDelegationDepth
+	}
+	return defaultMaxDelegationDepth
+}
+
+func getDelegators(ctx contractapi.TransactionContextInterface, electionID string, delegate string) ([]string, error) {
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(delegateIndex, []string{electionID, delegate})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read delegate index: %v", err)
+	}
+	defer resultsIterator.Close()
+
+	var delegators []string
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("failed to iterate through results: %v", err)
+		}
+
+		_, keyParts, err := ctx.GetStub().SplitCompositeKey(queryResponse.Key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to split delegate index key: %v", err)
+		}
+		if len(keyParts) != 3 {
+			return nil, fmt.Errorf("malformed delegate index key: %s", queryResponse.Key)
+		}
+		delegators = append(delegators, keyParts[2])
+	}
+
+	return delegators, nil
+}
+
+func getDelegation(ctx contractapi.TransactionContextInterface, electionID string, from string) (*Delegation, error) {
+	key, err := ctx.GetStub().CreateCompositeKey(delegationDocType, []string{electionID, from})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create delegation key: %v", err)
+	}
+
+	delegationJSON, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read delegation: %v", err)
+	}
+	if delegationJSON == nil {
+		return nil, nil
+	}
+
+	var delegation Delegation
+	err = unmarshalAsset(delegationJSON, &delegation)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal delegation: %v", err)
+	}
+
+	return &delegation, nil
+}
+
+func putDelegation(ctx contractapi.TransactionContextInterface, delegation *Delegation) error {
+	key, err := ctx.GetStub().CreateCompositeKey(delegationDocType, []string{delegation.ElectionID, delegation.From})
+	if err != nil {
+		return fmt.Errorf("failed to create delegation key: %v", err)
+	}
+	delegationJSON, err := json.Marshal(delegation)
+	if err != nil {
+		return fmt.Errorf("failed to marshal delegation: %v", err)
+	}
+	err = ctx.GetStub().PutState(key, delegationJSON)
+	if err != nil {
+		return fmt.Errorf("failed to put delegation: %v", err)
+	}
+
+	indexKey, err := ctx.GetStub().CreateCompositeKey(delegateIndex, []string{delegation.ElectionID, delegation.To, delegation.From})
+	if err != nil {
+		return fmt.Errorf("failed to create delegate index key: %v", err)
+	}
+	err = ctx.GetStub().PutState(indexKey, []byte{0x00})
+	if err != nil {
+		return fmt.Errorf("failed to put delegate index: %v", err)
+	}
+
+	return nil
+}
+
+func deleteDelegation(ctx contractapi.TransactionContextInterface, delegation *Delegation) error {
+	key, err := ctx.GetStub().CreateCompositeKey(delegationDocType, []string{delegation.ElectionID, delegation.From})
+	if err != nil {
+		return fmt.Errorf("failed to create delegation key: %v", err)
+	}
+	err = ctx.GetStub().DelState(key)
+	if err != nil {
+		return fmt.Errorf("failed to delete delegation: %v", err)
+	}
+
+	indexKey, err := ctx.GetStub().CreateCompositeKey(delegateIndex, []string{delegation.ElectionID, delegation.To, delegation.From})
+	if err != nil {
+		return fmt.Errorf("failed to create delegate index key: %v", err)
+	}
+	err = ctx.GetStub().DelState(indexKey)
+	if err != nil {
+		return fmt.Errorf("failed to delete delegate index: %v", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,407 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/v2/contractapi"
+)
+
+const (
+	disputeDocType       = "dispute"
+	disputeElectionIndex = "election~dispute"
+)
+
+const (
+	DisputeStatusFiled          = "Filed"
+	DisputeStatusUnderReview    = "UnderReview"
+	DisputeStatusRecountOrdered = "RecountOrdered"
+	DisputeStatusResultUpheld   = "ResultUpheld"
+	DisputeStatusResultAnnulled = "ResultAnnulled"
+)
+
+// DisputeAction is a single step taken on a dispute.
+type DisputeAction struct {
+	Action    string    `json:"action"`
+	Actor     string    `json:"actor"`
+	Note      string    `json:"note"`
+	TxID      string    `json:"txId"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// Dispute is a formal challenge of the result of a closed election.
+type Dispute struct {
+	DocType        string           `json:"docType"`
+	SchemaVersion  int              `json:"schemaVersion"`
+	ID             string           `json:"id"`
+	ElectionID     string           `json:"electionID"`
+	FiledBy        string           `json:"filedBy"`
+	Reason         string           `json:"reason"`
+	EvidenceHashes []string         `json:"evidenceHashes,omitempty" metadata:",optional"`
+	ReferencedIDs  []string         `json:"referencedIDs,omitempty" metadata:",optional"`
+	Status         string           `json:"status"`
+	Actions        []*DisputeAction `json:"actions,omitempty" metadata:",optional"`
+}
+
+// FileDispute lets an observer or candidate challenge a closed election,
+// referencing the hashes of off-chain evidence and any ballot or receipt IDs.
+// The election is Disputed until the dispute is resolved or a recount is
+// ordered.
+func (c *ElectionContract) FileDispute(ctx *ElectionContext, disputeID string, electionID string, reason string, evidenceHashes []string, referencedIDs []string) error {
+	err := assertRole(ctx, observerRole, candidateRole)
+	if err != nil {
+		return err
+	}
+	if len(disputeID) == 0 {
+		return fmt.Errorf("disputeID cannot be empty")
+	}
+	if len(reason) == 0 {
+		return fmt.Errorf("reason cannot be empty")
+	}
+
+	election, err := ctx.ReadElection(electionID)
+	if err != nil {
+		return err
+	}
+	err = assertNotPaused(election)
+	if err != nil {
+		return err
+	}
+	switch election.Status {
+	case ElectionStatusClosed, ElectionStatusCertified, ElectionStatusDisputed:
+	default:
+		return fmt.Errorf("election %s is %s, only closed elections can be disputed", electionID, election.Status)
+	}
+
+	existing, err := getDispute(ctx, disputeID)
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		return fmt.Errorf("dispute %s already exists", disputeID)
+	}
+
+	actor, err := clientActor(ctx)
+	if err != nil {
+		return err
+	}
+
+	dispute := &Dispute{
+		DocType:        disputeDocType,
+		SchemaVersion:  currentSchemaVersion,
+		ID:             disputeID,
+		ElectionID:     electionID,
+		FiledBy:        actor,
+		Reason:         reason,
+		EvidenceHashes: evidenceHashes,
+		ReferencedIDs:  referencedIDs,
+	}
+	err = recordDisputeAction(ctx, dispute, DisputeStatusFiled, reason)
+	if err != nil {
+		return err
+	}
+
+	if election.Status != ElectionStatusDisputed {
+		election.Status = ElectionStatusDisputed
+		err = putElection(ctx, election)
+		if err != nil {
+			return err
+		}
+	}
+
+	indexKey, err := ctx.GetStub().CreateCompositeKey(disputeElectionIndex, []string{electionID, disputeID})
+	if err != nil {
+		return fmt.Errorf("failed to create dispute index key: %v", err)
+	}
+	err = ctx.GetStub().PutState(indexKey, []byte{0x00})
+	if err != nil {
+		return fmt.Errorf("failed to put dispute index: %v", err)
+	}
+
+	return putDispute(ctx, dispute)
+}
+
+// RespondToDispute records an official response and puts the dispute under
+// review.
+func (c *ElectionContract) RespondToDispute(ctx *ElectionContext, disputeID string, response string) error {
+	dispute, _, err := openDispute(ctx, disputeID)
+	if err != nil {
+		return err
+	}
+
+	err = recordDisputeAction(ctx, dispute, DisputeStatusUnderReview, response)
+	if err != nil {
+		return err
+	}
+
+	return putDispute(ctx, dispute)
+}
+
+// OrderRecount discards the attestations and certified result of the
+// disputed election and returns it to Closed, so that every organization has
+// to certify a fresh tally. Annulled elections cannot be recounted.
+func (c *ElectionContract) OrderRecount(ctx *ElectionContext, disputeID string, note string) error {
+	dispute, election, err := openDispute(ctx, disputeID)
+	if err != nil {
+		return err
+	}
+
+	switch election.Status {
+	case ElectionStatusClosed, ElectionStatusCertified, ElectionStatusDisputed:
+	default:
+		return fmt.Errorf("election %s is %s and cannot be recounted", election.ID, election.Status)
+	}
+
+	err = deleteAttestations(ctx, election.ID)
+	if err != nil {
+		return err
+	}
+
+	key, err := resultKey(ctx, election.ID)
+	if err != nil {
+		return err
+	}
+	err = ctx.GetStub().DelState(key)
+	if err != nil {
+		return fmt.Errorf("failed to delete result: %v", err)
+	}
+
+	election.Status = ElectionStatusClosed
+	err = putElection(ctx, election)
+	if err != nil {
+		return err
+	}
+
+	err = recordDisputeAction(ctx, dispute, DisputeStatusRecountOrdered, note)
+	if err != nil {
+		return err
+	}
+
+	return putDispute(ctx, dispute)
+}
+
+// ResolveDispute closes the dispute. When annul is true the election result
+// is annulled. Otherwise the result is upheld: a disputed election returns to
+// Certified if its result was certified before the dispute. Otherwise it
+// returns to Closed and the conflicting attestations are discarded, so the
+// organizations can certify it again.
+func (c *ElectionContract) ResolveDispute(ctx *ElectionContext, disputeID string, annul bool, note string) error {
+	dispute, election, err := openDispute(ctx, disputeID)
+	if err != nil {
+		return err
+	}
+
+	if election.Status == ElectionStatusAnnulled {
+		return fmt.Errorf("election %s has already been annulled", election.ID)
+	}
+
+	status := DisputeStatusResultUpheld
+	if annul {
+		status = DisputeStatusResultAnnulled
+		election.Status = ElectionStatusAnnulled
+	} else if election.Status == ElectionStatusDisputed {
+		key, err := resultKey(ctx, election.ID)
+		if err != nil {
+			return err
+		}
+		resultJSON, err := ctx.GetStub().GetState(key)
+		if err != nil {
+			return fmt.Errorf("failed to read result: %v", err)
+		}
+		election.Status = ElectionStatusCertified
+		if resultJSON == nil {
+			election.Status = ElectionStatusClosed
+			err = deleteAttestations(ctx, election.ID)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	err = putElection(ctx, election)
+	if err != nil {
+		return err
+	}
+
+	err = recordDisputeAction(ctx, dispute, status, note)
+	if err != nil {
+		return err
+	}
+
+	return putDispute(ctx, dispute)
+}
+
+func (c *ElectionContract) ReadDispute(ctx *ElectionContext, disputeID string) (*Dispute, error) {
+	dispute, err := getDispute(ctx, disputeID)
+	if err != nil {
+		return nil, err
+	}
+	if dispute == nil {
+		return nil, fmt.Errorf("dispute %s does not exist", disputeID)
+	}
+
+	return dispute, nil
+}
+
+func (c *ElectionContract) GetDisputesByElection(ctx *ElectionContext, electionID string) ([]*Dispute, error) {
+	if len(electionID) == 0 {
+		return nil, fmt.Errorf("electionID cannot be empty")
+	}
+
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(disputeElectionIndex, []string{electionID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read dispute index: %v", err)
+	}
+	defer resultsIterator.Close()
+
+	var disputes []*Dispute
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("failed to iterate through results: %v", err)
+		}
+
+		_, keyParts, err := ctx.GetStub().SplitCompositeKey(queryResponse.Key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to split dispute index key: %v", err)
+		}
+		if len(keyParts) != 2 {
+			return nil, fmt.Errorf("malformed dispute index key: %s", queryResponse.Key)
+		}
+
+		dispute, err := getDispute(ctx, keyParts[1])
+		if err != nil {
+			return nil, err
+		}
+		if dispute != nil {
+			disputes = append(disputes, dispute)
+		}
+	}
+
+	return disputes, nil
+}
+
+func deleteAttestations(ctx contractapi.TransactionContextInterface, electionID string) error {
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(attestationDocType, []string{electionID})
+	if err != nil {
+		return fmt.Errorf("failed to read attestations: %v", err)
+	}
+	defer resultsIterator.Close()
+
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return fmt.Errorf("failed to iterate through attestations: %v", err)
+		}
+		err = ctx.GetStub().DelState(queryResponse.Key)
+		if err != nil {
+			return fmt.Errorf("failed to delete attestation: %v", err)
+		}
+	}
+
+	return nil
+}
+
+// openDispute loads a dispute that officials can still act on, together with
+// its election, which must not be paused.
+func openDispute(ctx contractapi.TransactionContextInterface, disputeID string) (*Dispute, *Election, error) {
+	err := assertAdmin(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	dispute, err := getDispute(ctx, disputeID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if dispute == nil {
+		return nil, nil, fmt.Errorf("dispute %s does not exist", disputeID)
+	}
+	if dispute.Status == DisputeStatusResultUpheld || dispute.Status == DisputeStatusResultAnnulled {
+		return nil, nil, fmt.Errorf("dispute %s has already been resolved", disputeID)
+	}
+
+	election, err := readElection(ctx, dispute.ElectionID)
+	if err != nil {
+		return nil, nil, err
+	}
+	err = assertNotPaused(election)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return dispute, election, nil
+}
+
+func recordDisputeAction(ctx contractapi.TransactionContextInterface, dispute *Dispute, status string, note string) error {
+	actor, err := clientActor(ctx)
+	if err != nil {
+		return err
+	}
+	timestamp, err := txTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	dispute.Status = status
+	dispute.Actions = append(dispute.Actions, &DisputeAction{
+		Action:    status,
+		Actor:     actor,
+		Note:      note,
+		TxID:      ctx.GetStub().GetTxID(),
+		Timestamp: timestamp,
+	})
+
+	return nil
+}
+
+func disputeKey(ctx contractapi.TransactionContextInterface, disputeID string) (string, error) {
+	key, err := ctx.GetStub().CreateCompositeKey(disputeDocType, []string{disputeID})
+	if err != nil {
+		return "", fmt.Errorf("failed to create dispute key: %v", err)
+	}
+
+	return key, nil
+}
+
+func getDispute(ctx contractapi.TransactionContextInterface, disputeID string) (*Dispute, error) {
+	key, err := disputeKey(ctx, disputeID)
+	if err != nil {
+		return nil, err
+	}
+
+	disputeJSON, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read dispute: %v", err)
+	}
+	if disputeJSON == nil {
+		return nil, nil
+	}
+
+	var dispute Dispute
+	err = unmarshalAsset(disputeJSON, &dispute)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal dispute: %v", err)
+	}
+
+	return &dispute, nil
+}
+
+func putDispute(ctx contractapi.TransactionContextInterface, dispute *Dispute) error {
+	key, err := disputeKey(ctx, dispute.ID)
+	if err != nil {
+		return err
+	}
+
+	disputeJSON, err := json.Marshal(dispute)
+	if err != nil {
+		return fmt.Errorf("failed to marshal dispute: %v", err)
+	}
+
+	err = ctx.GetStub().PutState(key, disputeJSON)
+	if err != nil {
+		return fmt.Errorf("failed to put dispute: %v", err)
+	}
+
+	return nil
+}
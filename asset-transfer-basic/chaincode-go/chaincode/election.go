@@ -0,0 +1,298 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/v2/contractapi"
+)
+
+const electionDocType = "election"
+
+const (
+	ElectionStatusCreated   = "Created"
+	ElectionStatusOpen      = "Open"
+	ElectionStatusClosed    = "Closed"
+	ElectionStatusCertified = "Certified"
+	ElectionStatusDisputed  = "Disputed"
+	ElectionStatusAnnulled  = "Annulled"
+)
+
+// ElectionRules are the voting options of an election. They can only be
+// changed before the election opens.
+type ElectionRules struct {
+	// AllowRevote lets voters cast a new ballot while the election is open.
+	// Only the last ballot counts; earlier ones are marked as superseded.
+	AllowRevote bool `json:"allowRevote"`
+	// Mode selects how ballots are cast and counted, see ElectionModeStandard
+	// and ElectionModeCoercionResistant.
+	Mode string `json:"mode"`
+	// CredentialPublicKey is the tallying authority's ElGamal public key used
+	// to encrypt voter credentials in ElectionModeCoercionResistant.
+	CredentialPublicKey string `json:"credentialPublicKey"`
+	// AllowDelegation lets voters delegate their vote to another voter.
+	AllowDelegation bool `json:"allowDelegation"`
+	// MaxDelegationDepth limits the length of delegation chains. Zero uses
+	// defaultMaxDelegationDepth.
+	MaxDelegationDepth int `json:"maxDelegationDepth"`
+	// Weighted counts each ballot with the weight the registrar assigned to
+	// the voter, such as a share count, instead of once. The tally of a
+	// weighted election, like that of one allowing delegation, is kept
+	// private until the election closes.
+	Weighted bool `json:"weighted"`
+	// AllowWriteIns lets voters write in a name that is not on the ballot.
+	AllowWriteIns bool `json:"allowWriteIns"`
+	// WithdrawnVotes decides how votes already cast for a withdrawn candidate
+	// are tallied, see the WithdrawnVotes constants.
+	WithdrawnVotes string `json:"withdrawnVotes"`
+	// Ordering is the order candidates are listed in, see the Ordering
+	// constants.
+	Ordering string `json:"ordering"`
+	// Eligibility restricts who can vote, evaluated against the voter's
+	// attributes when a ballot is cast.
+	Eligibility EligibilityRules `json:"eligibility"`
+	// RequireSignedBallots only accepts ballots signed by the voter's
+	// registered key, see CastSignedBallot.
+	RequireSignedBallots bool `json:"requireSignedBallots"`
+}
+
+type Election struct {
+	DocType           string        `json:"docType"`
+	SchemaVersion     int           `json:"schemaVersion"`
+	ID                string        `json:"id"`
+	Title             string        `json:"title"`
+	Status            string        `json:"status"`
+	Organizations     []string      `json:"organizations,omitempty" metadata:",optional"`
+	EndorsementQuorum int           `json:"endorsementQuorum"`
+	Rules             ElectionRules `json:"rules"`
+	Paused            bool          `json:"paused"`
+	PauseReason       string        `json:"pauseReason,omitempty" metadata:",optional"`
+	// BallotDefinitionHash is the ballot definition locked when the election
+	// opened.
+	BallotDefinitionHash string `json:"ballotDefinitionHash,omitempty" metadata:",optional"`
+	// OrderingSeed is the seed the candidate order is drawn from, combined
+	// from the seeds revealed by every organization of the election.
+	OrderingSeed string `json:"orderingSeed,omitempty" metadata:",optional"`
+}
+
+// CreateElection records a new election. Once created, the election and its
+// certified result can only be changed with endorsements from every one of
+// organizations, which also certify its results.
+func (c *ElectionContract) CreateElection(ctx *ElectionContext, electionID string, title string, organizations []string) error {
+	if len(electionID) == 0 {
+		return fmt.Errorf("electionID cannot be empty")
+	}
+
+	existing, err := getElection(ctx, electionID)
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		return fmt.Errorf("election %s already exists", electionID)
+	}
+
+	if len(organizations) == 0 {
+		return fmt.Errorf("organizations cannot be empty")
+	}
+	organizations, err = normalizeOrganizations(organizations, 0)
+	if err != nil {
+		return err
+	}
+
+	election := Election{
+		DocType:       electionDocType,
+		SchemaVersion: currentSchemaVersion,
+		ID:            electionID,
+		Title:         title,
+		Status:        ElectionStatusCreated,
+		Organizations: organizations,
+	}
+
+	err = putElection(ctx, &election)
+	if err != nil {
+		return err
+	}
+
+	return applyElectionEndorsementPolicy(ctx, &election)
+}
+
+func (c *ElectionContract) ReadElection(ctx *ElectionContext, electionID string) (*Election, error) {
+	return ctx.ReadElection(electionID)
+}
+
+// readElection returns the election stored under electionID, failing if it
+// has not been created on the ledger.
+func readElection(ctx contractapi.TransactionContextInterface, electionID string) (*Election, error) {
+	election, err := getElection(ctx, electionID)
+	if err != nil {
+		return nil, err
+	}
+	if election == nil {
+		return nil, fmt.Errorf("election %s does not exist", electionID)
+	}
+
+	return election, nil
+}
+
+func (c *ElectionContract) SetElectionRules(ctx *ElectionContext, electionID string, rules ElectionRules) error {
+	err := ctx.AssertAdmin()
+	if err != nil {
+		return err
+	}
+
+	election, err := ctx.ReadElection(electionID)
+	if err != nil {
+		return err
+	}
+	err = assertNotPaused(election)
+	if err != nil {
+		return err
+	}
+	if election.Status != ElectionStatusCreated {
+		return fmt.Errorf("election %s is %s, rules can only be changed before it opens", electionID, election.Status)
+	}
+
+	switch rules.Mode {
+	case ElectionModeStandard:
+	case ElectionModeCoercionResistant:
+		if len(rules.CredentialPublicKey) == 0 {
+			return fmt.Errorf("coercion-resistant elections require a credential public key")
+		}
+	default:
+		return fmt.Errorf("unknown election mode %q", rules.Mode)
+	}
+
+	switch rules.WithdrawnVotes {
+	case WithdrawnVotesKeep, WithdrawnVotesVoid, WithdrawnVotesSeparate:
+	default:
+		return fmt.Errorf("unknown withdrawn votes policy %q", rules.WithdrawnVotes)
+	}
+	switch rules.Ordering {
+	case OrderingRegistration, OrderingAlphabetical, OrderingRotation, OrderingLot:
+	default:
+		return fmt.Errorf("unknown ordering policy %q", rules.Ordering)
+	}
+	if rules.Ordering != election.Rules.Ordering && election.Rules.Ordering == OrderingLot {
+		contributions, err := getOrderingContributions(ctx, electionID)
+		if err != nil {
+			return err
+		}
+		if len(contributions) > 0 {
+			return fmt.Errorf("the ordering draw of election %s has started, its ordering can no longer change", electionID)
+		}
+	}
+	if rules.MaxDelegationDepth < 0 {
+		return fmt.Errorf("maxDelegationDepth cannot be negative")
+	}
+	err = validateEligibilityRules(&rules.Eligibility)
+	if err != nil {
+		return err
+	}
+
+	election.Rules = rules
+	return putElection(ctx, election)
+}
+
+// OpenElection starts accepting votes for the election.
+func (c *ElectionContract) OpenElection(ctx *ElectionContext, electionID string) error {
+	return transitionElection(ctx, electionID, ElectionStatusCreated, ElectionStatusOpen)
+}
+
+// CloseElection stops accepting votes so that results can be certified.
+func (c *ElectionContract) CloseElection(ctx *ElectionContext, electionID string) error {
+	return transitionElection(ctx, electionID, ElectionStatusOpen, ElectionStatusClosed)
+}
+
+func transitionElection(ctx contractapi.TransactionContextInterface, electionID string, from string, to string) error {
+	err := assertAdmin(ctx)
+	if err != nil {
+		return err
+	}
+
+	election, err := getElection(ctx, electionID)
+	if err != nil {
+		return err
+	}
+	if election == nil {
+		return fmt.Errorf("election %s does not exist", electionID)
+	}
+	err = assertNotPaused(election)
+	if err != nil {
+		return err
+	}
+	if election.Status != from {
+		return fmt.Errorf("election %s is %s, expected %s", electionID, election.Status, from)
+	}
+
+	if to == ElectionStatusOpen {
+		if election.Rules.Ordering == OrderingLot && election.OrderingSeed == "" {
+			return fmt.Errorf("the candidate order of election %s must be drawn before it opens", electionID)
+		}
+		err = lockBallotDefinition(ctx, election)
+		if err != nil {
+			return err
+		}
+	}
+	if to == ElectionStatusClosed && weightedBallots(election) {
+		err = publishWeightedTally(ctx, election)
+		if err != nil {
+			return err
+		}
+	}
+
+	election.Status = to
+	return putElection(ctx, election)
+}
+
+func electionKey(ctx contractapi.TransactionContextInterface, electionID string) (string, error) {
+	key, err := ctx.GetStub().CreateCompositeKey(electionDocType, []string{electionID})
+	if err != nil {
+		return "", fmt.Errorf("failed to create election key: %v", err)
+	}
+
+	return key, nil
+}
+
+// getElection returns the election stored under electionID, or nil if the
+// election has not been created on the ledger.
+func getElection(ctx contractapi.TransactionContextInterface, electionID string) (*Election, error) {
+	key, err := electionKey(ctx, electionID)
+	if err != nil {
+		return nil, err
+	}
+
+	electionJSON, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read election: %v", err)
+	}
+	if electionJSON == nil {
+		return nil, nil
+	}
+
+	var election Election
+	err = unmarshalAsset(electionJSON, &election)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal election: %v", err)
+	}
+
+	return &election, nil
+}
+
+func putElection(ctx contractapi.TransactionContextInterface, election *Election) error {
+	key, err := electionKey(ctx, election.ID)
+	if err != nil {
+		return err
+	}
+
+	electionJSON, err := json.Marshal(election)
+	if err != nil {
+		return fmt.Errorf("failed to marshal election: %v", err)
+	}
+
+	err = ctx.GetStub().PutState(key, electionJSON)
+	if err != nil {
+		return fmt.Errorf("failed to put election: %v", err)
+	}
+
+	return nil
+}
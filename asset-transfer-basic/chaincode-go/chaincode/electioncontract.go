@@ -0,0 +1,41 @@
+package chaincode
+
+import (
+	"github.com/hyperledger/fabric-contract-api-go/v2/contractapi"
+)
+
+const electionContractName = "election"
+
+// ElectionContext is the transaction context of the election contract.
+type ElectionContext struct {
+	contractContext
+}
+
+// ElectionContract holds the transactions that set up, run and tabulate elections and settle disputes about them.
+type ElectionContract struct {
+	contractapi.Contract
+}
+
+func (c *ElectionContract) GetName() string {
+	return electionContractName
+}
+
+func (c *ElectionContract) GetTransactionContextHandler() contractapi.SettableTransactionContextInterface {
+	return new(ElectionContext)
+}
+
+func (c *ElectionContract) GetBeforeTransaction() interface{} {
+	return c.beforeTransaction
+}
+
+func (c *ElectionContract) GetUnknownTransaction() interface{} {
+	return c.unknownTransaction
+}
+
+func (c *ElectionContract) beforeTransaction(ctx *ElectionContext) error {
+	return ctx.RejectWhenPaused()
+}
+
+func (c *ElectionContract) unknownTransaction(ctx *ElectionContext) error {
+	return ctx.unknownTransaction(electionContractName)
+}
@@ -0,0 +1,227 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/v2/contractapi"
+)
+
+// voterAttributesCollection is the private data collection holding the
+// eligibility attributes of voters, see collections_config.json.
+const voterAttributesCollection = "voterAttributes"
+
+const voterAttributesDocType = "voterAttributes"
+
+// voterAttributesField is the transient field SetVoterAttributes reads the
+// attributes from, so that they are not recorded in the transaction.
+const voterAttributesField = "attributes"
+
+// dateLayout is the format of dates in voter attributes and eligibility
+// rules.
+const dateLayout = "2006-01-02"
+
+// VoterAttributes are the facts about a voter that eligibility rules are
+// evaluated against. They are recorded by the registrar and kept out of the
+// world state.
+type VoterAttributes struct {
+	DateOfBirth string `json:"dateOfBirth"`
+	Citizenship string `json:"citizenship"`
+	Region      string `json:"region"`
+}
+
+// EligibilityRules restrict who can vote in an election. Empty rules let
+// every registered voter vote.
+type EligibilityRules struct {
+	// MinimumAge is the age a voter must have reached on ElectionDay.
+	MinimumAge int `json:"minimumAge"`
+	// ElectionDay is the date ages are computed at, formatted as YYYY-MM-DD.
+	ElectionDay string `json:"electionDay"`
+	// Citizenships lists the accepted citizenships, if not empty.
+	Citizenships []string `json:"citizenships,omitempty" metadata:",optional"`
+	// Regions lists the regions a voter must reside in, if not empty.
+	Regions []string `json:"regions,omitempty" metadata:",optional"`
+}
+
+// Eligibility is the outcome of evaluating an election's rules for a voter.
+type Eligibility struct {
+	Eligible bool   `json:"eligible"`
+	Reason   string `json:"reason,omitempty" metadata:",optional"`
+}
+
+// SetVoterAttributes records the eligibility attributes of a registered
+// voter. The attributes are passed as JSON in the transient attributes field
+// and stored in the voterAttributes private data collection.
+func (c *RegistryContract) SetVoterAttributes(ctx *RegistryContext, voterID string) error {
+	err := ctx.AssertAdmin()
+	if err != nil {
+		return err
+	}
+
+	transient, err := ctx.GetStub().GetTransient()
+	if err != nil {
+		return fmt.Errorf("failed to read transient data: %v", err)
+	}
+	attributesJSON, ok := transient[voterAttributesField]
+	if !ok {
+		return fmt.Errorf("voter attributes must be passed in the transient %s field", voterAttributesField)
+	}
+	var attributes VoterAttributes
+	err = json.Unmarshal(attributesJSON, &attributes)
+	if err != nil {
+		return fmt.Errorf("failed to unmarshal voter attributes: %v", err)
+	}
+	err = validateVoterAttributes(&attributes)
+	if err != nil {
+		return err
+	}
+
+	_, err = ctx.ReadVoter(voterID)
+	if err != nil {
+		return err
+	}
+
+	key, err := ctx.GetStub().CreateCompositeKey(voterAttributesDocType, []string{voterID})
+	if err != nil {
+		return fmt.Errorf("failed to create voter attributes key: %v", err)
+	}
+	attributesJSON, err = json.Marshal(attributes)
+	if err != nil {
+		return fmt.Errorf("failed to marshal voter attributes: %v", err)
+	}
+	err = ctx.GetStub().PutPrivateData(voterAttributesCollection, key, attributesJSON)
+	if err != nil {
+		return fmt.Errorf("failed to put voter attributes: %v", err)
+	}
+
+	return nil
+}
+
+// getVoterAttributes returns the attributes recorded for voterID, which are
+// empty if none were recorded.
+func getVoterAttributes(ctx contractapi.TransactionContextInterface, voterID string) (*VoterAttributes, error) {
+	key, err := ctx.GetStub().CreateCompositeKey(voterAttributesDocType, []string{voterID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create voter attributes key: %v", err)
+	}
+	attributesJSON, err := ctx.GetStub().GetPrivateData(voterAttributesCollection, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read voter attributes: %v", err)
+	}
+
+	var attributes VoterAttributes
+	if attributesJSON == nil {
+		return &attributes, nil
+	}
+	err = json.Unmarshal(attributesJSON, &attributes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal voter attributes: %v", err)
+	}
+
+	return &attributes, nil
+}
+
+// CheckEligibility evaluates the eligibility rules of the election for a
+// voter, the same way they are enforced when the voter casts a ballot.
+func (c *BallotContract) CheckEligibility(ctx *BallotContext, electionID string, voterID string) (*Eligibility, error) {
+	election, err := ctx.ReadElection(electionID)
+	if err != nil {
+		return nil, err
+	}
+
+	voter, err := ctx.ReadVoter(voterID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = assertEligible(ctx, voter, election)
+	if err != nil {
+		return &Eligibility{Reason: err.Error()}, nil
+	}
+
+	return &Eligibility{Eligible: true}, nil
+}
+
+// assertEligible rejects a voter who does not meet the election's
+// eligibility rules. Elections that only exist off-chain have no rules.
+func assertEligible(ctx contractapi.TransactionContextInterface, voter *Voter, election *Election) error {
+	if election == nil {
+		return nil
+	}
+	rules := &election.Rules.Eligibility
+	if rules.MinimumAge == 0 && len(rules.Citizenships) == 0 && len(rules.Regions) == 0 {
+		return nil
+	}
+
+	attributes, err := getVoterAttributes(ctx, voter.ID)
+	if err != nil {
+		return err
+	}
+
+	if rules.MinimumAge > 0 {
+		if attributes.DateOfBirth == "" {
+			return fmt.Errorf("voter %s has no date of birth on record", voter.ID)
+		}
+		age, err := ageOn(attributes.DateOfBirth, rules.ElectionDay)
+		if err != nil {
+			return err
+		}
+		if age < rules.MinimumAge {
+			return fmt.Errorf("voter %s is younger than %d on election day", voter.ID, rules.MinimumAge)
+		}
+	}
+	if len(rules.Citizenships) > 0 && !containsString(rules.Citizenships, attributes.Citizenship) {
+		return fmt.Errorf("voter %s does not hold an accepted citizenship", voter.ID)
+	}
+	if len(rules.Regions) > 0 && !containsString(rules.Regions, attributes.Region) {
+		return fmt.Errorf("voter %s does not reside in an eligible region", voter.ID)
+	}
+
+	return nil
+}
+
+func validateEligibilityRules(rules *EligibilityRules) error {
+	if rules.MinimumAge < 0 {
+		return fmt.Errorf("minimumAge cannot be negative")
+	}
+	if rules.MinimumAge > 0 {
+		_, err := time.Parse(dateLayout, rules.ElectionDay)
+		if err != nil {
+			return fmt.Errorf("a minimum age requires an election day formatted as YYYY-MM-DD")
+		}
+	}
+
+	return nil
+}
+
+func validateVoterAttributes(attributes *VoterAttributes) error {
+	if attributes.DateOfBirth != "" {
+		_, err := time.Parse(dateLayout, attributes.DateOfBirth)
+		if err != nil {
+			return fmt.Errorf("dateOfBirth must be formatted as YYYY-MM-DD")
+		}
+	}
+
+	return nil
+}
+
+// ageOn returns the age in whole years of someone born on dateOfBirth at the
+// given day.
+func ageOn(dateOfBirth string, day string) (int, error) {
+	born, err := time.Parse(dateLayout, dateOfBirth)
+	if err != nil {
+		return 0, fmt.Errorf("invalid date of birth %q", dateOfBirth)
+	}
+	on, err := time.Parse(dateLayout, day)
+	if err != nil {
+		return 0, fmt.Errorf("invalid election day %q", day)
+	}
+
+	age := on.Year() - born.Year()
+	if on.Month() < born.Month() || (on.Month() == born.Month() && on.Day() < born.Day()) {
+		age--
+	}
+
+	return age, nil
+}
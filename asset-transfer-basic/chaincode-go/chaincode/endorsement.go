@@ -0,0 +1,164 @@
+package chaincode
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/hyperledger/fabric-contract-api-go/v2/contractapi"
+	"github.com/hyperledger/fabric-protos-go-apiv2/common"
+	"github.com/hyperledger/fabric-protos-go-apiv2/msp"
+	"google.golang.org/protobuf/proto"
+)
+
+const resultDocType = "result"
+
+// SetElectionEndorsementPolicy changes the organizations whose peers must
+// endorse updates to the election and its certified result. The quorum must
+// be a majority of the organizations and at most all of them. Because the
+// election key is itself protected, the change has to satisfy the policy that
+// is currently in force.
+func (c *ElectionContract) SetElectionEndorsementPolicy(ctx *ElectionContext, electionID string, organizations []string, quorum int) error {
+	err := ctx.AssertAdmin()
+	if err != nil {
+		return err
+	}
+
+	election, err := ctx.ReadElection(electionID)
+	if err != nil {
+		return err
+	}
+
+	if len(organizations) == 0 {
+		return fmt.Errorf("organizations cannot be empty")
+	}
+	organizations, err = normalizeOrganizations(organizations, quorum)
+	if err != nil {
+		return err
+	}
+	if quorum < 1 {
+		return fmt.Errorf("quorum must be between 1 and %d", len(organizations))
+	}
+
+	election.Organizations = organizations
+	election.EndorsementQuorum = quorum
+
+	err = putElection(ctx, election)
+	if err != nil {
+		return err
+	}
+
+	return applyElectionEndorsementPolicy(ctx, election)
+}
+
+func normalizeOrganizations(organizations []string, quorum int) ([]string, error) {
+	seen := make(map[string]bool)
+	var normalized []string
+	for _, org := range organizations {
+		if org == "" {
+			return nil, fmt.Errorf("organization MSP ID cannot be empty")
+		}
+		if !seen[org] {
+			seen[org] = true
+			normalized = append(normalized, org)
+		}
+	}
+	sort.Strings(normalized)
+
+	if quorum < 0 || quorum > len(normalized) {
+		return nil, fmt.Errorf("quorum must be between 0 and %d", len(normalized))
+	}
+	if quorum > 0 && quorum*2 <= len(normalized) {
+		return nil, fmt.Errorf("quorum %d is not a majority of %d organizations", quorum, len(normalized))
+	}
+
+	return normalized, nil
+}
+
+// applyElectionEndorsementPolicy sets the key-level endorsement policy of the
+// election and, once it exists, of its certified result. Elections without
+// organizations fall back to the chaincode endorsement policy.
+func applyElectionEndorsementPolicy(ctx contractapi.TransactionContextInterface, election *Election) error {
+	if len(election.Organizations) == 0 {
+		return nil
+	}
+
+	policy, err := endorsementPolicy(election.Organizations, election.EndorsementQuorum)
+	if err != nil {
+		return err
+	}
+
+	key, err := electionKey(ctx, election.ID)
+	if err != nil {
+		return err
+	}
+	err = ctx.GetStub().SetStateValidationParameter(key, policy)
+	if err != nil {
+		return fmt.Errorf("failed to set election endorsement policy: %v", err)
+	}
+
+	key, err = resultKey(ctx, election.ID)
+	if err != nil {
+		return err
+	}
+	resultJSON, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return fmt.Errorf("failed to read result: %v", err)
+	}
+	if resultJSON == nil {
+		return nil
+	}
+	err = ctx.GetStub().SetStateValidationParameter(key, policy)
+	if err != nil {
+		return fmt.Errorf("failed to set result endorsement policy: %v", err)
+	}
+
+	return nil
+}
+
+// endorsementPolicy builds a signature policy requiring quorum peers out of
+// organizations, or all of them when quorum is zero.
+func endorsementPolicy(organizations []string, quorum int) ([]byte, error) {
+	if quorum == 0 {
+		quorum = len(organizations)
+	}
+
+	var identities []*msp.MSPPrincipal
+	var rules []*common.SignaturePolicy
+	for i, org := range organizations {
+		role, err := proto.Marshal(&msp.MSPRole{MspIdentifier: org, Role: msp.MSPRole_PEER})
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal role for %s: %v", org, err)
+		}
+		identities = append(identities, &msp.MSPPrincipal{
+			PrincipalClassification: msp.MSPPrincipal_ROLE,
+			Principal:               role,
+		})
+		rules = append(rules, &common.SignaturePolicy{
+			Type: &common.SignaturePolicy_SignedBy{SignedBy: int32(i)},
+		})
+	}
+
+	policy, err := proto.Marshal(&common.SignaturePolicyEnvelope{
+		Version: 0,
+		Rule: &common.SignaturePolicy{
+			Type: &common.SignaturePolicy_NOutOf_{
+				NOutOf: &common.SignaturePolicy_NOutOf{N: int32(quorum), Rules: rules},
+			},
+		},
+		Identities: identities,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal endorsement policy: %v", err)
+	}
+
+	return policy, nil
+}
+
+func resultKey(ctx contractapi.TransactionContextInterface, electionID string) (string, error) {
+	key, err := ctx.GetStub().CreateCompositeKey(resultDocType, []string{electionID})
+	if err != nil {
+		return "", fmt.Errorf("failed to create result key: %v", err)
+	}
+
+	return key, nil
+}
@@ -0,0 +1,518 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/hyperledger/fabric-contract-api-go/v2/contractapi"
+)
+
+const (
+	constituencyDocType = "constituency"
+	precinctDocType     = "precinct"
+)
+
+// Constituency is an electoral district. Contests scoped to a constituency
+// are only on the ballot of voters registered in one of its precincts.
+type Constituency struct {
+	DocType       string `json:"docType"`
+	SchemaVersion int    `json:"schemaVersion"`
+	ID            string `json:"id"`
+	Name          string `json:"name"`
+}
+
+// Precinct is the polling district a voter is registered in.
+type Precinct struct {
+	DocType        string `json:"docType"`
+	SchemaVersion  int    `json:"schemaVersion"`
+	ID             string `json:"id"`
+	Name           string `json:"name"`
+	ConstituencyID string `json:"constituencyID"`
+}
+
+// DistrictTally is the tally of the ballots cast in one constituency, or in
+// one of its precincts when PrecinctID is set.
+type DistrictTally struct {
+	ConstituencyID string            `json:"constituencyID"`
+	PrecinctID     string            `json:"precinctID,omitempty" metadata:",optional"`
+	Tally          []*CandidateTally `json:"tally,omitempty" metadata:",optional"`
+}
+
+// DistrictResults break the votes of an election down by constituency and
+// by precinct. Ballots of voters without a precinct are reported under an
+// empty constituency.
+type DistrictResults struct {
+	ElectionID     string           `json:"electionID"`
+	Constituencies []*DistrictTally `json:"constituencies,omitempty" metadata:",optional"`
+	Precincts      []*DistrictTally `json:"precincts,omitempty" metadata:",optional"`
+}
+
+func (c *RegistryContract) CreateConstituency(ctx *RegistryContext, constituencyID string, name string) error {
+	err := ctx.AssertAdmin()
+	if err != nil {
+		return err
+	}
+	if len(constituencyID) == 0 {
+		return fmt.Errorf("constituencyID cannot be empty")
+	}
+
+	existing, err := getConstituency(ctx, constituencyID)
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		return fmt.Errorf("constituency %s already exists", constituencyID)
+	}
+
+	constituency := &Constituency{
+		DocType:       constituencyDocType,
+		SchemaVersion: currentSchemaVersion,
+		ID:            constituencyID,
+		Name:          name,
+	}
+
+	return putGeographyAsset(ctx, constituencyDocType, constituencyID, constituency)
+}
+
+func (c *RegistryContract) CreatePrecinct(ctx *RegistryContext, precinctID string, name string, constituencyID string) error {
+	err := ctx.AssertAdmin()
+	if err != nil {
+		return err
+	}
+	if len(precinctID) == 0 {
+		return fmt.Errorf("precinctID cannot be empty")
+	}
+
+	existing, err := getPrecinct(ctx, precinctID)
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		return fmt.Errorf("precinct %s already exists", precinctID)
+	}
+	constituency, err := getConstituency(ctx, constituencyID)
+	if err != nil {
+		return err
+	}
+	if constituency == nil {
+		return fmt.Errorf("constituency %s does not exist", constituencyID)
+	}
+
+	precinct := &Precinct{
+		DocType:        precinctDocType,
+		SchemaVersion:  currentSchemaVersion,
+		ID:             precinctID,
+		Name:           name,
+		ConstituencyID: constituencyID,
+	}
+
+	return putGeographyAsset(ctx, precinctDocType, precinctID, precinct)
+}
+
+func (c *RegistryContract) GetConstituencies(ctx *RegistryContext) ([]*Constituency, error) {
+	values, err := getPartialCompositeKeyValues(ctx, constituencyDocType, []string{})
+	if err != nil {
+		return nil, err
+	}
+
+	constituencies := make([]*Constituency, 0, len(values))
+	for _, value := range values {
+		var constituency Constituency
+		err = unmarshalAsset(value, &constituency)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal constituency: %v", err)
+		}
+		constituencies = append(constituencies, &constituency)
+	}
+
+	return constituencies, nil
+}
+
+// GetPrecincts returns the precincts of a constituency, or every precinct if
+// constituencyID is empty.
+func (c *RegistryContract) GetPrecincts(ctx *RegistryContext, constituencyID string) ([]*Precinct, error) {
+	values, err := getPartialCompositeKeyValues(ctx, precinctDocType, []string{})
+	if err != nil {
+		return nil, err
+	}
+
+	precincts := make([]*Precinct, 0, len(values))
+	for _, value := range values {
+		var precinct Precinct
+		err = unmarshalAsset(value, &precinct)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal precinct: %v", err)
+		}
+		if constituencyID == "" || precinct.ConstituencyID == constituencyID {
+			precincts = append(precincts, &precinct)
+		}
+	}
+
+	return precincts, nil
+}
+
+// GetDistrictResults counts the ballots of the election that currently count
+// by the precinct the voter was registered in when casting them, and sums the
+// precincts of each constituency. Votes delegated to another voter are
+// counted in the precinct of the voter who delegated them. Adjudicated
+// write-ins are counted for their candidate, and withdrawn candidates are
+// tallied according to the election's WithdrawnVotes rule, as in GetTally.
+// Results of weighted and delegation elections are only available once the
+// election closes.
+func (c *ElectionContract) GetDistrictResults(ctx *ElectionContext, electionID string) (*DistrictResults, error) {
+	if len(electionID) == 0 {
+		return nil, fmt.Errorf("electionID cannot be empty")
+	}
+
+	election, err := getElection(ctx, electionID)
+	if err != nil {
+		return nil, err
+	}
+	err = assertResultsPublished(election)
+	if err != nil {
+		return nil, err
+	}
+
+	values, err := getPartialCompositeKeyValues(ctx, voteDocType, []string{electionID})
+	if err != nil {
+		return nil, err
+	}
+
+	count := newDistrictCount(ctx, electionID)
+	for _, value := range values {
+		var vote Vote
+		err = unmarshalAsset(value, &vote)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal vote: %v", err)
+		}
+		if vote.SupersededBy != "" {
+			continue
+		}
+
+		weight, err := voteWeight(ctx, election, &vote)
+		if err != nil {
+			return nil, err
+		}
+		err = count.add(&vote, vote.PrecinctID, weight)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if election != nil && election.Rules.AllowDelegation {
+		err = countDelegatedVotes(ctx, election, count)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(candidateElectionIndex, []string{electionID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read candidate index: %v", err)
+	}
+	defer resultsIterator.Close()
+
+	candidates, err := candidatesFromIndex(ctx, resultsIterator)
+	if err != nil {
+		return nil, err
+	}
+	withdrawn := make(map[string]bool)
+	for _, candidate := range candidates {
+		withdrawn[candidate.ID] = candidate.Status == CandidateStatusWithdrawn
+	}
+	policy := withdrawnVotesPolicy(election)
+
+	byConstituency := make(map[string]map[string]int)
+	for precinctID, votes := range count.byPrecinct {
+		for candidateID, n := range votes {
+			addDistrictVotes(byConstituency, count.constituencyOf[precinctID], candidateID, n)
+		}
+	}
+
+	// A district whose only ballots were cast on behalf of voters elsewhere
+	// is left out.
+	results := &DistrictResults{ElectionID: electionID}
+	for _, constituencyID := range sortedKeys(byConstituency) {
+		tally := districtTally(byConstituency[constituencyID], withdrawn, policy)
+		if len(tally) > 0 {
+			results.Constituencies = append(results.Constituencies, &DistrictTally{
+				ConstituencyID: constituencyID,
+				Tally:          tally,
+			})
+		}
+	}
+	for _, precinctID := range sortedKeys(count.byPrecinct) {
+		tally := districtTally(count.byPrecinct[precinctID], withdrawn, policy)
+		if len(tally) > 0 {
+			results.Precincts = append(results.Precincts, &DistrictTally{
+				ConstituencyID: count.constituencyOf[precinctID],
+				PrecinctID:     precinctID,
+				Tally:          tally,
+			})
+		}
+	}
+
+	return results, nil
+}
+
+// countDelegatedVotes moves the votes of each voter who delegated their vote
+// and did not vote themselves from the precinct of the ballot cast on their
+// behalf to their own precinct.
+func countDelegatedVotes(ctx contractapi.TransactionContextInterface, election *Election, count *districtCount) error {
+	values, err := getPartialCompositeKeyValues(ctx, delegationDocType, []string{election.ID})
+	if err != nil {
+		return err
+	}
+
+	for _, value := range values {
+		var delegation Delegation
+		err = unmarshalAsset(value, &delegation)
+		if err != nil {
+			return fmt.Errorf("failed to unmarshal delegation: %v", err)
+		}
+
+		own, err := getVoterVote(ctx, election.ID, delegation.From)
+		if err != nil {
+			return err
+		}
+		if own != nil {
+			continue
+		}
+		vote, err := delegateVote(ctx, election, delegation.To, maxDelegationDepth(election))
+		if err != nil {
+			return err
+		}
+		if vote == nil {
+			continue
+		}
+
+		voter, err := readVoter(ctx, delegation.From)
+		if err != nil {
+			return err
+		}
+		weight, err := registeredWeight(ctx, election, delegation.From)
+		if err != nil {
+			return err
+		}
+		err = count.add(vote, vote.PrecinctID, -weight)
+		if err != nil {
+			return err
+		}
+		err = count.add(vote, voter.PrecinctID, weight)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// districtCount accumulates the votes of an election by precinct.
+type districtCount struct {
+	ctx               contractapi.TransactionContextInterface
+	electionID        string
+	constituencyOf    map[string]string
+	writeInCandidates map[string]string
+	byPrecinct        map[string]map[string]int
+}
+
+func newDistrictCount(ctx contractapi.TransactionContextInterface, electionID string) *districtCount {
+	return &districtCount{
+		ctx:               ctx,
+		electionID:        electionID,
+		constituencyOf:    map[string]string{"": ""},
+		writeInCandidates: make(map[string]string),
+		byPrecinct:        make(map[string]map[string]int),
+	}
+}
+
+// add counts weight votes for each candidate of vote in precinctID.
+func (d *districtCount) add(vote *Vote, precinctID string, weight int) error {
+	if _, ok := d.constituencyOf[precinctID]; !ok {
+		precinct, err := getPrecinct(d.ctx, precinctID)
+		if err != nil {
+			return err
+		}
+		d.constituencyOf[precinctID] = ""
+		if precinct != nil {
+			d.constituencyOf[precinctID] = precinct.ConstituencyID
+		}
+	}
+
+	for _, target := range targetsOf(vote) {
+		candidateID := target.candidateID
+		if target.writeIn != "" {
+			var ok bool
+			candidateID, ok = d.writeInCandidates[target.writeIn]
+			if !ok {
+				var err error
+				candidateID, err = writeInCandidate(d.ctx, d.electionID, target.writeIn)
+				if err != nil {
+					return err
+				}
+				d.writeInCandidates[target.writeIn] = candidateID
+			}
+		}
+
+		addDistrictVotes(d.byPrecinct, precinctID, candidateID, weight)
+	}
+
+	return nil
+}
+
+// voterConstituency returns the constituency of the voter's precinct, or an
+// empty string for voters registered without a precinct.
+func voterConstituency(ctx contractapi.TransactionContextInterface, voter *Voter) (string, error) {
+	if voter.PrecinctID == "" {
+		return "", nil
+	}
+
+	precinct, err := getPrecinct(ctx, voter.PrecinctID)
+	if err != nil {
+		return "", err
+	}
+	if precinct == nil {
+		return "", fmt.Errorf("precinct %s of voter %s does not exist", voter.PrecinctID, voter.ID)
+	}
+
+	return precinct.ConstituencyID, nil
+}
+
+// assertPrecinctExists checks the precinct a voter is registered in. An empty
+// precinctID registers the voter without one.
+func assertPrecinctExists(ctx contractapi.TransactionContextInterface, precinctID string) error {
+	if precinctID == "" {
+		return nil
+	}
+
+	precinct, err := getPrecinct(ctx, precinctID)
+	if err != nil {
+		return err
+	}
+	if precinct == nil {
+		return fmt.Errorf("precinct %s does not exist", precinctID)
+	}
+
+	return nil
+}
+
+// inDistrict reports whether a voter of constituencyID can vote in contest.
+func inDistrict(contest *BallotContest, constituencyID string) bool {
+	return contest.ConstituencyID == "" || contest.ConstituencyID == constituencyID
+}
+
+// writeInCandidate returns the candidate a write-in name was adjudicated to,
+// or WriteInTallyID while it is not adjudicated.
+func writeInCandidate(ctx contractapi.TransactionContextInterface, electionID string, name string) (string, error) {
+	writeIn, err := getWriteIn(ctx, electionID, name)
+	if err != nil {
+		return "", err
+	}
+	if writeIn == nil || len(writeIn.Adjudications) == 0 {
+		return WriteInTallyID, nil
+	}
+
+	return writeIn.Adjudications[len(writeIn.Adjudications)-1].CandidateID, nil
+}
+
+func addDistrictVotes(districts map[string]map[string]int, districtID string, candidateID string, votes int) {
+	if districts[districtID] == nil {
+		districts[districtID] = make(map[string]int)
+	}
+	districts[districtID][candidateID] += votes
+}
+
+// districtTally orders the votes of a district like GetTally: candidates by
+// ID, then the write-ins awaiting adjudication, then withdrawn candidates
+// reported separately.
+func districtTally(votes map[string]int, withdrawn map[string]bool, policy string) []*CandidateTally {
+	tally := make([]*CandidateTally, 0, len(votes))
+	var separate []*CandidateTally
+	for _, candidateID := range sortedKeys(votes) {
+		if votes[candidateID] == 0 || candidateID == WriteInTallyID {
+			continue
+		}
+		entry := &CandidateTally{CandidateID: candidateID, Votes: votes[candidateID]}
+		if applyWithdrawnVotes(policy, entry, withdrawn[candidateID]) {
+			separate = append(separate, entry)
+		} else {
+			tally = append(tally, entry)
+		}
+	}
+	if votes[WriteInTallyID] > 0 {
+		tally = append(tally, &CandidateTally{CandidateID: WriteInTallyID, Votes: votes[WriteInTallyID]})
+	}
+
+	return append(tally, separate...)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+func getConstituency(ctx contractapi.TransactionContextInterface, constituencyID string) (*Constituency, error) {
+	var constituency Constituency
+	found, err := getGeographyAsset(ctx, constituencyDocType, constituencyID, &constituency)
+	if err != nil || !found {
+		return nil, err
+	}
+
+	return &constituency, nil
+}
+
+func getPrecinct(ctx contractapi.TransactionContextInterface, precinctID string) (*Precinct, error) {
+	var precinct Precinct
+	found, err := getGeographyAsset(ctx, precinctDocType, precinctID, &precinct)
+	if err != nil || !found {
+		return nil, err
+	}
+
+	return &precinct, nil
+}
+
+func getGeographyAsset(ctx contractapi.TransactionContextInterface, docType string, id string, asset interface{}) (bool, error) {
+	key, err := ctx.GetStub().CreateCompositeKey(docType, []string{id})
+	if err != nil {
+		return false, fmt.Errorf("failed to create %s key: %v", docType, err)
+	}
+
+	assetJSON, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return false, fmt.Errorf("failed to read %s: %v", docType, err)
+	}
+	if assetJSON == nil {
+		return false, nil
+	}
+
+	err = unmarshalAsset(assetJSON, asset)
+	if err != nil {
+		return false, fmt.Errorf("failed to unmarshal %s: %v", docType, err)
+	}
+
+	return true, nil
+}
+
+func putGeographyAsset(ctx contractapi.TransactionContextInterface, docType string, id string, asset interface{}) error {
+	key, err := ctx.GetStub().CreateCompositeKey(docType, []string{id})
+	if err != nil {
+		return fmt.Errorf("failed to create %s key: %v", docType, err)
+	}
+
+	assetJSON, err := json.Marshal(asset)
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s: %v", docType, err)
+	}
+	err = ctx.GetStub().PutState(key, assetJSON)
+	if err != nil {
+		return fmt.Errorf("failed to put %s: %v", docType, err)
+	}
+
+	return nil
+}
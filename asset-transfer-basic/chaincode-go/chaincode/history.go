@@ -0,0 +1,159 @@
+package chaincode
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/v2/contractapi"
+)
+
+// VoterHistoryRecord is a single modification of a voter record. Record is
+// nil when the modification deleted the voter.
+type VoterHistoryRecord struct {
+	TxID      string    `json:"txId"`
+	Timestamp time.Time `json:"timestamp"`
+	IsDelete  bool      `json:"isDelete"`
+	Record    *Voter    `json:"record"`
+}
+
+// CandidateHistoryRecord is a single modification of a candidate record.
+type CandidateHistoryRecord struct {
+	TxID      string     `json:"txId"`
+	Timestamp time.Time  `json:"timestamp"`
+	IsDelete  bool       `json:"isDelete"`
+	Record    *Candidate `json:"record"`
+}
+
+// ElectionHistoryRecord is a single modification of an election record.
+type ElectionHistoryRecord struct {
+	TxID      string    `json:"txId"`
+	Timestamp time.Time `json:"timestamp"`
+	IsDelete  bool      `json:"isDelete"`
+	Record    *Election `json:"record"`
+}
+
+type keyModification struct {
+	txID      string
+	timestamp time.Time
+	isDelete  bool
+	value     []byte
+}
+
+func (c *RegistryContract) GetVoterHistory(ctx *RegistryContext, voterID string) ([]*VoterHistoryRecord, error) {
+	modifications, err := getKeyHistory(ctx, voterID)
+	if err != nil {
+		return nil, err
+	}
+
+	var records []*VoterHistoryRecord
+	for _, modification := range modifications {
+		record := &VoterHistoryRecord{
+			TxID:      modification.txID,
+			Timestamp: modification.timestamp,
+			IsDelete:  modification.isDelete,
+		}
+		if len(modification.value) > 0 {
+			var voter Voter
+			err = unmarshalAsset(modification.value, &voter)
+			if err != nil {
+				return nil, fmt.Errorf("failed to unmarshal voter: %v", err)
+			}
+			record.Record = &voter
+		}
+		records = append(records, record)
+	}
+
+	return records, nil
+}
+
+func (c *RegistryContract) GetCandidateHistory(ctx *RegistryContext, candidateID string) ([]*CandidateHistoryRecord, error) {
+	modifications, err := getKeyHistory(ctx, candidateID)
+	if err != nil {
+		return nil, err
+	}
+
+	var records []*CandidateHistoryRecord
+	for _, modification := range modifications {
+		record := &CandidateHistoryRecord{
+			TxID:      modification.txID,
+			Timestamp: modification.timestamp,
+			IsDelete:  modification.isDelete,
+		}
+		if len(modification.value) > 0 {
+			var candidate Candidate
+			err = unmarshalAsset(modification.value, &candidate)
+			if err != nil {
+				return nil, fmt.Errorf("failed to unmarshal candidate: %v", err)
+			}
+			record.Record = &candidate
+		}
+		records = append(records, record)
+	}
+
+	return records, nil
+}
+
+func (c *ElectionContract) GetElectionHistory(ctx *ElectionContext, electionID string) ([]*ElectionHistoryRecord, error) {
+	key, err := electionKey(ctx, electionID)
+	if err != nil {
+		return nil, err
+	}
+
+	modifications, err := getKeyHistory(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	var records []*ElectionHistoryRecord
+	for _, modification := range modifications {
+		record := &ElectionHistoryRecord{
+			TxID:      modification.txID,
+			Timestamp: modification.timestamp,
+			IsDelete:  modification.isDelete,
+		}
+		if len(modification.value) > 0 {
+			var election Election
+			err = unmarshalAsset(modification.value, &election)
+			if err != nil {
+				return nil, fmt.Errorf("failed to unmarshal election: %v", err)
+			}
+			record.Record = &election
+		}
+		records = append(records, record)
+	}
+
+	return records, nil
+}
+
+func getKeyHistory(ctx contractapi.TransactionContextInterface, key string) ([]keyModification, error) {
+	if len(key) == 0 {
+		return nil, fmt.Errorf("key cannot be empty")
+	}
+
+	resultsIterator, err := ctx.GetStub().GetHistoryForKey(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read history: %v", err)
+	}
+	defer resultsIterator.Close()
+
+	var modifications []keyModification
+	for resultsIterator.HasNext() {
+		response, err := resultsIterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("failed to iterate through history: %v", err)
+		}
+
+		var timestamp time.Time
+		if response.Timestamp != nil {
+			timestamp = response.Timestamp.AsTime()
+		}
+		modifications = append(modifications, keyModification{
+			txID:      response.TxId,
+			timestamp: timestamp,
+			isDelete:  response.IsDelete,
+			value:     response.Value,
+		})
+	}
+
+	return modifications, nil
+}
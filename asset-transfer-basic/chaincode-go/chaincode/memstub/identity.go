@@ -0,0 +1,71 @@
+package memstub
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/hyperledger/fabric-chaincode-go/v2/pkg/attrmgr"
+	"github.com/hyperledger/fabric-protos-go-apiv2/msp"
+	"google.golang.org/protobuf/proto"
+)
+
+// Identity is a client identity submitting transactions to a Stub.
+type Identity struct {
+	MSPID string
+	// Certificate is the PEM encoded X.509 certificate of the client.
+	Certificate []byte
+}
+
+// NewIdentity returns an identity of mspID with a self-signed certificate
+// for subject. Attributes are embedded the way the Fabric CA issues them, so
+// that they can be read with the cid package, for example role=admin.
+func NewIdentity(mspID string, subject pkix.Name, attributes map[string]string) (*Identity, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate key: %v", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      subject,
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(24 * 365 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+	if len(attributes) > 0 {
+		err = attrmgr.New().AddAttributesToCert(&attrmgr.Attributes{Attrs: attributes}, template)
+		if err != nil {
+			return nil, fmt.Errorf("failed to add attributes to certificate: %v", err)
+		}
+		// x509 only writes the extensions of a template listed as extra.
+		template.ExtraExtensions = template.Extensions
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create certificate: %v", err)
+	}
+
+	return &Identity{
+		MSPID:       mspID,
+		Certificate: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+	}, nil
+}
+
+// Serialize returns the identity as a peer passes it to chaincode in the
+// transaction creator.
+func (id *Identity) Serialize() ([]byte, error) {
+	creator, err := proto.Marshal(&msp.SerializedIdentity{Mspid: id.MSPID, IdBytes: id.Certificate})
+	if err != nil {
+		return nil, fmt.Errorf("failed to serialize identity: %v", err)
+	}
+
+	return creator, nil
+}
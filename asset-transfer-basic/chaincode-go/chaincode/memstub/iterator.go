@@ -0,0 +1,101 @@
+package memstub
+
+import (
+	"errors"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/hyperledger/fabric-protos-go-apiv2/ledger/queryresult"
+	"github.com/hyperledger/fabric-protos-go-apiv2/peer"
+)
+
+const (
+	// compositeKeyNamespace starts every composite key.
+	compositeKeyNamespace = "\x00"
+	// emptyKeySubstitute replaces an empty start key, so that range queries
+	// over simple keys never return composite keys.
+	emptyKeySubstitute = "\x01"
+	maxUnicodeRune     = string(utf8.MaxRune)
+)
+
+var errIteratorExhausted = errors.New("no more results")
+
+// rangeQuery returns the keys of state in [startKey, endKey), or from
+// startKey on when endKey is empty. A non-empty bookmark replaces startKey,
+// and a positive pageSize limits the number of keys returned.
+func (s *Stub) rangeQuery(state map[string][]byte, startKey, endKey string, pageSize int32, bookmark string) (*stateIterator, *peer.QueryResponseMetadata, error) {
+	if bookmark != "" {
+		if bookmark < startKey || (endKey != "" && bookmark >= endKey) {
+			return nil, nil, errors.New("bookmark is outside the queried range")
+		}
+		startKey = bookmark
+	}
+
+	var results []*queryresult.KV
+	next := ""
+	for _, key := range sortedKeys(state) {
+		if key < startKey || (endKey != "" && key >= endKey) {
+			continue
+		}
+		if pageSize > 0 && len(results) == int(pageSize) {
+			next = key
+			break
+		}
+		results = append(results, &queryresult.KV{Key: key, Value: state[key]})
+	}
+
+	metadata := &peer.QueryResponseMetadata{
+		FetchedRecordsCount: int32(len(results)),
+		Bookmark:            next,
+	}
+
+	return &stateIterator{results: results}, metadata, nil
+}
+
+func isCompositeKey(key string) bool {
+	return strings.HasPrefix(key, compositeKeyNamespace)
+}
+
+type stateIterator struct {
+	results []*queryresult.KV
+}
+
+func (it *stateIterator) HasNext() bool {
+	return len(it.results) > 0
+}
+
+func (it *stateIterator) Next() (*queryresult.KV, error) {
+	if len(it.results) == 0 {
+		return nil, errIteratorExhausted
+	}
+	result := it.results[0]
+	it.results = it.results[1:]
+	return result, nil
+}
+
+func (it *stateIterator) Close() error {
+	it.results = nil
+	return nil
+}
+
+type historyIterator struct {
+	results []*queryresult.KeyModification
+}
+
+func (it *historyIterator) HasNext() bool {
+	return len(it.results) > 0
+}
+
+func (it *historyIterator) Next() (*queryresult.KeyModification, error) {
+	if len(it.results) == 0 {
+		return nil, errIteratorExhausted
+	}
+	result := it.results[0]
+	it.results = it.results[1:]
+	return result, nil
+}
+
+func (it *historyIterator) Close() error {
+	it.results = nil
+	return nil
+}
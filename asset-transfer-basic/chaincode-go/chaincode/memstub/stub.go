@@ -0,0 +1,509 @@
+// Package memstub provides an in-memory implementation of
+// shim.ChaincodeStubInterface, so that contracts can be exercised end to end
+// without a peer.
+//
+// A chaincode is run one transaction at a time with Invoke:
+//
+//	cc, _ := chaincode.NewChaincode()
+//	stub := memstub.New("mychannel")
+//	admin, _ := memstub.NewIdentity("Org1MSP", pkix.Name{CommonName: "registrar"}, map[string]string{"role": "admin"})
+//	stub.SetIdentity(admin)
+//	response := stub.Invoke(cc, "election:CreateElection", "e1", "Local elections", `["Org1MSP"]`)
+//
+// Contract functions can also be called directly between StartTransaction
+// and Commit, with the context returned by TransactionContext.
+package memstub
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/hyperledger/fabric-chaincode-go/v2/pkg/cid"
+	"github.com/hyperledger/fabric-chaincode-go/v2/shim"
+	"github.com/hyperledger/fabric-contract-api-go/v2/contractapi"
+	"github.com/hyperledger/fabric-protos-go-apiv2/ledger/queryresult"
+	"github.com/hyperledger/fabric-protos-go-apiv2/peer"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// errRichQuery is the error a peer backed by LevelDB returns for CouchDB
+// selector queries.
+var errRichQuery = errors.New("ExecuteQuery not supported for leveldb")
+
+var errNoTransaction = errors.New("no transaction in progress")
+
+// Stub keeps the world state, key history, private data and chaincode events
+// of a single channel in memory.
+//
+// Like on a peer, writes are buffered for the duration of a transaction and
+// only become visible to reads once it is committed. Range queries skip
+// composite keys and rich queries fail as they do on LevelDB.
+type Stub struct {
+	channelID  string
+	state      map[string][]byte
+	validation map[string][]byte
+	private    map[string]map[string][]byte
+	history    map[string][]*queryresult.KeyModification
+	events     []*peer.ChaincodeEvent
+	clock      func() time.Time
+	txCount    int
+
+	creator   []byte
+	transient map[string][]byte
+	tx        *transaction
+}
+
+// transaction holds the arguments and pending writes of the transaction in
+// progress.
+type transaction struct {
+	id         string
+	args       [][]byte
+	timestamp  *timestamppb.Timestamp
+	writes     map[string]*write
+	validation map[string][]byte
+	private    map[string]map[string]*write
+	event      *peer.ChaincodeEvent
+}
+
+type write struct {
+	value   []byte
+	deleted bool
+}
+
+var _ shim.ChaincodeStubInterface = (*Stub)(nil)
+
+// New returns an empty ledger for channelID.
+func New(channelID string) *Stub {
+	return &Stub{
+		channelID:  channelID,
+		state:      make(map[string][]byte),
+		validation: make(map[string][]byte),
+		private:    make(map[string]map[string][]byte),
+		history:    make(map[string][]*queryresult.KeyModification),
+		clock:      time.Now,
+	}
+}
+
+// SetIdentity makes id the submitter of the following transactions.
+func (s *Stub) SetIdentity(id *Identity) error {
+	creator, err := id.Serialize()
+	if err != nil {
+		return err
+	}
+	s.creator = creator
+
+	return nil
+}
+
+// SetTransient sets the transient data passed to the following transactions.
+func (s *Stub) SetTransient(transient map[string][]byte) {
+	s.transient = transient
+}
+
+// SetClock replaces the clock transaction timestamps are taken from.
+func (s *Stub) SetClock(clock func() time.Time) {
+	s.clock = clock
+}
+
+// StartTransaction begins a transaction invoked with args, the function name
+// followed by its parameters. An empty txID is replaced by a generated one.
+func (s *Stub) StartTransaction(txID string, args ...string) error {
+	if s.tx != nil {
+		return fmt.Errorf("transaction %s is still in progress", s.tx.id)
+	}
+
+	s.txCount++
+	if txID == "" {
+		txID = fmt.Sprintf("tx%d", s.txCount)
+	}
+	rawArgs := make([][]byte, 0, len(args))
+	for _, arg := range args {
+		rawArgs = append(rawArgs, []byte(arg))
+	}
+
+	s.tx = &transaction{
+		id:         txID,
+		args:       rawArgs,
+		timestamp:  timestamppb.New(s.clock()),
+		writes:     make(map[string]*write),
+		validation: make(map[string][]byte),
+		private:    make(map[string]map[string]*write),
+	}
+
+	return nil
+}
+
+// Commit applies the writes and the event of the transaction in progress.
+func (s *Stub) Commit() error {
+	if s.tx == nil {
+		return errNoTransaction
+	}
+	tx := s.tx
+	s.tx = nil
+
+	for _, key := range sortedKeys(tx.writes) {
+		w := tx.writes[key]
+		if w.deleted {
+			delete(s.state, key)
+		} else {
+			s.state[key] = w.value
+		}
+		s.history[key] = append(s.history[key], &queryresult.KeyModification{
+			TxId:      tx.id,
+			Value:     w.value,
+			Timestamp: tx.timestamp,
+			IsDelete:  w.deleted,
+		})
+	}
+	for key, ep := range tx.validation {
+		s.validation[key] = ep
+	}
+	for collection, writes := range tx.private {
+		if s.private[collection] == nil {
+			s.private[collection] = make(map[string][]byte)
+		}
+		for key, w := range writes {
+			if w.deleted {
+				delete(s.private[collection], key)
+			} else {
+				s.private[collection][key] = w.value
+			}
+		}
+	}
+	if tx.event != nil {
+		s.events = append(s.events, tx.event)
+	}
+
+	return nil
+}
+
+// Rollback discards the transaction in progress.
+func (s *Stub) Rollback() {
+	s.tx = nil
+}
+
+// Invoke runs one transaction of cc, committing it when cc succeeds and
+// discarding it otherwise.
+func (s *Stub) Invoke(cc shim.Chaincode, args ...string) *peer.Response {
+	err := s.StartTransaction("", args...)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	response := cc.Invoke(s)
+	if response.Status >= shim.ERRORTHRESHOLD {
+		s.Rollback()
+		return response
+	}
+	err = s.Commit()
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	return response
+}
+
+// TransactionContext returns a context for calling contract functions
+// directly within the transaction in progress.
+func (s *Stub) TransactionContext() (*contractapi.TransactionContext, error) {
+	identity, err := cid.New(s)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read client identity: %v", err)
+	}
+
+	ctx := new(contractapi.TransactionContext)
+	ctx.SetStub(s)
+	ctx.SetClientIdentity(identity)
+
+	return ctx, nil
+}
+
+// Events returns the events of the committed transactions, oldest first.
+func (s *Stub) Events() []*peer.ChaincodeEvent {
+	return s.events
+}
+
+func (s *Stub) GetArgs() [][]byte {
+	if s.tx == nil {
+		return nil
+	}
+	return s.tx.args
+}
+
+func (s *Stub) GetStringArgs() []string {
+	args := make([]string, 0, len(s.GetArgs()))
+	for _, arg := range s.GetArgs() {
+		args = append(args, string(arg))
+	}
+	return args
+}
+
+func (s *Stub) GetFunctionAndParameters() (string, []string) {
+	args := s.GetStringArgs()
+	if len(args) == 0 {
+		return "", []string{}
+	}
+	return args[0], args[1:]
+}
+
+func (s *Stub) GetArgsSlice() ([]byte, error) {
+	return bytes.Join(s.GetArgs(), nil), nil
+}
+
+func (s *Stub) GetTxID() string {
+	if s.tx == nil {
+		return ""
+	}
+	return s.tx.id
+}
+
+func (s *Stub) GetChannelID() string {
+	return s.channelID
+}
+
+func (s *Stub) InvokeChaincode(chaincodeName string, args [][]byte, channel string) *peer.Response {
+	return shim.Error(fmt.Sprintf("cannot invoke chaincode %s: chaincode to chaincode calls are not supported", chaincodeName))
+}
+
+func (s *Stub) GetState(key string) ([]byte, error) {
+	return s.state[key], nil
+}
+
+func (s *Stub) PutState(key string, value []byte) error {
+	if s.tx == nil {
+		return errNoTransaction
+	}
+	if key == "" {
+		return errors.New("key must not be an empty string")
+	}
+	s.tx.writes[key] = &write{value: value}
+	return nil
+}
+
+func (s *Stub) DelState(key string) error {
+	if s.tx == nil {
+		return errNoTransaction
+	}
+	s.tx.writes[key] = &write{deleted: true}
+	return nil
+}
+
+func (s *Stub) SetStateValidationParameter(key string, ep []byte) error {
+	if s.tx == nil {
+		return errNoTransaction
+	}
+	s.tx.validation[key] = ep
+	return nil
+}
+
+func (s *Stub) GetStateValidationParameter(key string) ([]byte, error) {
+	return s.validation[key], nil
+}
+
+func (s *Stub) GetStateByRange(startKey, endKey string) (shim.StateQueryIteratorInterface, error) {
+	iterator, _, err := s.GetStateByRangeWithPagination(startKey, endKey, 0, "")
+	return iterator, err
+}
+
+// GetStateByRangeWithPagination returns at most pageSize keys, or all of them
+// when pageSize is 0. The bookmark is the first key of the next page.
+func (s *Stub) GetStateByRangeWithPagination(startKey, endKey string, pageSize int32, bookmark string) (shim.StateQueryIteratorInterface, *peer.QueryResponseMetadata, error) {
+	if isCompositeKey(startKey) || isCompositeKey(endKey) {
+		return nil, nil, errors.New("range query keys cannot be composite keys")
+	}
+	if startKey == "" {
+		startKey = emptyKeySubstitute
+	}
+
+	return s.rangeQuery(s.state, startKey, endKey, pageSize, bookmark)
+}
+
+func (s *Stub) GetStateByPartialCompositeKey(objectType string, keys []string) (shim.StateQueryIteratorInterface, error) {
+	iterator, _, err := s.GetStateByPartialCompositeKeyWithPagination(objectType, keys, 0, "")
+	return iterator, err
+}
+
+func (s *Stub) GetStateByPartialCompositeKeyWithPagination(objectType string, keys []string, pageSize int32, bookmark string) (shim.StateQueryIteratorInterface, *peer.QueryResponseMetadata, error) {
+	startKey, err := shim.CreateCompositeKey(objectType, keys)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return s.rangeQuery(s.state, startKey, startKey+maxUnicodeRune, pageSize, bookmark)
+}
+
+func (s *Stub) CreateCompositeKey(objectType string, attributes []string) (string, error) {
+	return shim.CreateCompositeKey(objectType, attributes)
+}
+
+func (s *Stub) SplitCompositeKey(compositeKey string) (string, []string, error) {
+	if !isCompositeKey(compositeKey) {
+		return "", nil, fmt.Errorf("%q is not a composite key", compositeKey)
+	}
+
+	var components []string
+	start := 1
+	for i := 1; i < len(compositeKey); i++ {
+		if compositeKey[i] == 0 {
+			components = append(components, compositeKey[start:i])
+			start = i + 1
+		}
+	}
+	if len(components) == 0 {
+		return "", nil, fmt.Errorf("%q is not a composite key", compositeKey)
+	}
+
+	return components[0], components[1:], nil
+}
+
+func (s *Stub) GetQueryResult(query string) (shim.StateQueryIteratorInterface, error) {
+	return nil, errRichQuery
+}
+
+func (s *Stub) GetQueryResultWithPagination(query string, pageSize int32, bookmark string) (shim.StateQueryIteratorInterface, *peer.QueryResponseMetadata, error) {
+	return nil, nil, errRichQuery
+}
+
+// GetHistoryForKey returns the committed modifications of key, newest first
+// as on a Fabric 2 peer.
+func (s *Stub) GetHistoryForKey(key string) (shim.HistoryQueryIteratorInterface, error) {
+	modifications := s.history[key]
+	results := make([]*queryresult.KeyModification, 0, len(modifications))
+	for i := len(modifications) - 1; i >= 0; i-- {
+		results = append(results, modifications[i])
+	}
+
+	return &historyIterator{results: results}, nil
+}
+
+func (s *Stub) GetPrivateData(collection, key string) ([]byte, error) {
+	return s.private[collection][key], nil
+}
+
+func (s *Stub) GetPrivateDataHash(collection, key string) ([]byte, error) {
+	value := s.private[collection][key]
+	if value == nil {
+		return nil, nil
+	}
+	hash := sha256.Sum256(value)
+	return hash[:], nil
+}
+
+func (s *Stub) PutPrivateData(collection string, key string, value []byte) error {
+	return s.writePrivateData(collection, key, &write{value: value})
+}
+
+func (s *Stub) DelPrivateData(collection, key string) error {
+	return s.writePrivateData(collection, key, &write{deleted: true})
+}
+
+func (s *Stub) PurgePrivateData(collection, key string) error {
+	return s.writePrivateData(collection, key, &write{deleted: true})
+}
+
+func (s *Stub) SetPrivateDataValidationParameter(collection, key string, ep []byte) error {
+	return s.SetStateValidationParameter(privateKey(collection, key), ep)
+}
+
+func (s *Stub) GetPrivateDataValidationParameter(collection, key string) ([]byte, error) {
+	return s.GetStateValidationParameter(privateKey(collection, key))
+}
+
+func (s *Stub) GetPrivateDataByRange(collection, startKey, endKey string) (shim.StateQueryIteratorInterface, error) {
+	if startKey == "" {
+		startKey = emptyKeySubstitute
+	}
+	iterator, _, err := s.rangeQuery(s.private[collection], startKey, endKey, 0, "")
+	return iterator, err
+}
+
+func (s *Stub) GetPrivateDataByPartialCompositeKey(collection, objectType string, keys []string) (shim.StateQueryIteratorInterface, error) {
+	startKey, err := shim.CreateCompositeKey(objectType, keys)
+	if err != nil {
+		return nil, err
+	}
+	iterator, _, err := s.rangeQuery(s.private[collection], startKey, startKey+maxUnicodeRune, 0, "")
+	return iterator, err
+}
+
+func (s *Stub) GetPrivateDataQueryResult(collection, query string) (shim.StateQueryIteratorInterface, error) {
+	return nil, errRichQuery
+}
+
+func (s *Stub) GetCreator() ([]byte, error) {
+	return s.creator, nil
+}
+
+func (s *Stub) GetTransient() (map[string][]byte, error) {
+	return s.transient, nil
+}
+
+func (s *Stub) GetBinding() ([]byte, error) {
+	return nil, nil
+}
+
+func (s *Stub) GetDecorations() map[string][]byte {
+	return nil
+}
+
+func (s *Stub) GetSignedProposal() (*peer.SignedProposal, error) {
+	return nil, nil
+}
+
+func (s *Stub) GetTxTimestamp() (*timestamppb.Timestamp, error) {
+	if s.tx == nil {
+		return nil, errNoTransaction
+	}
+	return s.tx.timestamp, nil
+}
+
+// SetEvent sets the event of the transaction; like on a peer, only the last
+// event set by a transaction is emitted.
+func (s *Stub) SetEvent(name string, payload []byte) error {
+	if s.tx == nil {
+		return errNoTransaction
+	}
+	if name == "" {
+		return errors.New("event name can not be empty string")
+	}
+	s.tx.event = &peer.ChaincodeEvent{
+		TxId:      s.tx.id,
+		EventName: name,
+		Payload:   payload,
+	}
+	return nil
+}
+
+func (s *Stub) writePrivateData(collection, key string, w *write) error {
+	if s.tx == nil {
+		return errNoTransaction
+	}
+	if collection == "" {
+		return errors.New("collection must not be an empty string")
+	}
+	if key == "" {
+		return errors.New("key must not be an empty string")
+	}
+	if s.tx.private[collection] == nil {
+		s.tx.private[collection] = make(map[string]*write)
+	}
+	s.tx.private[collection][key] = w
+	return nil
+}
+
+func privateKey(collection, key string) string {
+	return collection + "\x00" + key
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
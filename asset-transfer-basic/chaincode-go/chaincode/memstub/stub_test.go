@@ -0,0 +1,433 @@
+package memstub
+
+import (
+	"crypto/x509/pkix"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric-chaincode-go/v2/pkg/cid"
+	"github.com/hyperledger/fabric-chaincode-go/v2/shim"
+	"github.com/hyperledger/fabric-protos-go-apiv2/peer"
+)
+
+// chaincodeFunc runs a function as a chaincode, so that Invoke can be tested
+// without a contract.
+type chaincodeFunc func(stub shim.ChaincodeStubInterface) *peer.Response
+
+func (f chaincodeFunc) Init(stub shim.ChaincodeStubInterface) *peer.Response {
+	return shim.Success(nil)
+}
+
+func (f chaincodeFunc) Invoke(stub shim.ChaincodeStubInterface) *peer.Response {
+	return f(stub)
+}
+
+// put commits a transaction writing each key with its own name as value.
+func put(t *testing.T, stub *Stub, keys ...string) {
+	t.Helper()
+	if err := stub.StartTransaction(""); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range keys {
+		if err := stub.PutState(key, []byte(key)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := stub.Commit(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func keysOf(t *testing.T, iterator shim.StateQueryIteratorInterface) []string {
+	t.Helper()
+	defer iterator.Close()
+
+	keys := []string{}
+	for iterator.HasNext() {
+		result, err := iterator.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		keys = append(keys, result.Key)
+	}
+	return keys
+}
+
+func compositeKey(t *testing.T, objectType string, attributes ...string) string {
+	t.Helper()
+	key, err := shim.CreateCompositeKey(objectType, attributes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func TestWritesAreVisibleOnceCommitted(t *testing.T) {
+	stub := New("mychannel")
+
+	if err := stub.PutState("a", []byte("1")); err != errNoTransaction {
+		t.Fatalf("PutState outside a transaction returned %v", err)
+	}
+
+	if err := stub.StartTransaction("tx-a"); err != nil {
+		t.Fatal(err)
+	}
+	if err := stub.PutState("a", []byte("1")); err != nil {
+		t.Fatal(err)
+	}
+	if value, _ := stub.GetState("a"); value != nil {
+		t.Fatalf("uncommitted write is visible: %q", value)
+	}
+	if err := stub.StartTransaction(""); err == nil {
+		t.Fatal("a second transaction started while one is in progress")
+	}
+	if err := stub.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if value, _ := stub.GetState("a"); string(value) != "1" {
+		t.Fatalf("GetState returned %q after commit", value)
+	}
+
+	if err := stub.StartTransaction(""); err != nil {
+		t.Fatal(err)
+	}
+	if err := stub.DelState("a"); err != nil {
+		t.Fatal(err)
+	}
+	stub.Rollback()
+	if value, _ := stub.GetState("a"); string(value) != "1" {
+		t.Fatalf("rolled back delete was applied, GetState returned %q", value)
+	}
+}
+
+func TestRangeQuerySkipsCompositeKeys(t *testing.T) {
+	stub := New("mychannel")
+	put(t, stub, "c", "a", "b", compositeKey(t, "vote", "e1", "b1"))
+
+	iterator, err := stub.GetStateByRange("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if keys := keysOf(t, iterator); !reflect.DeepEqual(keys, []string{"a", "b", "c"}) {
+		t.Fatalf("GetStateByRange returned %q", keys)
+	}
+
+	iterator, err = stub.GetStateByRange("a", "c")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if keys := keysOf(t, iterator); !reflect.DeepEqual(keys, []string{"a", "b"}) {
+		t.Fatalf("GetStateByRange(a, c) returned %q", keys)
+	}
+
+	if _, err := stub.GetStateByRange(compositeKey(t, "vote"), ""); err == nil {
+		t.Fatal("range query starting at a composite key succeeded")
+	}
+}
+
+func TestPartialCompositeKeyQuery(t *testing.T) {
+	stub := New("mychannel")
+	e1b1 := compositeKey(t, "vote", "e1", "b1")
+	e1b2 := compositeKey(t, "vote", "e1", "b2")
+	e2b1 := compositeKey(t, "vote", "e2", "b1")
+	put(t, stub, e2b1, e1b2, e1b1, compositeKey(t, "voter", "e1"), "e1")
+
+	iterator, err := stub.GetStateByPartialCompositeKey("vote", []string{"e1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if keys := keysOf(t, iterator); !reflect.DeepEqual(keys, []string{e1b1, e1b2}) {
+		t.Fatalf("GetStateByPartialCompositeKey returned %q", keys)
+	}
+
+	iterator, err = stub.GetStateByPartialCompositeKey("vote", []string{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if keys := keysOf(t, iterator); len(keys) != 3 {
+		t.Fatalf("GetStateByPartialCompositeKey without attributes returned %q", keys)
+	}
+
+	objectType, attributes, err := stub.SplitCompositeKey(e2b1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if objectType != "vote" || !reflect.DeepEqual(attributes, []string{"e2", "b1"}) {
+		t.Fatalf("SplitCompositeKey returned %q %q", objectType, attributes)
+	}
+	if _, _, err := stub.SplitCompositeKey("e1"); err == nil {
+		t.Fatal("SplitCompositeKey split a simple key")
+	}
+}
+
+func TestPagination(t *testing.T) {
+	stub := New("mychannel")
+	for i := 0; i < 5; i++ {
+		put(t, stub, fmt.Sprintf("k%d", i), compositeKey(t, "vote", "e1", fmt.Sprintf("b%d", i)))
+	}
+
+	var pages [][]string
+	bookmark := ""
+	for {
+		iterator, metadata, err := stub.GetStateByRangeWithPagination("", "", 2, bookmark)
+		if err != nil {
+			t.Fatal(err)
+		}
+		keys := keysOf(t, iterator)
+		if int(metadata.FetchedRecordsCount) != len(keys) {
+			t.Fatalf("FetchedRecordsCount is %d for %d keys", metadata.FetchedRecordsCount, len(keys))
+		}
+		pages = append(pages, keys)
+		bookmark = metadata.Bookmark
+		if bookmark == "" {
+			break
+		}
+	}
+	want := [][]string{{"k0", "k1"}, {"k2", "k3"}, {"k4"}}
+	if !reflect.DeepEqual(pages, want) {
+		t.Fatalf("range pages are %q, want %q", pages, want)
+	}
+
+	iterator, metadata, err := stub.GetStateByPartialCompositeKeyWithPagination("vote", []string{"e1"}, 3, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if keys := keysOf(t, iterator); len(keys) != 3 || metadata.Bookmark != compositeKey(t, "vote", "e1", "b3") {
+		t.Fatalf("first composite page is %q with bookmark %q", keys, metadata.Bookmark)
+	}
+	iterator, metadata, err = stub.GetStateByPartialCompositeKeyWithPagination("vote", []string{"e1"}, 3, metadata.Bookmark)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if keys := keysOf(t, iterator); len(keys) != 2 || metadata.Bookmark != "" {
+		t.Fatalf("last composite page is %q with bookmark %q", keys, metadata.Bookmark)
+	}
+
+	if _, _, err := stub.GetStateByPartialCompositeKeyWithPagination("vote", []string{"e1"}, 3, "k0"); err == nil {
+		t.Fatal("a bookmark outside the queried range was accepted")
+	}
+}
+
+func TestHistory(t *testing.T) {
+	stub := New("mychannel")
+	for i, write := range []string{"v1", "v2", ""} {
+		if err := stub.StartTransaction(fmt.Sprintf("tx%d", i)); err != nil {
+			t.Fatal(err)
+		}
+		if write == "" {
+			err := stub.DelState("k")
+			if err != nil {
+				t.Fatal(err)
+			}
+		} else if err := stub.PutState("k", []byte(write)); err != nil {
+			t.Fatal(err)
+		}
+		if err := stub.Commit(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	iterator, err := stub.GetHistoryForKey("k")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer iterator.Close()
+
+	var got []string
+	for iterator.HasNext() {
+		modification, err := iterator.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, fmt.Sprintf("%s:%s:%t", modification.TxId, modification.Value, modification.IsDelete))
+	}
+	want := []string{"tx2::true", "tx1:v2:false", "tx0:v1:false"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("history is %q, want %q", got, want)
+	}
+}
+
+func TestEvents(t *testing.T) {
+	stub := New("mychannel")
+	cc := chaincodeFunc(func(stub shim.ChaincodeStubInterface) *peer.Response {
+		_, params := stub.GetFunctionAndParameters()
+		for _, name := range params {
+			if err := stub.SetEvent(name, []byte(stub.GetTxID())); err != nil {
+				return shim.Error(err.Error())
+			}
+		}
+		if len(params) == 0 {
+			_ = stub.SetEvent("Failed", nil)
+			return shim.Error("no events")
+		}
+		return shim.Success(nil)
+	})
+
+	if response := stub.Invoke(cc, "emit", "First", "Second"); response.Status != shim.OK {
+		t.Fatal(response.Message)
+	}
+	if response := stub.Invoke(cc, "emit"); response.Status == shim.OK {
+		t.Fatal("the failing transaction succeeded")
+	}
+	if response := stub.Invoke(cc, "emit", "Third"); response.Status != shim.OK {
+		t.Fatal(response.Message)
+	}
+
+	var got []string
+	for _, event := range stub.Events() {
+		got = append(got, event.EventName+":"+string(event.Payload))
+	}
+	want := []string{"Second:tx1", "Third:tx3"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("events are %q, want %q", got, want)
+	}
+}
+
+func TestInvokeRollsBackFailedTransactions(t *testing.T) {
+	stub := New("mychannel")
+	cc := chaincodeFunc(func(stub shim.ChaincodeStubInterface) *peer.Response {
+		function, params := stub.GetFunctionAndParameters()
+		if err := stub.PutState(params[0], []byte(function)); err != nil {
+			return shim.Error(err.Error())
+		}
+		if function == "fail" {
+			return shim.Error("failed")
+		}
+		return shim.Success(nil)
+	})
+
+	stub.Invoke(cc, "fail", "a")
+	stub.Invoke(cc, "put", "b")
+	if value, _ := stub.GetState("a"); value != nil {
+		t.Fatalf("write of a failed transaction was committed: %q", value)
+	}
+	if value, _ := stub.GetState("b"); string(value) != "put" {
+		t.Fatalf("GetState returned %q", value)
+	}
+}
+
+func TestTransientAndPrivateData(t *testing.T) {
+	stub := New("mychannel")
+	stub.SetTransient(map[string][]byte{"salt": []byte("s")})
+	transient, err := stub.GetTransient()
+	if err != nil || string(transient["salt"]) != "s" {
+		t.Fatalf("GetTransient returned %q, %v", transient, err)
+	}
+
+	first := compositeKey(t, "weight", "e1", "v1")
+	second := compositeKey(t, "weight", "e1", "v2")
+	if err := stub.StartTransaction(""); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{first, second} {
+		if err := stub.PutPrivateData("weights", key, []byte("3")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if value, _ := stub.GetPrivateData("weights", first); value != nil {
+		t.Fatalf("uncommitted private write is visible: %q", value)
+	}
+	if err := stub.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	if value, _ := stub.GetPrivateData("weights", first); string(value) != "3" {
+		t.Fatalf("GetPrivateData returned %q", value)
+	}
+	if value, _ := stub.GetState(first); value != nil {
+		t.Fatalf("private data is in the world state: %q", value)
+	}
+	if hash, _ := stub.GetPrivateDataHash("weights", first); len(hash) != 32 {
+		t.Fatalf("GetPrivateDataHash returned %x", hash)
+	}
+	iterator, err := stub.GetPrivateDataByPartialCompositeKey("weights", "weight", []string{"e1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if keys := keysOf(t, iterator); !reflect.DeepEqual(keys, []string{first, second}) {
+		t.Fatalf("GetPrivateDataByPartialCompositeKey returned %q", keys)
+	}
+	if value, _ := stub.GetPrivateData("other", first); value != nil {
+		t.Fatalf("private data leaked to another collection: %q", value)
+	}
+}
+
+func TestIdentity(t *testing.T) {
+	stub := New("mychannel")
+	admin, err := NewIdentity("Org1MSP", pkix.Name{CommonName: "registrar", OrganizationalUnit: []string{"admin"}}, map[string]string{"role": "admin"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := stub.SetIdentity(admin); err != nil {
+		t.Fatal(err)
+	}
+
+	identity, err := cid.New(stub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mspID, _ := identity.GetMSPID(); mspID != "Org1MSP" {
+		t.Fatalf("GetMSPID returned %q", mspID)
+	}
+	if role, found, _ := identity.GetAttributeValue("role"); !found || role != "admin" {
+		t.Fatalf("role attribute is %q, found %t", role, found)
+	}
+	cert, err := identity.GetX509Certificate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cert.Subject.CommonName != "registrar" || !reflect.DeepEqual(cert.Subject.OrganizationalUnit, []string{"admin"}) {
+		t.Fatalf("certificate subject is %v", cert.Subject)
+	}
+
+	if err := stub.StartTransaction("tx-ctx", "Function", "arg"); err != nil {
+		t.Fatal(err)
+	}
+	ctx, err := stub.TransactionContext()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mspID, _ := ctx.GetClientIdentity().GetMSPID(); mspID != "Org1MSP" {
+		t.Fatalf("context MSP ID is %q", mspID)
+	}
+	if ctx.GetStub().GetTxID() != "tx-ctx" {
+		t.Fatalf("context transaction is %q", ctx.GetStub().GetTxID())
+	}
+	if function, params := ctx.GetStub().GetFunctionAndParameters(); function != "Function" || !reflect.DeepEqual(params, []string{"arg"}) {
+		t.Fatalf("context function is %q %q", function, params)
+	}
+	stub.Rollback()
+}
+
+func TestClock(t *testing.T) {
+	stub := New("mychannel")
+	now := time.Date(2026, 11, 1, 8, 0, 0, 0, time.UTC)
+	stub.SetClock(func() time.Time { return now })
+
+	if _, err := stub.GetTxTimestamp(); err != errNoTransaction {
+		t.Fatalf("GetTxTimestamp outside a transaction returned %v", err)
+	}
+	if err := stub.StartTransaction(""); err != nil {
+		t.Fatal(err)
+	}
+	timestamp, err := stub.GetTxTimestamp()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !timestamp.AsTime().Equal(now) {
+		t.Fatalf("transaction timestamp is %v, want %v", timestamp.AsTime(), now)
+	}
+	stub.Rollback()
+}
+
+func TestRichQueriesFailAsOnLevelDB(t *testing.T) {
+	stub := New("mychannel")
+	if _, err := stub.GetQueryResult(`{"selector":{}}`); err != errRichQuery {
+		t.Fatalf("GetQueryResult returned %v", err)
+	}
+	if _, err := stub.GetPrivateDataQueryResult("weights", `{"selector":{}}`); err != errRichQuery {
+		t.Fatalf("GetPrivateDataQueryResult returned %v", err)
+	}
+}
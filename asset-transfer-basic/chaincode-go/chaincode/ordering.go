@@ -0,0 +1,322 @@
+package chaincode
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hyperledger/fabric-contract-api-go/v2/contractapi"
+)
+
+const orderingContributionDocType = "orderingContribution"
+
+// Policies for the order candidates are listed in.
+const (
+	// OrderingRegistration lists candidates in the order they were registered.
+	OrderingRegistration = ""
+	// OrderingAlphabetical lists candidates by name.
+	OrderingAlphabetical = "alphabetical"
+	// OrderingLot lists candidates in an order drawn from the seeds
+	// contributed by the election's organizations, the same for every voter.
+	OrderingLot = "lot"
+	// OrderingRotation rotates the alphabetical order by an offset derived
+	// from the election's seed and the voter, so that every candidate is
+	// listed first for a share of the voters.
+	OrderingRotation = "rotation"
+)
+
+// OrderingContribution is one organization's share of the seed that draws
+// the candidate order of an election. Seed is empty until it is revealed.
+type OrderingContribution struct {
+	DocType       string `json:"docType"`
+	SchemaVersion int    `json:"schemaVersion"`
+	ElectionID    string `json:"electionID"`
+	MSPID         string `json:"mspID"`
+	// Commitment is the hex encoded SHA-256 hash of Seed.
+	Commitment string `json:"commitment"`
+	Seed       string `json:"seed,omitempty" metadata:",optional"`
+}
+
+// CommitOrderingSeed records the submitting organization's commitment to its
+// share of the seed of an election whose candidates are ordered by lot. Each
+// organization of the election commits once. The first commitment closes the
+// election's candidate list, so the candidates are fixed before any share is
+// known.
+func (c *ElectionContract) CommitOrderingSeed(ctx *ElectionContext, electionID string, commitment string) error {
+	election, mspID, err := orderingDrawElection(ctx, electionID)
+	if err != nil {
+		return err
+	}
+	commitment = strings.ToLower(commitment)
+	decoded, err := hex.DecodeString(commitment)
+	if err != nil || len(decoded) != sha256.Size {
+		return fmt.Errorf("commitment must be a hex encoded SHA-256 hash")
+	}
+
+	contributions, err := getOrderingContributions(ctx, electionID)
+	if err != nil {
+		return err
+	}
+	for _, contribution := range contributions {
+		if contribution.MSPID == mspID {
+			return fmt.Errorf("organization %s has already committed to an ordering seed for election %s", mspID, electionID)
+		}
+	}
+
+	return putOrderingContribution(ctx, &OrderingContribution{
+		DocType:       orderingContributionDocType,
+		SchemaVersion: currentSchemaVersion,
+		ElectionID:    election.ID,
+		MSPID:         mspID,
+		Commitment:    commitment,
+	})
+}
+
+// RevealOrderingSeed publishes the submitting organization's share of the
+// seed, which must hash to its commitment. Shares can only be revealed once
+// every organization of the election has committed, so no organization can
+// pick its share knowing another's. When the last share is revealed the
+// candidate order is drawn from all of them, which no single organization
+// controls. The election can only open once the order is drawn.
+func (c *ElectionContract) RevealOrderingSeed(ctx *ElectionContext, electionID string, seed string) error {
+	election, mspID, err := orderingDrawElection(ctx, electionID)
+	if err != nil {
+		return err
+	}
+
+	contributions, err := getOrderingContributions(ctx, electionID)
+	if err != nil {
+		return err
+	}
+	if len(contributions) < len(election.Organizations) {
+		return fmt.Errorf("every organization of election %s must commit to an ordering seed before seeds are revealed", electionID)
+	}
+
+	var own *OrderingContribution
+	revealed := 0
+	for _, contribution := range contributions {
+		if contribution.MSPID == mspID {
+			own = contribution
+		}
+		if contribution.Seed != "" {
+			revealed++
+		}
+	}
+	if own == nil {
+		return fmt.Errorf("organization %s has not committed to an ordering seed for election %s", mspID, electionID)
+	}
+	if own.Seed != "" {
+		return fmt.Errorf("organization %s has already revealed its ordering seed for election %s", mspID, electionID)
+	}
+	hash := sha256.Sum256([]byte(seed))
+	if hex.EncodeToString(hash[:]) != own.Commitment {
+		return fmt.Errorf("seed does not match the commitment of organization %s", mspID)
+	}
+
+	own.Seed = seed
+	err = putOrderingContribution(ctx, own)
+	if err != nil {
+		return err
+	}
+	if revealed+1 < len(contributions) {
+		return nil
+	}
+
+	// The contribution written above is not visible to reads in the same
+	// transaction, so the draw uses the updated copy held in contributions.
+	election.OrderingSeed = combinedOrderingSeed(contributions)
+	return putElection(ctx, election)
+}
+
+// GetOrderingContributions returns the commitments and revealed seeds of the
+// organizations drawing the candidate order of the election.
+func (c *ElectionContract) GetOrderingContributions(ctx *ElectionContext, electionID string) ([]*OrderingContribution, error) {
+	return getOrderingContributions(ctx, electionID)
+}
+
+// orderingDrawElection returns the election whose candidate order the
+// submitting admin's organization takes part in drawing, together with the
+// organization's MSP ID.
+func orderingDrawElection(ctx *ElectionContext, electionID string) (*Election, string, error) {
+	err := ctx.AssertAdmin()
+	if err != nil {
+		return nil, "", err
+	}
+
+	election, err := ctx.ReadElection(electionID)
+	if err != nil {
+		return nil, "", err
+	}
+	err = assertNotPaused(election)
+	if err != nil {
+		return nil, "", err
+	}
+	if election.Rules.Ordering != OrderingLot {
+		return nil, "", fmt.Errorf("the candidates of election %s are not ordered by lot", electionID)
+	}
+	if election.Status != ElectionStatusCreated {
+		return nil, "", fmt.Errorf("election %s is %s, the candidate order can only be drawn before it opens", electionID, election.Status)
+	}
+	if election.OrderingSeed != "" {
+		return nil, "", fmt.Errorf("the candidate order of election %s has already been drawn", electionID)
+	}
+
+	mspID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read client MSP ID: %v", err)
+	}
+	if !containsString(election.Organizations, mspID) {
+		return nil, "", fmt.Errorf("organization %s does not participate in election %s", mspID, electionID)
+	}
+
+	return election, mspID, nil
+}
+
+// assertCandidateListOpen rejects changes to the candidates of an election
+// ordered by lot once its organizations have started committing to the seed.
+func assertCandidateListOpen(ctx contractapi.TransactionContextInterface, election *Election) error {
+	if election == nil || election.Rules.Ordering != OrderingLot {
+		return nil
+	}
+
+	contributions, err := getOrderingContributions(ctx, election.ID)
+	if err != nil {
+		return err
+	}
+	if len(contributions) > 0 {
+		return fmt.Errorf("the candidate list of election %s closed when its ordering draw started", election.ID)
+	}
+
+	return nil
+}
+
+func getOrderingContributions(ctx contractapi.TransactionContextInterface, electionID string) ([]*OrderingContribution, error) {
+	values, err := getPartialCompositeKeyValues(ctx, orderingContributionDocType, []string{electionID})
+	if err != nil {
+		return nil, err
+	}
+
+	contributions := make([]*OrderingContribution, 0, len(values))
+	for _, value := range values {
+		var contribution OrderingContribution
+		err = unmarshalAsset(value, &contribution)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal ordering contribution: %v", err)
+		}
+		contributions = append(contributions, &contribution)
+	}
+
+	return contributions, nil
+}
+
+func putOrderingContribution(ctx contractapi.TransactionContextInterface, contribution *OrderingContribution) error {
+	key, err := ctx.GetStub().CreateCompositeKey(orderingContributionDocType, []string{contribution.ElectionID, contribution.MSPID})
+	if err != nil {
+		return fmt.Errorf("failed to create ordering contribution key: %v", err)
+	}
+	contributionJSON, err := json.Marshal(contribution)
+	if err != nil {
+		return fmt.Errorf("failed to marshal ordering contribution: %v", err)
+	}
+	err = ctx.GetStub().PutState(key, contributionJSON)
+	if err != nil {
+		return fmt.Errorf("failed to put ordering contribution: %v", err)
+	}
+
+	return nil
+}
+
+// combinedOrderingSeed hashes the revealed seeds of every organization, in
+// MSP ID order, into the seed the candidate order is drawn from.
+func combinedOrderingSeed(contributions []*OrderingContribution) string {
+	sort.Slice(contributions, func(i, j int) bool {
+		return contributions[i].MSPID < contributions[j].MSPID
+	})
+
+	hash := sha256.New()
+	for _, contribution := range contributions {
+		fmt.Fprintf(hash, "%s=%s\n", contribution.MSPID, contribution.Seed)
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// GetCandidatesForVoter returns the candidates of the election in the order
+// they are presented to voterID under the election's ordering policy.
+func (c *BallotContract) GetCandidatesForVoter(ctx *BallotContext, electionID string, voterID string) ([]*Candidate, error) {
+	if len(voterID) == 0 {
+		return nil, fmt.Errorf("voterID cannot be empty")
+	}
+
+	candidates, err := getCandidatesByElection(ctx, electionID)
+	if err != nil {
+		return nil, err
+	}
+
+	election, err := getElection(ctx, electionID)
+	if err != nil {
+		return nil, err
+	}
+
+	return orderCandidates(election, candidates, voterID), nil
+}
+
+// orderCandidates sorts candidates by the election's ordering policy. Without
+// a voter, rotated ballots are returned unrotated.
+func orderCandidates(election *Election, candidates []*Candidate, voterID string) []*Candidate {
+	// Candidate IDs are random, so they only break ties between candidates
+	// registered at the same time.
+	sort.Slice(candidates, func(i, j int) bool {
+		if !candidates[i].RegisteredAt.Equal(candidates[j].RegisteredAt) {
+			return candidates[i].RegisteredAt.Before(candidates[j].RegisteredAt)
+		}
+		return candidates[i].ID < candidates[j].ID
+	})
+	if election == nil {
+		return candidates
+	}
+
+	switch election.Rules.Ordering {
+	case OrderingAlphabetical, OrderingRotation:
+		sort.SliceStable(candidates, func(i, j int) bool {
+			return strings.ToLower(candidates[i].Name) < strings.ToLower(candidates[j].Name)
+		})
+		if election.Rules.Ordering == OrderingRotation && voterID != "" && len(candidates) > 0 {
+			offset := int(seededUint(orderingSeed(election), voterID) % uint64(len(candidates)))
+			rotated := make([]*Candidate, 0, len(candidates))
+			rotated = append(rotated, candidates[offset:]...)
+			candidates = append(rotated, candidates[:offset]...)
+		}
+	case OrderingLot:
+		// The lot is drawn once every seed is revealed, which the election
+		// requires before it opens.
+		if election.OrderingSeed == "" {
+			break
+		}
+		// Fisher-Yates shuffle driven by the drawn seed.
+		for i := len(candidates) - 1; i > 0; i-- {
+			j := int(seededUint(orderingSeed(election), strconv.Itoa(i)) % uint64(i+1))
+			candidates[i], candidates[j] = candidates[j], candidates[i]
+		}
+	}
+
+	return candidates
+}
+
+// orderingSeed is the drawn seed of the election, or the election ID when
+// its candidates are not ordered by lot.
+func orderingSeed(election *Election) string {
+	if election.OrderingSeed != "" {
+		return election.OrderingSeed
+	}
+	return election.ID
+}
+
+func seededUint(seed string, value string) uint64 {
+	hash := sha256.Sum256([]byte(seed + ":" + value))
+	return binary.BigEndian.Uint64(hash[:8])
+}
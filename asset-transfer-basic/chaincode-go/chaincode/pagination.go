@@ -0,0 +1,206 @@
+package chaincode
+
+import (
+	"fmt"
+
+	"github.com/hyperledger/fabric-chaincode-go/v2/shim"
+	"github.com/hyperledger/fabric-contract-api-go/v2/contractapi"
+)
+
+// candidateElectionIndex is the composite key object type used to look up
+// candidates by the election they are registered for.
+const candidateElectionIndex = "election~candidate"
+
+// voterIndex is the composite key object type listing every registered voter,
+// so that voters can be paged without scanning the other assets.
+const voterIndex = "voter~id"
+
+// PaginatedQueryResult holds a single page of candidates together with the
+// bookmark that must be passed back to fetch the next page.
+type PaginatedQueryResult struct {
+	Records             []*Candidate `json:"records,omitempty" metadata:",optional"`
+	FetchedRecordsCount int32        `json:"fetchedRecordsCount"`
+	Bookmark            string       `json:"bookmark"`
+}
+
+// VoterPage holds a single page of voters together with the bookmark that
+// must be passed back to fetch the next page.
+type VoterPage struct {
+	Records             []*Voter `json:"records,omitempty" metadata:",optional"`
+	FetchedRecordsCount int32    `json:"fetchedRecordsCount"`
+	Bookmark            string   `json:"bookmark"`
+}
+
+func putCandidateElectionIndex(ctx contractapi.TransactionContextInterface, electionID string, candidateID string) error {
+	indexKey, err := ctx.GetStub().CreateCompositeKey(candidateElectionIndex, []string{electionID, candidateID})
+	if err != nil {
+		return fmt.Errorf("failed to create candidate index key: %v", err)
+	}
+
+	// Only the key is needed for the lookup, so store a placeholder value.
+	err = ctx.GetStub().PutState(indexKey, []byte{0x00})
+	if err != nil {
+		return fmt.Errorf("failed to put candidate index: %v", err)
+	}
+
+	return nil
+}
+
+func putVoterIndex(ctx contractapi.TransactionContextInterface, voterID string) error {
+	indexKey, err := ctx.GetStub().CreateCompositeKey(voterIndex, []string{voterID})
+	if err != nil {
+		return fmt.Errorf("failed to create voter index key: %v", err)
+	}
+
+	err = ctx.GetStub().PutState(indexKey, []byte{0x00})
+	if err != nil {
+		return fmt.Errorf("failed to put voter index: %v", err)
+	}
+
+	return nil
+}
+
+func hasVoterIndex(ctx contractapi.TransactionContextInterface, voterID string) (bool, error) {
+	indexKey, err := ctx.GetStub().CreateCompositeKey(voterIndex, []string{voterID})
+	if err != nil {
+		return false, fmt.Errorf("failed to create voter index key: %v", err)
+	}
+
+	value, err := ctx.GetStub().GetState(indexKey)
+	if err != nil {
+		return false, fmt.Errorf("failed to read voter index: %v", err)
+	}
+
+	return value != nil, nil
+}
+
+// GetAllAssetsWithPagination returns a page of at most pageSize candidates of
+// every election, starting at the position identified by bookmark. Voters are
+// paged with GetVotersWithPagination.
+func (s *SmartContract) GetAllAssetsWithPagination(ctx contractapi.TransactionContextInterface, pageSize int32, bookmark string) (*PaginatedQueryResult, error) {
+	if pageSize <= 0 {
+		return nil, fmt.Errorf("pageSize must be greater than zero")
+	}
+
+	resultsIterator, responseMetadata, err := ctx.GetStub().GetStateByPartialCompositeKeyWithPagination(candidateElectionIndex, []string{}, pageSize, bookmark)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read candidate index: %v", err)
+	}
+	defer resultsIterator.Close()
+
+	candidates, err := candidatesFromIndex(ctx, resultsIterator)
+	if err != nil {
+		return nil, err
+	}
+
+	return &PaginatedQueryResult{
+		Records:             candidates,
+		FetchedRecordsCount: responseMetadata.FetchedRecordsCount,
+		Bookmark:            responseMetadata.Bookmark,
+	}, nil
+}
+
+// GetVotersWithPagination returns a page of at most pageSize registered
+// voters, starting at bookmark.
+func (c *RegistryContract) GetVotersWithPagination(ctx *RegistryContext, pageSize int32, bookmark string) (*VoterPage, error) {
+	if pageSize <= 0 {
+		return nil, fmt.Errorf("pageSize must be greater than zero")
+	}
+
+	resultsIterator, responseMetadata, err := ctx.GetStub().GetStateByPartialCompositeKeyWithPagination(voterIndex, []string{}, pageSize, bookmark)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read voter index: %v", err)
+	}
+	defer resultsIterator.Close()
+
+	var voters []*Voter
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("failed to iterate through results: %v", err)
+		}
+
+		_, keyParts, err := ctx.GetStub().SplitCompositeKey(queryResponse.Key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to split voter index key: %v", err)
+		}
+		if len(keyParts) != 1 {
+			return nil, fmt.Errorf("malformed voter index key: %s", queryResponse.Key)
+		}
+
+		voter, err := ctx.ReadVoter(keyParts[0])
+		if err != nil {
+			return nil, err
+		}
+		voters = append(voters, voter)
+	}
+
+	return &VoterPage{
+		Records:             voters,
+		FetchedRecordsCount: responseMetadata.FetchedRecordsCount,
+		Bookmark:            responseMetadata.Bookmark,
+	}, nil
+}
+
+// GetCandidatesByElectionWithPagination returns a page of at most pageSize
+// candidates registered for electionID, starting at bookmark.
+func (c *RegistryContract) GetCandidatesByElectionWithPagination(ctx *RegistryContext, electionID string, pageSize int32, bookmark string) (*PaginatedQueryResult, error) {
+	if len(electionID) == 0 {
+		return nil, fmt.Errorf("electionID cannot be empty")
+	}
+	if pageSize <= 0 {
+		return nil, fmt.Errorf("pageSize must be greater than zero")
+	}
+
+	resultsIterator, responseMetadata, err := ctx.GetStub().GetStateByPartialCompositeKeyWithPagination(candidateElectionIndex, []string{electionID}, pageSize, bookmark)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read candidate index: %v", err)
+	}
+	defer resultsIterator.Close()
+
+	candidates, err := candidatesFromIndex(ctx, resultsIterator)
+	if err != nil {
+		return nil, err
+	}
+
+	return &PaginatedQueryResult{
+		Records:             candidates,
+		FetchedRecordsCount: responseMetadata.FetchedRecordsCount,
+		Bookmark:            responseMetadata.Bookmark,
+	}, nil
+}
+
+func candidatesFromIndex(ctx contractapi.TransactionContextInterface, resultsIterator shim.StateQueryIteratorInterface) ([]*Candidate, error) {
+	var candidates []*Candidate
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("failed to iterate through results: %v", err)
+		}
+
+		_, keyParts, err := ctx.GetStub().SplitCompositeKey(queryResponse.Key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to split candidate index key: %v", err)
+		}
+		if len(keyParts) != 2 {
+			return nil, fmt.Errorf("malformed candidate index key: %s", queryResponse.Key)
+		}
+
+		candidateJSON, err := ctx.GetStub().GetState(keyParts[1])
+		if err != nil {
+			return nil, fmt.Errorf("failed to read candidate: %v", err)
+		}
+		if candidateJSON == nil {
+			continue
+		}
+
+		var candidate Candidate
+		err = unmarshalAsset(candidateJSON, &candidate)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal candidate: %v", err)
+		}
+		candidates = append(candidates, &candidate)
+	}
+
+	return candidates, nil
+}
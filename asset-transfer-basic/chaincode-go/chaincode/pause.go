@@ -0,0 +1,392 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/v2/contractapi"
+)
+
+const (
+	pauseDocType         = "pause"
+	pauseApprovalDocType = "pauseApproval"
+)
+
+// PausedErrorPrefix starts the error returned for every transaction rejected
+// because the contract or the election is paused, so clients can tell it
+// apart from other failures.
+const PausedErrorPrefix = "PAUSED"
+
+// readOnlyPrefixes are the name prefixes of transactions that only read the
+// ledger and stay available while the contract is paused.
+var readOnlyPrefixes = []string{"Get", "Read", "Query", "Compute", "Check"}
+
+// GlobalPause is the contract wide pause switch. Once written it can only be
+// changed with endorsements from a quorum of Organizations. The switch is
+// created, and its organizations changed, only after an administrator of
+// every listed organization has approved them with ApprovePauseOrganizations.
+type GlobalPause struct {
+	DocType           string    `json:"docType"`
+	SchemaVersion     int       `json:"schemaVersion"`
+	Paused            bool      `json:"paused"`
+	Reason            string    `json:"reason"`
+	Organizations     []string  `json:"organizations,omitempty" metadata:",optional"`
+	EndorsementQuorum int       `json:"endorsementQuorum"`
+	UpdatedBy         string    `json:"updatedBy"`
+	UpdatedAt         time.Time `json:"updatedAt"`
+}
+
+// PauseApproval records an organization's agreement to protect the pause
+// switch with Organizations and EndorsementQuorum.
+type PauseApproval struct {
+	DocType           string    `json:"docType"`
+	SchemaVersion     int       `json:"schemaVersion"`
+	MSPID             string    `json:"mspID"`
+	Organizations     []string  `json:"organizations"`
+	EndorsementQuorum int       `json:"endorsementQuorum"`
+	ApprovedBy        string    `json:"approvedBy"`
+	ApprovedAt        time.Time `json:"approvedAt"`
+}
+
+// PauseStatus reports whether writes are currently rejected, for the whole
+// contract and, when requested, for a single election.
+type PauseStatus struct {
+	GlobalPaused   bool   `json:"globalPaused"`
+	GlobalReason   string `json:"globalReason,omitempty" metadata:",optional"`
+	ElectionID     string `json:"electionID,omitempty" metadata:",optional"`
+	ElectionPaused bool   `json:"electionPaused"`
+	ElectionReason string `json:"electionReason,omitempty" metadata:",optional"`
+}
+
+// ApprovePauseOrganizations records that the submitting organization agrees
+// to protect the pause switch with organizations and quorum. The submitting
+// organization must be one of them. Since only channel members can submit
+// transactions, an approval from each organization also proves that every
+// listed organization belongs to the channel.
+func (s *SmartContract) ApprovePauseOrganizations(ctx contractapi.TransactionContextInterface, organizations []string, quorum int) error {
+	err := assertAdmin(ctx)
+	if err != nil {
+		return err
+	}
+	organizations, err = pauseOrganizations(organizations, quorum)
+	if err != nil {
+		return err
+	}
+
+	mspID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return fmt.Errorf("failed to read client MSP ID: %v", err)
+	}
+	if !containsString(organizations, mspID) {
+		return fmt.Errorf("organization %s is not one of the organizations it approves", mspID)
+	}
+
+	actor, err := clientActor(ctx)
+	if err != nil {
+		return err
+	}
+	timestamp, err := txTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	key, err := ctx.GetStub().CreateCompositeKey(pauseApprovalDocType, []string{mspID})
+	if err != nil {
+		return fmt.Errorf("failed to create pause approval key: %v", err)
+	}
+	approvalJSON, err := json.Marshal(PauseApproval{
+		DocType:           pauseApprovalDocType,
+		SchemaVersion:     currentSchemaVersion,
+		MSPID:             mspID,
+		Organizations:     organizations,
+		EndorsementQuorum: quorum,
+		ApprovedBy:        actor,
+		ApprovedAt:        timestamp,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal pause approval: %v", err)
+	}
+	err = ctx.GetStub().PutState(key, approvalJSON)
+	if err != nil {
+		return fmt.Errorf("failed to put pause approval: %v", err)
+	}
+
+	return nil
+}
+
+// SetGlobalPause stops or resumes every state-changing transaction of the
+// contract. The switch is protected by a key-level endorsement policy over
+// organizations, which must name at least two organizations that have all
+// approved them with ApprovePauseOrganizations. Pass an empty list to keep
+// the organizations already configured.
+func (s *SmartContract) SetGlobalPause(ctx contractapi.TransactionContextInterface, paused bool, reason string, organizations []string, quorum int) error {
+	err := assertAdmin(ctx)
+	if err != nil {
+		return err
+	}
+	if paused && len(reason) == 0 {
+		return fmt.Errorf("reason cannot be empty")
+	}
+
+	pause, err := getGlobalPause(ctx)
+	if err != nil {
+		return err
+	}
+	if pause == nil {
+		if len(organizations) == 0 {
+			return fmt.Errorf("the pause switch is not configured, organizations cannot be empty")
+		}
+		pause = &GlobalPause{DocType: pauseDocType}
+	}
+	if len(organizations) > 0 {
+		organizations, err = pauseOrganizations(organizations, quorum)
+		if err != nil {
+			return err
+		}
+		if !equalStrings(organizations, pause.Organizations) || quorum != pause.EndorsementQuorum {
+			err = consumePauseApprovals(ctx, organizations, quorum)
+			if err != nil {
+				return err
+			}
+		}
+		pause.Organizations = organizations
+		pause.EndorsementQuorum = quorum
+	}
+
+	timestamp, err := txTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+	actor, err := clientActor(ctx)
+	if err != nil {
+		return err
+	}
+
+	pause.SchemaVersion = currentSchemaVersion
+	pause.Paused = paused
+	pause.Reason = reason
+	pause.UpdatedBy = actor
+	pause.UpdatedAt = timestamp
+
+	key, err := globalPauseKey(ctx)
+	if err != nil {
+		return err
+	}
+	pauseJSON, err := json.Marshal(pause)
+	if err != nil {
+		return fmt.Errorf("failed to marshal pause switch: %v", err)
+	}
+	err = ctx.GetStub().PutState(key, pauseJSON)
+	if err != nil {
+		return fmt.Errorf("failed to put pause switch: %v", err)
+	}
+
+	policy, err := endorsementPolicy(pause.Organizations, pause.EndorsementQuorum)
+	if err != nil {
+		return err
+	}
+	err = ctx.GetStub().SetStateValidationParameter(key, policy)
+	if err != nil {
+		return fmt.Errorf("failed to set pause switch endorsement policy: %v", err)
+	}
+
+	return nil
+}
+
+// SetElectionPaused stops or resumes the state-changing transactions of one
+// election. The flag is stored on the election, so it is protected by the
+// election's endorsement policy, which must span at least two organizations.
+func (c *ElectionContract) SetElectionPaused(ctx *ElectionContext, electionID string, paused bool, reason string) error {
+	err := ctx.AssertAdmin()
+	if err != nil {
+		return err
+	}
+	if paused && len(reason) == 0 {
+		return fmt.Errorf("reason cannot be empty")
+	}
+
+	election, err := ctx.ReadElection(electionID)
+	if err != nil {
+		return err
+	}
+	if len(election.Organizations) < 2 {
+		return fmt.Errorf("election %s is not endorsed by multiple organizations and cannot be paused", electionID)
+	}
+
+	election.Paused = paused
+	election.PauseReason = reason
+	if !paused {
+		election.PauseReason = ""
+	}
+
+	return putElection(ctx, election)
+}
+
+// GetPauseStatus returns the global pause switch and, if electionID is not
+// empty, the pause flag of that election.
+func (s *SmartContract) GetPauseStatus(ctx contractapi.TransactionContextInterface, electionID string) (*PauseStatus, error) {
+	status := &PauseStatus{ElectionID: electionID}
+
+	pause, err := getGlobalPause(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if pause != nil && pause.Paused {
+		status.GlobalPaused = true
+		status.GlobalReason = pause.Reason
+	}
+
+	if electionID != "" {
+		election, err := readElection(ctx, electionID)
+		if err != nil {
+			return nil, err
+		}
+		status.ElectionPaused = election.Paused
+		status.ElectionReason = election.PauseReason
+	}
+
+	return status, nil
+}
+
+// GetBeforeTransaction rejects every state-changing transaction while the
+// global pause switch is set. Read-only transactions and the pause controls
+// themselves are always let through.
+func (s *SmartContract) GetBeforeTransaction() interface{} {
+	return rejectWhenPaused
+}
+
+func rejectWhenPaused(ctx contractapi.TransactionContextInterface) error {
+	function := transactionName(ctx)
+	if function == "SetGlobalPause" || function == "ApprovePauseOrganizations" || function == "SetElectionPaused" {
+		return nil
+	}
+	for _, prefix := range readOnlyPrefixes {
+		if strings.HasPrefix(function, prefix) {
+			return nil
+		}
+	}
+
+	pause, err := getGlobalPause(ctx)
+	if err != nil {
+		return err
+	}
+	if pause != nil && pause.Paused {
+		return fmt.Errorf("%s: the contract is paused: %s", PausedErrorPrefix, pause.Reason)
+	}
+
+	return nil
+}
+
+// transactionName returns the name of the invoked transaction without the
+// contract namespace.
+func transactionName(ctx contractapi.TransactionContextInterface) string {
+	function, _ := ctx.GetStub().GetFunctionAndParameters()
+	if i := strings.LastIndex(function, ":"); i >= 0 {
+		function = function[i+1:]
+	}
+
+	return function
+}
+
+// assertNotPaused rejects writes to an election whose pause flag is set.
+// Elections that only exist off-chain cannot be paused.
+func assertNotPaused(election *Election) error {
+	if election != nil && election.Paused {
+		return fmt.Errorf("%s: election %s is paused: %s", PausedErrorPrefix, election.ID, election.PauseReason)
+	}
+
+	return nil
+}
+
+func pauseOrganizations(organizations []string, quorum int) ([]string, error) {
+	organizations, err := normalizeOrganizations(organizations, quorum)
+	if err != nil {
+		return nil, err
+	}
+	if len(organizations) < 2 {
+		return nil, fmt.Errorf("the pause switch requires endorsement by at least two organizations")
+	}
+
+	return organizations, nil
+}
+
+// consumePauseApprovals checks that every organization approved organizations
+// and quorum, and removes the approvals so they cannot be used again.
+func consumePauseApprovals(ctx contractapi.TransactionContextInterface, organizations []string, quorum int) error {
+	for _, mspID := range organizations {
+		key, err := ctx.GetStub().CreateCompositeKey(pauseApprovalDocType, []string{mspID})
+		if err != nil {
+			return fmt.Errorf("failed to create pause approval key: %v", err)
+		}
+		approvalJSON, err := ctx.GetStub().GetState(key)
+		if err != nil {
+			return fmt.Errorf("failed to read pause approval: %v", err)
+		}
+		if approvalJSON == nil {
+			return fmt.Errorf("organization %s has not approved the pause switch organizations", mspID)
+		}
+
+		var approval PauseApproval
+		err = unmarshalAsset(approvalJSON, &approval)
+		if err != nil {
+			return fmt.Errorf("failed to unmarshal pause approval: %v", err)
+		}
+		if !equalStrings(approval.Organizations, organizations) || approval.EndorsementQuorum != quorum {
+			return fmt.Errorf("organization %s approved different pause switch organizations", mspID)
+		}
+
+		err = ctx.GetStub().DelState(key)
+		if err != nil {
+			return fmt.Errorf("failed to delete pause approval: %v", err)
+		}
+	}
+
+	return nil
+}
+
+func equalStrings(a []string, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+func globalPauseKey(ctx contractapi.TransactionContextInterface) (string, error) {
+	key, err := ctx.GetStub().CreateCompositeKey(pauseDocType, []string{})
+	if err != nil {
+		return "", fmt.Errorf("failed to create pause switch key: %v", err)
+	}
+
+	return key, nil
+}
+
+func getGlobalPause(ctx contractapi.TransactionContextInterface) (*GlobalPause, error) {
+	key, err := globalPauseKey(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	pauseJSON, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read pause switch: %v", err)
+	}
+	if pauseJSON == nil {
+		return nil, nil
+	}
+
+	var pause GlobalPause
+	err = unmarshalAsset(pauseJSON, &pause)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal pause switch: %v", err)
+	}
+
+	return &pause, nil
+}
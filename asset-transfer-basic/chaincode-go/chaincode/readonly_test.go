@@ -0,0 +1,146 @@
+package chaincode
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/hyperledger/fabric-contract-api-go/v2/contractapi"
+)
+
+// readTransactionArgs are the arguments each read transaction is called with
+// on the ledger built by populatedLedger.
+func readTransactionArgs(keyPEM string) map[string][]string {
+	return map[string][]string{
+		"GetAllAssets":               {},
+		"GetAllAssetsWithPagination": {"2", ""},
+		"GetPauseStatus":             {"e1"},
+
+		"GetCredentialRoll":                     {"e1"},
+		"GetConstituencies":                     {},
+		"GetPrecincts":                          {"north"},
+		"GetVoterHistory":                       {"v1"},
+		"GetCandidateHistory":                   {"e1-alice"},
+		"GetVotersWithPagination":               {"2", ""},
+		"GetCandidatesByElectionWithPagination": {"e1", "1", ""},
+		"GetCandidatesByElection":               {"e1"},
+		"QueryCandidatesByParty":                {"Blue"},
+		"QueryVotersByStatus":                   {VoterStatusRegistered},
+		"ComputeKeyChallenge":                   {"v1", keyPEM},
+		"GetTrustedRoots":                       {},
+		"ReadVoterWeight":                       {"e1", "v1"},
+
+		"ReadElection":                {"e1"},
+		"GetElectionHistory":          {"e1"},
+		"ReadBallotDefinition":        {"e2"},
+		"ReadBallotDefinitionVersion": {"e2", "1"},
+		"GetContests":                 {"e2"},
+		"GetContestResults":           {"e2"},
+		"GetTally":                    {"e1"},
+		"ComputeTallyHash":            {"e1"},
+		"GetAttestations":             {"e1"},
+		"ReadResult":                  {"e1"},
+		"GetCoercionResistantBallots": {"e1"},
+		"ReadDispute":                 {"d1"},
+		"GetDisputesByElection":       {"e1"},
+		"GetDistrictResults":          {"e1"},
+		"QueryVotesByElection":        {"e1"},
+		"GetVoteCount":                {"e1-alice"},
+		"GetWriteIns":                 {"e1"},
+		"GetOrderingContributions":    {"e1"},
+
+		"ReadDelegation":           {"v2", "e1"},
+		"CheckEligibility":         {"e1", "v1"},
+		"GetCandidatesForVoter":    {"e1", "v1"},
+		"ComputeBallotPayload":     {`{"electionID":"e1","voterID":"v1","candidateID":"e1-alice","nonce":"n"}`},
+		"ComputeDelegationPayload": {`{"electionID":"e1","from":"v1","to":"v2","keyVersion":0,"nonce":"n"}`},
+	}
+}
+
+// populatedLedger builds a ledger with a certified and disputed election e1,
+// in which votes were cast, delegated and written in, and an election e2
+// with contests.
+func populatedLedger(t *testing.T) *ledger {
+	l := newLedger(t)
+	l.invoke("registry:CreateConstituency", "north", "North")
+	l.invoke("registry:CreatePrecinct", "n1", "North 1", "north")
+
+	l.createElection("e1", "v2")
+	l.invoke("registry:RegisterVoterInPrecinct", "v1", "Voter 1", "n1")
+	l.invoke("registry:RegisterVoterInPrecinct", "v3", "Voter 3", "n1")
+	l.setRules("e1", func(rules *ElectionRules) {
+		rules.AllowDelegation = true
+		rules.AllowWriteIns = true
+	})
+	l.invoke("election:OpenElection", "e1")
+	l.invoke("ballot:CastVote", "v1", "e1-alice")
+	l.invoke("ballot:DelegateVote", "v2", "v1", "e1")
+	l.invoke("ballot:CastWriteInVote", "v3", "e1", "Carol")
+	l.invoke("election:CloseElection", "e1")
+	l.certify("e1", "Org1MSP", "Org2MSP")
+
+	l.asUser("Org2MSP", map[string]string{"role": observerRole})
+	l.invoke("election:FileDispute", "d1", "e1", "miscount", `["abc"]`, "[]")
+	l.as("Org1MSP")
+
+	l.invoke("election:CreateElection", "e2", "Council", `["Org1MSP"]`)
+	l.invoke("registry:RegisterCandidate", "e2-carol", "Carol", "e2", "Blue")
+	l.invoke("election:CreateContest", "e2", "council", "Council", "north", `["e2-carol"]`, "1", "1")
+
+	return l
+}
+
+func TestEveryReadTransaction(t *testing.T) {
+	_, keyPEM := newVoterKey(t)
+	args := readTransactionArgs(keyPEM)
+	l := populatedLedger(t)
+
+	// Methods promoted from contractapi.Contract are not transactions.
+	excluded := make(map[string]bool)
+	base := reflect.TypeOf(&contractapi.Contract{})
+	for i := 0; i < base.NumMethod(); i++ {
+		excluded[base.Method(i).Name] = true
+	}
+
+	called := make(map[string]bool)
+	for _, contract := range NewContracts() {
+		contractType := reflect.TypeOf(contract)
+		for i := 0; i < contractType.NumMethod(); i++ {
+			name := contractType.Method(i).Name
+			if excluded[name] || !hasReadOnlyPrefix(name) {
+				continue
+			}
+
+			function := name
+			if contract.GetName() != "" {
+				function = contract.GetName() + ":" + name
+			}
+			transactionArgs, ok := args[name]
+			if !ok {
+				t.Errorf("read transaction %s has no arguments in readTransactionArgs", function)
+				continue
+			}
+			called[name] = true
+
+			response := l.stub.Invoke(l.cc, append([]string{function}, transactionArgs...)...)
+			if response.Status != 200 {
+				t.Errorf("%s%q failed: %s", function, transactionArgs, response.Message)
+			}
+		}
+	}
+
+	for name := range args {
+		if !called[name] {
+			t.Errorf("readTransactionArgs lists %s, which is not a read transaction", name)
+		}
+	}
+}
+
+func hasReadOnlyPrefix(name string) bool {
+	for _, prefix := range readOnlyPrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,41 @@
+package chaincode
+
+import (
+	"github.com/hyperledger/fabric-contract-api-go/v2/contractapi"
+)
+
+const registryContractName = "registry"
+
+// RegistryContext is the transaction context of the registry contract.
+type RegistryContext struct {
+	contractContext
+}
+
+// RegistryContract holds the transactions that register voters, candidates and the electoral geography.
+type RegistryContract struct {
+	contractapi.Contract
+}
+
+func (c *RegistryContract) GetName() string {
+	return registryContractName
+}
+
+func (c *RegistryContract) GetTransactionContextHandler() contractapi.SettableTransactionContextInterface {
+	return new(RegistryContext)
+}
+
+func (c *RegistryContract) GetBeforeTransaction() interface{} {
+	return c.beforeTransaction
+}
+
+func (c *RegistryContract) GetUnknownTransaction() interface{} {
+	return c.unknownTransaction
+}
+
+func (c *RegistryContract) beforeTransaction(ctx *RegistryContext) error {
+	return ctx.RejectWhenPaused()
+}
+
+func (c *RegistryContract) unknownTransaction(ctx *RegistryContext) error {
+	return ctx.unknownTransaction(registryContractName)
+}
@@ -0,0 +1,134 @@
+package chaincode
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/v2/contractapi"
+)
+
+// voterBallotCollection is the private data collection holding the link
+// between a voter and their ballot, see collections_config.json. Ballots in
+// the world state carry no voter ID, but this does not keep the vote secret:
+// the transaction that casts a ballot takes the voter and their choice as
+// arguments and updates the voter record alongside the ballot, so anyone who
+// can read the block can tell who cast which ballot.
+const voterBallotCollection = "voterBallots"
+
+// voterVoteIndex keys the private record of the ballot that currently counts
+// for a voter in an election.
+const voterVoteIndex = "election~voter"
+
+const voterBallotDocType = "voterBallot"
+
+// ballotSaltField is the transient field holding the salt of the ballot ID.
+// Voters who know the salt and the transaction ID can find their ballot.
+const ballotSaltField = "ballotSalt"
+
+// VoterBallot is the private record linking a voter to the ballot that
+// counts for them, along with the voter's signature of signed ballots.
+type VoterBallot struct {
+	DocType       string `json:"docType"`
+	SchemaVersion int    `json:"schemaVersion"`
+	ElectionID    string `json:"electionID"`
+	VoterID       string `json:"voterID"`
+	BallotID      string `json:"ballotID"`
+	Signature     string `json:"signature,omitempty" metadata:",optional"`
+	SignedPayload string `json:"signedPayload,omitempty" metadata:",optional"`
+}
+
+// getVoterVote returns the ballot that currently counts for voterID in the
+// election, or nil if the voter has not voted in it.
+func getVoterVote(ctx contractapi.TransactionContextInterface, electionID string, voterID string) (*Vote, error) {
+	voterBallot, err := getVoterBallot(ctx, electionID, voterID)
+	if err != nil {
+		return nil, err
+	}
+	if voterBallot == nil {
+		return nil, nil
+	}
+
+	voteKey, err := ctx.GetStub().CreateCompositeKey(voteDocType, []string{electionID, voterBallot.BallotID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create vote key: %v", err)
+	}
+	voteJSON, err := ctx.GetStub().GetState(voteKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read vote: %v", err)
+	}
+	if voteJSON == nil {
+		return nil, fmt.Errorf("vote %s does not exist", voterBallot.BallotID)
+	}
+
+	var vote Vote
+	err = unmarshalAsset(voteJSON, &vote)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal vote: %v", err)
+	}
+
+	return &vote, nil
+}
+
+func getVoterBallot(ctx contractapi.TransactionContextInterface, electionID string, voterID string) (*VoterBallot, error) {
+	key, err := ctx.GetStub().CreateCompositeKey(voterVoteIndex, []string{electionID, voterID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create voter ballot key: %v", err)
+	}
+
+	voterBallotJSON, err := ctx.GetStub().GetPrivateData(voterBallotCollection, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read voter ballot: %v", err)
+	}
+	if voterBallotJSON == nil {
+		return nil, nil
+	}
+
+	var voterBallot VoterBallot
+	err = unmarshalAsset(voterBallotJSON, &voterBallot)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal voter ballot: %v", err)
+	}
+
+	return &voterBallot, nil
+}
+
+func putVoterBallot(ctx contractapi.TransactionContextInterface, voterBallot *VoterBallot) error {
+	key, err := ctx.GetStub().CreateCompositeKey(voterVoteIndex, []string{voterBallot.ElectionID, voterBallot.VoterID})
+	if err != nil {
+		return fmt.Errorf("failed to create voter ballot key: %v", err)
+	}
+
+	voterBallotJSON, err := json.Marshal(voterBallot)
+	if err != nil {
+		return fmt.Errorf("failed to marshal voter ballot: %v", err)
+	}
+	err = ctx.GetStub().PutPrivateData(voterBallotCollection, key, voterBallotJSON)
+	if err != nil {
+		return fmt.Errorf("failed to put voter ballot: %v", err)
+	}
+
+	return nil
+}
+
+// ballotID is the ID of the ballot cast by the current transaction: the hex
+// SHA-256 hash of the transaction ID and the salt passed in the transient
+// ballotSalt field.
+func ballotID(ctx contractapi.TransactionContextInterface) (string, error) {
+	transient, err := ctx.GetStub().GetTransient()
+	if err != nil {
+		return "", fmt.Errorf("failed to read transient data: %v", err)
+	}
+
+	hash := sha256.Sum256(append([]byte(ctx.GetStub().GetTxID()+":"), transient[ballotSaltField]...))
+	return hex.EncodeToString(hash[:]), nil
+}
+
+// supersedeVote marks previous as replaced by the ballot ballotID. The caller
+// is responsible for taking its weight away from the previously chosen
+// candidate.
+func supersedeVote(ctx contractapi.TransactionContextInterface, previous *Vote, ballotID string) error {
+	previous.SupersededBy = ballotID
+	return storeVote(ctx, previous)
+}
@@ -0,0 +1,188 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/hyperledger/fabric-chaincode-go/v2/shim"
+	"github.com/hyperledger/fabric-contract-api-go/v2/contractapi"
+)
+
+// errRichQueryUnsupported is returned when the peer keeps its world state in
+// LevelDB, which cannot execute CouchDB selector queries.
+var errRichQueryUnsupported = errors.New("rich queries are not supported by the state database")
+
+// QueryCandidatesByParty returns all candidates registered for party.
+func (c *RegistryContract) QueryCandidatesByParty(ctx *RegistryContext, party string) ([]*Candidate, error) {
+	if len(party) == 0 {
+		return nil, fmt.Errorf("party cannot be empty")
+	}
+
+	query, err := selectorQuery(map[string]interface{}{
+		"docType": candidateDocType,
+		"party":   party,
+	}, "indexCandidatePartyDoc", "indexCandidateParty")
+	if err != nil {
+		return nil, err
+	}
+
+	values, err := getQueryResultValues(ctx, query)
+	if errors.Is(err, errRichQueryUnsupported) {
+		values, err = getRangeValues(ctx)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var candidates []*Candidate
+	for _, value := range values {
+		var candidate Candidate
+		err = unmarshalAsset(value, &candidate)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal candidate: %v", err)
+		}
+		if candidate.DocType == candidateDocType && candidate.Party == party {
+			candidates = append(candidates, &candidate)
+		}
+	}
+
+	return candidates, nil
+}
+
+// QueryVotersByStatus returns all voters whose registration status is status.
+func (c *RegistryContract) QueryVotersByStatus(ctx *RegistryContext, status string) ([]*Voter, error) {
+	if len(status) == 0 {
+		return nil, fmt.Errorf("status cannot be empty")
+	}
+
+	query, err := selectorQuery(map[string]interface{}{
+		"docType": voterDocType,
+		"status":  status,
+	}, "indexVoterStatusDoc", "indexVoterStatus")
+	if err != nil {
+		return nil, err
+	}
+
+	values, err := getQueryResultValues(ctx, query)
+	if errors.Is(err, errRichQueryUnsupported) {
+		values, err = getRangeValues(ctx)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var voters []*Voter
+	for _, value := range values {
+		var voter Voter
+		err = unmarshalAsset(value, &voter)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal voter: %v", err)
+		}
+		if voter.DocType == voterDocType && voter.Status == status {
+			voters = append(voters, &voter)
+		}
+	}
+
+	return voters, nil
+}
+
+// QueryVotesByElection returns the ballots recorded for electionID. Ballots
+// do not name the voter who cast them, though the transactions that cast
+// them do, see voterBallotCollection.
+func (c *ElectionContract) QueryVotesByElection(ctx *ElectionContext, electionID string) ([]*Vote, error) {
+	if len(electionID) == 0 {
+		return nil, fmt.Errorf("electionID cannot be empty")
+	}
+
+	query, err := selectorQuery(map[string]interface{}{
+		"docType":    voteDocType,
+		"electionID": electionID,
+	}, "indexVoteElectionDoc", "indexVoteElection")
+	if err != nil {
+		return nil, err
+	}
+
+	values, err := getQueryResultValues(ctx, query)
+	if errors.Is(err, errRichQueryUnsupported) {
+		values, err = getPartialCompositeKeyValues(ctx, voteDocType, []string{electionID})
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var votes []*Vote
+	for _, value := range values {
+		var vote Vote
+		err = unmarshalAsset(value, &vote)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal vote: %v", err)
+		}
+		if vote.DocType == voteDocType && vote.ElectionID == electionID {
+			votes = append(votes, &vote)
+		}
+	}
+
+	return votes, nil
+}
+
+func selectorQuery(selector map[string]interface{}, designDoc string, indexName string) (string, error) {
+	query := map[string]interface{}{
+		"selector":  selector,
+		"use_index": []string{"_design/" + designDoc, indexName},
+	}
+
+	queryJSON, err := json.Marshal(query)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal query: %v", err)
+	}
+
+	return string(queryJSON), nil
+}
+
+func getQueryResultValues(ctx contractapi.TransactionContextInterface, query string) ([][]byte, error) {
+	resultsIterator, err := ctx.GetStub().GetQueryResult(query)
+	if err != nil {
+		if strings.Contains(err.Error(), "not supported for leveldb") {
+			return nil, errRichQueryUnsupported
+		}
+		return nil, fmt.Errorf("failed to execute query: %v", err)
+	}
+	defer resultsIterator.Close()
+
+	return iteratorValues(resultsIterator)
+}
+
+func getRangeValues(ctx contractapi.TransactionContextInterface) ([][]byte, error) {
+	resultsIterator, err := ctx.GetStub().GetStateByRange("", "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from world state: %v", err)
+	}
+	defer resultsIterator.Close()
+
+	return iteratorValues(resultsIterator)
+}
+
+func getPartialCompositeKeyValues(ctx contractapi.TransactionContextInterface, objectType string, attributes []string) ([][]byte, error) {
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(objectType, attributes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from world state: %v", err)
+	}
+	defer resultsIterator.Close()
+
+	return iteratorValues(resultsIterator)
+}
+
+func iteratorValues(resultsIterator shim.StateQueryIteratorInterface) ([][]byte, error) {
+	var values [][]byte
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("failed to iterate through results: %v", err)
+		}
+		values = append(values, queryResponse.Value)
+	}
+
+	return values, nil
+}
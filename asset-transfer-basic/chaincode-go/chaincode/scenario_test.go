@@ -0,0 +1,589 @@
+package chaincode
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric-samples/asset-transfer-basic/chaincode-go/chaincode/memstub"
+)
+
+// ledger drives the chaincode through an in-memory stub as one of the
+// organization admins.
+type ledger struct {
+	t    *testing.T
+	cc   *Chaincode
+	stub *memstub.Stub
+}
+
+func newLedger(t *testing.T) *ledger {
+	t.Helper()
+	cc, err := NewChaincode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := &ledger{t: t, cc: cc, stub: memstub.New("mychannel")}
+	l.as("Org1MSP")
+	return l
+}
+
+// as submits the following transactions as an admin of mspID.
+func (l *ledger) as(mspID string) {
+	l.t.Helper()
+	identity, err := memstub.NewIdentity(mspID, pkix.Name{CommonName: "admin", OrganizationalUnit: []string{adminRole}}, nil)
+	if err != nil {
+		l.t.Fatal(err)
+	}
+	err = l.stub.SetIdentity(identity)
+	if err != nil {
+		l.t.Fatal(err)
+	}
+}
+
+// asUser submits the following transactions as a client of mspID that is
+// not an admin, with the given certificate attributes.
+func (l *ledger) asUser(mspID string, attributes map[string]string) {
+	l.t.Helper()
+	identity, err := memstub.NewIdentity(mspID, pkix.Name{CommonName: "user"}, attributes)
+	if err != nil {
+		l.t.Fatal(err)
+	}
+	err = l.stub.SetIdentity(identity)
+	if err != nil {
+		l.t.Fatal(err)
+	}
+}
+
+// invoke submits a transaction that must succeed and returns its payload.
+func (l *ledger) invoke(function string, args ...string) []byte {
+	l.t.Helper()
+	response := l.stub.Invoke(l.cc, append([]string{function}, args...)...)
+	if response.Status != 200 {
+		l.t.Fatalf("%s%q failed: %s", function, args, response.Message)
+	}
+	return response.Payload
+}
+
+// reject submits a transaction that must fail with an error containing want.
+func (l *ledger) reject(want string, function string, args ...string) {
+	l.t.Helper()
+	response := l.stub.Invoke(l.cc, append([]string{function}, args...)...)
+	if response.Status == 200 {
+		l.t.Fatalf("%s%q succeeded, want an error containing %q", function, args, want)
+	}
+	if !strings.Contains(response.Message, want) {
+		l.t.Fatalf("%s%q failed with %q, want an error containing %q", function, args, response.Message, want)
+	}
+}
+
+// query invokes a transaction that must succeed and decodes its payload.
+func (l *ledger) query(value interface{}, function string, args ...string) {
+	l.t.Helper()
+	payload := l.invoke(function, args...)
+	err := json.Unmarshal(payload, value)
+	if err != nil {
+		l.t.Fatalf("failed to decode the result of %s: %v", function, err)
+	}
+}
+
+// setRules sets the election rules, starting from the defaults.
+func (l *ledger) setRules(electionID string, update func(rules *ElectionRules)) {
+	l.t.Helper()
+	var rules ElectionRules
+	update(&rules)
+	l.invoke("election:SetElectionRules", electionID, mustJSON(l.t, rules))
+}
+
+func (l *ledger) tally(electionID string) map[string]*CandidateTally {
+	l.t.Helper()
+	var tally []*CandidateTally
+	l.query(&tally, "election:GetTally", electionID)
+
+	byCandidate := make(map[string]*CandidateTally)
+	for _, entry := range tally {
+		byCandidate[entry.CandidateID] = entry
+	}
+	return byCandidate
+}
+
+// certify has every organization of the election certify its tally hash.
+func (l *ledger) certify(electionID string, organizations ...string) {
+	l.t.Helper()
+	for _, mspID := range organizations {
+		l.as(mspID)
+		tallyHash := l.invoke("election:ComputeTallyHash", electionID)
+		l.invoke("election:CertifyResults", electionID, string(tallyHash))
+	}
+	l.as("Org1MSP")
+}
+
+func mustJSON(t *testing.T, value interface{}) string {
+	t.Helper()
+	valueJSON, err := json.Marshal(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(valueJSON)
+}
+
+// newVoterKey returns a voter signing key and its PEM encoded public key.
+func newVoterKey(t *testing.T) (*ecdsa.PrivateKey, string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key, string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: keyDER}))
+}
+
+// sign returns the base64 encoded signature of text, as a voter's client
+// would send it.
+func sign(t *testing.T, key *ecdsa.PrivateKey, text []byte) string {
+	t.Helper()
+	digest := sha256.Sum256(text)
+	signature, err := ecdsa.SignASN1(rand.Reader, key, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return base64.StdEncoding.EncodeToString(signature)
+}
+
+// registerKey registers a new signing key for the voter.
+func (l *ledger) registerKey(voterID string) *ecdsa.PrivateKey {
+	l.t.Helper()
+	key, keyPEM := newVoterKey(l.t)
+	challenge := l.invoke("registry:ComputeKeyChallenge", voterID, keyPEM)
+	l.invoke("registry:RegisterVoterKey", voterID, keyPEM, sign(l.t, key, challenge))
+	return key
+}
+
+// createElection creates an election of Org1MSP and Org2MSP with two
+// candidates and the given voters.
+func (l *ledger) createElection(electionID string, voters ...string) {
+	l.t.Helper()
+	l.invoke("election:CreateElection", electionID, "Mayor", `["Org1MSP","Org2MSP"]`)
+	l.invoke("registry:RegisterCandidate", electionID+"-alice", "Alice", electionID, "Blue")
+	l.invoke("registry:RegisterCandidate", electionID+"-bob", "Bob", electionID, "Green")
+	for _, voterID := range voters {
+		l.invoke("registry:RegisterVoter", voterID, "Voter "+voterID)
+	}
+}
+
+func TestElectionLifecycle(t *testing.T) {
+	l := newLedger(t)
+	l.createElection("e1", "v1", "v2", "v3")
+	l.setRules("e1", func(rules *ElectionRules) { rules.AllowRevote = true })
+
+	l.reject("not open for voting", "ballot:CastVote", "v1", "e1-alice")
+	l.invoke("election:OpenElection", "e1")
+	l.reject("rules can only be changed before it opens", "election:SetElectionRules", "e1", mustJSON(t, ElectionRules{}))
+
+	l.invoke("ballot:CastVote", "v1", "e1-alice")
+	l.invoke("ballot:CastVote", "v2", "e1-alice")
+	// Un-namespaced transactions are routed to the contract that owns them.
+	l.invoke("CastVote", "v3", "e1-bob")
+
+	l.invoke("ballot:CastVote", "v2", "e1-bob")
+	tally := l.tally("e1")
+	if tally["e1-alice"].Votes != 1 || tally["e1-bob"].Votes != 2 {
+		t.Fatalf("tally after revote is alice %d, bob %d, want 1 and 2", tally["e1-alice"].Votes, tally["e1-bob"].Votes)
+	}
+	var votes []*Vote
+	l.query(&votes, "election:QueryVotesByElection", "e1")
+	if len(votes) != 4 {
+		t.Fatalf("election has %d ballots, want 4 including the superseded one", len(votes))
+	}
+
+	l.reject("results can only be certified once it is closed", "election:CertifyResults", "e1", "hash")
+	l.invoke("election:CloseElection", "e1")
+	l.reject("not open for voting", "ballot:CastVote", "v1", "e1-bob")
+
+	l.as("Org3MSP")
+	l.reject("does not participate", "election:CertifyResults", "e1", "hash")
+	l.as("Org1MSP")
+
+	l.certify("e1", "Org1MSP")
+	var election Election
+	l.query(&election, "election:ReadElection", "e1")
+	if election.Status != ElectionStatusClosed {
+		t.Fatalf("election is %s after one of two attestations", election.Status)
+	}
+
+	l.certify("e1", "Org2MSP")
+	l.query(&election, "election:ReadElection", "e1")
+	if election.Status != ElectionStatusCertified {
+		t.Fatalf("election is %s after both attestations", election.Status)
+	}
+	var result ElectionResult
+	l.query(&result, "election:ReadResult", "e1")
+	if len(result.Organizations) != 2 || len(result.Tally) != 2 {
+		t.Fatalf("result is certified by %q with %d tally entries", result.Organizations, len(result.Tally))
+	}
+}
+
+func TestConflictingAttestationDisputesElection(t *testing.T) {
+	l := newLedger(t)
+	l.createElection("e1", "v1")
+	l.invoke("election:OpenElection", "e1")
+	l.invoke("ballot:CastVote", "v1", "e1-alice")
+	l.invoke("election:CloseElection", "e1")
+
+	l.certify("e1", "Org1MSP")
+	l.as("Org2MSP")
+	l.invoke("election:CertifyResults", "e1", "hash")
+	l.as("Org1MSP")
+
+	var election Election
+	l.query(&election, "election:ReadElection", "e1")
+	if election.Status != ElectionStatusDisputed {
+		t.Fatalf("election is %s after conflicting attestations", election.Status)
+	}
+	var attestations []*Attestation
+	l.query(&attestations, "election:GetAttestations", "e1")
+	if len(attestations) != 2 {
+		t.Fatalf("election has %d attestations, want both recorded", len(attestations))
+	}
+}
+
+func TestDisputeSuspendsCertifiedResult(t *testing.T) {
+	l := newLedger(t)
+	l.createElection("e1", "v1")
+	l.invoke("election:OpenElection", "e1")
+	l.invoke("ballot:CastVote", "v1", "e1-alice")
+	l.invoke("election:CloseElection", "e1")
+	l.certify("e1", "Org1MSP", "Org2MSP")
+
+	l.asUser("Org2MSP", map[string]string{"role": observerRole})
+	l.invoke("election:FileDispute", "d1", "e1", "miscount", "[]", "[]")
+	l.as("Org1MSP")
+	var election Election
+	l.query(&election, "election:ReadElection", "e1")
+	if election.Status != ElectionStatusDisputed {
+		t.Fatalf("election is %s after a dispute was filed", election.Status)
+	}
+
+	organizations := `["Org1MSP","Org2MSP"]`
+	l.invoke("ApprovePauseOrganizations", organizations, "2")
+	l.as("Org2MSP")
+	l.invoke("ApprovePauseOrganizations", organizations, "2")
+	l.invoke("SetGlobalPause", "true", "audit", organizations, "2")
+	l.reject(PausedErrorPrefix, "election:RespondToDispute", "d1", "under review")
+	l.reject(PausedErrorPrefix, "election:OrderRecount", "d1", "recount")
+	l.reject(PausedErrorPrefix, "election:ResolveDispute", "d1", "false", "upheld")
+	l.invoke("SetGlobalPause", "false", "", "[]", "0")
+
+	l.invoke("election:ResolveDispute", "d1", "false", "upheld")
+	l.query(&election, "election:ReadElection", "e1")
+	if election.Status != ElectionStatusCertified {
+		t.Fatalf("election is %s after the dispute was dismissed", election.Status)
+	}
+}
+
+func TestRevoteRejectedUnlessAllowed(t *testing.T) {
+	l := newLedger(t)
+	l.createElection("e1", "v1")
+	l.invoke("election:OpenElection", "e1")
+
+	l.invoke("ballot:CastVote", "v1", "e1-alice")
+	l.reject("has already voted", "ballot:CastVote", "v1", "e1-bob")
+	if tally := l.tally("e1"); tally["e1-alice"].Votes != 1 || tally["e1-bob"].Votes != 0 {
+		t.Fatalf("a rejected revote changed the tally")
+	}
+}
+
+func TestGlobalPause(t *testing.T) {
+	l := newLedger(t)
+	l.createElection("e1", "v1")
+	l.invoke("election:OpenElection", "e1")
+
+	organizations := `["Org1MSP","Org2MSP"]`
+	l.reject("has not approved", "SetGlobalPause", "true", "audit", organizations, "2")
+
+	l.invoke("ApprovePauseOrganizations", organizations, "2")
+	l.as("Org2MSP")
+	l.invoke("ApprovePauseOrganizations", organizations, "2")
+	l.invoke("SetGlobalPause", "true", "audit", organizations, "2")
+
+	var status PauseStatus
+	l.query(&status, "GetPauseStatus", "e1")
+	if !status.GlobalPaused {
+		t.Fatalf("pause status is %+v", status)
+	}
+	l.reject(PausedErrorPrefix, "ballot:CastVote", "v1", "e1-alice")
+	l.reject(PausedErrorPrefix, "registry:RegisterVoter", "v2", "Voter v2")
+	l.tally("e1")
+
+	l.invoke("SetGlobalPause", "false", "", "[]", "0")
+	l.invoke("ballot:CastVote", "v1", "e1-alice")
+	if tally := l.tally("e1"); tally["e1-alice"].Votes != 1 {
+		t.Fatalf("vote cast after the pause was lifted is not counted")
+	}
+}
+
+func TestWithdrawnCandidateVotesAreVoided(t *testing.T) {
+	l := newLedger(t)
+	l.createElection("e1", "v1", "v2", "v3")
+	l.setRules("e1", func(rules *ElectionRules) { rules.WithdrawnVotes = WithdrawnVotesVoid })
+	l.invoke("election:OpenElection", "e1")
+
+	l.invoke("ballot:CastVote", "v1", "e1-alice")
+	l.invoke("ballot:CastVote", "v2", "e1-bob")
+
+	l.reject("reason cannot be empty", "registry:WithdrawCandidate", "e1-alice", "")
+	l.invoke("registry:WithdrawCandidate", "e1-alice", "illness")
+	l.reject("has already withdrawn", "registry:WithdrawCandidate", "e1-alice", "illness")
+	l.reject("has withdrawn", "ballot:CastVote", "v3", "e1-alice")
+	l.invoke("ballot:CastVote", "v3", "e1-bob")
+
+	tally := l.tally("e1")
+	alice := tally["e1-alice"]
+	if !alice.Withdrawn || alice.Votes != 0 || alice.VoidedVotes != 1 {
+		t.Fatalf("withdrawn candidate tally is %+v", alice)
+	}
+	if tally["e1-bob"].Votes != 2 {
+		t.Fatalf("candidate tally is %+v", tally["e1-bob"])
+	}
+
+	l.invoke("election:CloseElection", "e1")
+	l.certify("e1", "Org1MSP", "Org2MSP")
+	l.reject("is certified", "registry:WithdrawCandidate", "e1-bob", "late")
+}
+
+func TestContestsAreScopedToTheVoterDistrict(t *testing.T) {
+	l := newLedger(t)
+	l.invoke("registry:CreateConstituency", "north", "North")
+	l.invoke("registry:CreateConstituency", "south", "South")
+	l.invoke("registry:CreatePrecinct", "n1", "North 1", "north")
+	l.invoke("registry:CreatePrecinct", "s1", "South 1", "south")
+
+	l.invoke("election:CreateElection", "e1", "General", `["Org1MSP","Org2MSP"]`)
+	for _, candidateID := range []string{"mayor-a", "mayor-b", "north-a", "south-a"} {
+		l.invoke("registry:RegisterCandidate", candidateID, candidateID, "e1", "")
+	}
+	l.invoke("registry:RegisterVoterInPrecinct", "v1", "Voter 1", "n1")
+	l.invoke("registry:RegisterVoterInPrecinct", "v2", "Voter 2", "s1")
+	l.reject("precinctID cannot be empty", "registry:RegisterVoterInPrecinct", "v3", "Voter 3", "")
+
+	l.invoke("election:CreateContest", "e1", "mayor", "Mayor", "", `["mayor-a","mayor-b"]`, "1", "1")
+	l.invoke("election:CreateContest", "e1", "north", "North council", "north", `["north-a"]`, "0", "1")
+	l.invoke("election:CreateContest", "e1", "south", "South council", "south", `["south-a"]`, "0", "1")
+	l.reject("already exists", "election:CreateContest", "e1", "mayor", "Mayor", "", `["mayor-a"]`, "1", "1")
+
+	var contests []*BallotContest
+	l.query(&contests, "election:GetContests", "e1")
+	if len(contests) != 3 {
+		t.Fatalf("election has %d contests, want 3", len(contests))
+	}
+
+	l.invoke("election:OpenElection", "e1")
+	l.reject("is outside the district", "ballot:CastContestBallot", "v1", "e1",
+		`[{"contestID":"mayor","candidateIDs":["mayor-a"]},{"contestID":"south","candidateIDs":["south-a"]}]`)
+	l.reject("has no selection for contest north", "ballot:CastContestBallot", "v1", "e1",
+		`[{"contestID":"mayor","candidateIDs":["mayor-a"]}]`)
+	l.invoke("ballot:CastContestBallot", "v1", "e1",
+		`[{"contestID":"mayor","candidateIDs":["mayor-a"]},{"contestID":"north","candidateIDs":["north-a"]}]`)
+	l.invoke("ballot:CastContestBallot", "v2", "e1",
+		`[{"contestID":"mayor","candidateIDs":["mayor-b"]},{"contestID":"south","candidateIDs":[]}]`)
+
+	var results []*ContestResult
+	l.query(&results, "election:GetContestResults", "e1")
+	votes := make(map[string]int)
+	for _, result := range results {
+		for _, entry := range result.Tally {
+			votes[result.ContestID+"/"+entry.CandidateID] = entry.Votes
+		}
+	}
+	want := map[string]int{"mayor/mayor-a": 1, "mayor/mayor-b": 1, "north/north-a": 1, "south/south-a": 0}
+	for key, count := range want {
+		if votes[key] != count {
+			t.Fatalf("contest results are %v, want %v", votes, want)
+		}
+	}
+}
+
+func TestSignedDelegation(t *testing.T) {
+	l := newLedger(t)
+	l.createElection("e1", "v1", "v2")
+	l.setRules("e1", func(rules *ElectionRules) {
+		rules.AllowDelegation = true
+		rules.RequireSignedBallots = true
+	})
+	key := l.registerKey("v1")
+	other, _ := newVoterKey(t)
+	l.invoke("election:OpenElection", "e1")
+
+	l.reject("only accepts delegations signed by the voter", "ballot:DelegateVote", "v1", "v2", "e1")
+
+	payload := l.invoke("ballot:ComputeDelegationPayload", mustJSON(t, DelegationPayload{ElectionID: "e1", From: "v1", To: "v2", KeyVersion: 1, Nonce: "n1"}))
+	l.reject("does not match the key of voter v1", "ballot:SubmitSignedDelegation", string(payload), sign(t, other, payload))
+	l.invoke("ballot:SubmitSignedDelegation", string(payload), sign(t, key, payload))
+	l.reject("already submitted", "ballot:SubmitSignedDelegation", string(payload), sign(t, key, payload))
+
+	var delegation Delegation
+	l.query(&delegation, "ballot:ReadDelegation", "v1", "e1")
+	if delegation.To != "v2" {
+		t.Fatalf("v1 delegated to %q, want v2", delegation.To)
+	}
+
+	l.reject("only accepts delegations signed by the voter", "ballot:RevokeDelegation", "v1", "e1")
+	stale := l.invoke("ballot:ComputeDelegationPayload", mustJSON(t, DelegationPayload{ElectionID: "e1", From: "v1", Nonce: "n2"}))
+	l.reject("key version", "ballot:SubmitSignedDelegation", string(stale), sign(t, key, stale))
+	revoke := l.invoke("ballot:ComputeDelegationPayload", mustJSON(t, DelegationPayload{ElectionID: "e1", From: "v1", KeyVersion: 1, Nonce: "n3"}))
+	l.invoke("ballot:SubmitSignedDelegation", string(revoke), sign(t, key, revoke))
+	l.reject("has not delegated", "ballot:ReadDelegation", "v1", "e1")
+}
+
+func TestRegisterVoterKeepsExistingVoters(t *testing.T) {
+	l := newLedger(t)
+	l.createElection("e1", "v1")
+	l.registerKey("v1")
+	l.invoke("election:OpenElection", "e1")
+	l.invoke("ballot:CastVote", "v1", "e1-alice")
+
+	l.reject("voter v1 already exists", "registry:RegisterVoter", "v1", "Voter v1")
+	var results []*BatchResult
+	l.query(&results, "registry:RegisterVotersBatch", `[{"id":"v1","name":"Voter v1"},{"id":"v2","name":"Voter v2"}]`)
+	if results[0].Success || !results[1].Success {
+		t.Fatalf("batch results are %+v and %+v", results[0], results[1])
+	}
+	var voters []*Voter
+	l.query(&voters, "registry:QueryVotersByStatus", VoterStatusRegistered)
+	if len(voters) != 2 || voters[0].ID != "v1" || !voters[0].HasVoted || voters[0].KeyVersion != 1 {
+		t.Fatalf("voter after a rejected re-registration is %+v", voters[0])
+	}
+
+	l.asUser("Org1MSP", nil)
+	l.reject("not authorized", "registry:RegisterVoter", "v3", "Voter v3")
+	l.reject("not authorized", "registry:RegisterVotersBatch", `[{"id":"v3","name":"Voter v3"}]`)
+}
+
+func TestCandidatePagesAreFull(t *testing.T) {
+	l := newLedger(t)
+	l.createElection("e1", "v1", "v2")
+	l.createElection("e2")
+
+	var page PaginatedQueryResult
+	l.query(&page, "GetAllAssetsWithPagination", "3", "")
+	if len(page.Records) != 3 || page.Bookmark == "" {
+		t.Fatalf("first page has %d candidates and bookmark %q, want 3 and a bookmark", len(page.Records), page.Bookmark)
+	}
+	bookmark := page.Bookmark
+	page = PaginatedQueryResult{}
+	l.query(&page, "GetAllAssetsWithPagination", "3", bookmark)
+	if len(page.Records) != 1 {
+		t.Fatalf("second page has %d candidates, want 1", len(page.Records))
+	}
+
+	var candidates []*Candidate
+	l.query(&candidates, "registry:GetCandidatesByElection", "e2")
+	if len(candidates) != 2 || candidates[0].ElectionID != "e2" || candidates[1].ElectionID != "e2" {
+		t.Fatalf("election e2 has candidates %+v", candidates)
+	}
+}
+
+func TestWeightedElection(t *testing.T) {
+	l := newLedger(t)
+	l.createElection("e1", "v1", "v2")
+	l.setRules("e1", func(rules *ElectionRules) { rules.Weighted = true })
+
+	l.reject("transient weight field", "registry:SetVoterWeight", "e1", "v1")
+	l.stub.SetTransient(map[string][]byte{voterWeightField: []byte("3")})
+	l.invoke("registry:SetVoterWeight", "e1", "v1")
+	l.stub.SetTransient(map[string][]byte{voterWeightField: []byte("1")})
+	l.invoke("registry:SetVoterWeight", "e1", "v2")
+	l.stub.SetTransient(nil)
+
+	l.invoke("election:OpenElection", "e1")
+	l.invoke("ballot:CastVote", "v1", "e1-alice")
+	l.invoke("ballot:CastVote", "v2", "e1-bob")
+	l.reject("private until it closes", "election:GetDistrictResults", "e1")
+	l.reject("private until it closes", "election:GetContestResults", "e1")
+	l.invoke("election:CloseElection", "e1")
+	l.invoke("election:GetDistrictResults", "e1")
+
+	tally := l.tally("e1")
+	if tally["e1-alice"].Votes != 3 || tally["e1-bob"].Votes != 1 {
+		t.Fatalf("weighted tally is alice %d, bob %d, want 3 and 1", tally["e1-alice"].Votes, tally["e1-bob"].Votes)
+	}
+}
+
+func TestCandidatesListedInRegistrationOrder(t *testing.T) {
+	l := newLedger(t)
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	l.stub.SetClock(func() time.Time { return now })
+
+	l.invoke("election:CreateElection", "e1", "Mayor", `["Org1MSP"]`)
+	for _, candidateID := range []string{"id-zed", "id-amy", "id-kim"} {
+		now = now.Add(time.Minute)
+		l.invoke("registry:RegisterCandidate", candidateID, "Candidate "+candidateID, "e1", "Blue")
+	}
+
+	var candidates []*Candidate
+	l.query(&candidates, "registry:GetCandidatesByElection", "e1")
+	var order []string
+	for _, candidate := range candidates {
+		order = append(order, candidate.ID)
+	}
+	if strings.Join(order, ",") != "id-zed,id-amy,id-kim" {
+		t.Fatalf("candidates are listed as %q, want the registration order", order)
+	}
+}
+
+func TestCandidateOrderDrawnByLot(t *testing.T) {
+	l := newLedger(t)
+	l.createElection("e1", "v1")
+	l.setRules("e1", func(rules *ElectionRules) { rules.Ordering = OrderingLot })
+
+	commit := func(seed string) string {
+		hash := sha256.Sum256([]byte(seed))
+		return hex.EncodeToString(hash[:])
+	}
+	l.reject("must be drawn before it opens", "election:OpenElection", "e1")
+	l.reject("must be a hex encoded SHA-256 hash", "election:CommitOrderingSeed", "e1", "seed")
+	l.invoke("election:CommitOrderingSeed", "e1", commit("org1 seed"))
+	l.reject("already committed", "election:CommitOrderingSeed", "e1", commit("other seed"))
+	l.reject("candidate list of election e1 closed", "registry:RegisterCandidate", "e1-carol", "Carol", "e1", "Red")
+	l.reject("ordering can no longer change", "election:SetElectionRules", "e1", mustJSON(t, ElectionRules{}))
+	l.reject("must commit to an ordering seed before seeds are revealed", "election:RevealOrderingSeed", "e1", "org1 seed")
+
+	l.as("Org3MSP")
+	l.reject("does not participate", "election:CommitOrderingSeed", "e1", commit("org3 seed"))
+	l.as("Org2MSP")
+	l.invoke("election:CommitOrderingSeed", "e1", commit("org2 seed"))
+	l.reject("does not match the commitment", "election:RevealOrderingSeed", "e1", "org1 seed")
+	l.invoke("election:RevealOrderingSeed", "e1", "org2 seed")
+	l.as("Org1MSP")
+
+	var election Election
+	l.query(&election, "election:ReadElection", "e1")
+	if election.OrderingSeed != "" {
+		t.Fatalf("the order was drawn before every seed was revealed")
+	}
+	l.invoke("election:RevealOrderingSeed", "e1", "org1 seed")
+	l.query(&election, "election:ReadElection", "e1")
+	if election.OrderingSeed == "" {
+		t.Fatalf("the order was not drawn once every seed was revealed")
+	}
+
+	var first, second []*Candidate
+	l.query(&first, "ballot:GetCandidatesForVoter", "e1", "v1")
+	l.invoke("election:OpenElection", "e1")
+	l.query(&second, "ballot:GetCandidatesForVoter", "e1", "v1")
+	if len(first) != 2 || first[0].ID != second[0].ID {
+		t.Fatalf("the drawn order changed when the election opened")
+	}
+}
@@ -0,0 +1,244 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/hyperledger/fabric-chaincode-go/v2/shim"
+	"github.com/hyperledger/fabric-contract-api-go/v2/contractapi"
+)
+
+// currentSchemaVersion is the version stamped on every asset written by this
+// chaincode. Records written before versioning was introduced have version 0.
+const currentSchemaVersion = 1
+
+// schemaUpgrades holds the steps that bring a record from version i to i+1.
+var schemaUpgrades = []func(record map[string]interface{}){
+	upgradeFromV0,
+}
+
+// MigrationResult reports a single page of a state migration.
+type MigrationResult struct {
+	Scanned  int32  `json:"scanned"`
+	Migrated int32  `json:"migrated"`
+	Bookmark string `json:"bookmark"`
+}
+
+// MigrateState rewrites the records of one page at the current schema
+// version. Voters and candidates are migrated when objectType is empty,
+// otherwise the assets stored under that composite key object type. Call it
+// again with the returned bookmark until the bookmark is empty.
+func (s *SmartContract) MigrateState(ctx contractapi.TransactionContextInterface, objectType string, pageSize int32, bookmark string) (*MigrationResult, error) {
+	err := assertAdmin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if pageSize <= 0 {
+		return nil, fmt.Errorf("page size must be positive")
+	}
+
+	// Pagination is not available to update transactions, so the page is
+	// delimited by hand: bookmark is the last key migrated by the previous call.
+	result := &MigrationResult{}
+	if objectType == "" {
+		resultsIterator, err := ctx.GetStub().GetStateByRange(bookmark, "")
+		if err != nil {
+			return nil, fmt.Errorf("failed to read from world state: %v", err)
+		}
+		defer resultsIterator.Close()
+
+		err = migrateRecords(ctx, resultsIterator, objectType, pageSize, bookmark, "", result)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		// A range cannot start at a composite key, so the scan resumes under
+		// the longest prefix of the bookmark's attributes and only moves to a
+		// shorter prefix once the keys under the longer one are exhausted.
+		prefixes, err := resumePrefixes(ctx, bookmark)
+		if err != nil {
+			return nil, err
+		}
+		done := ""
+		for _, prefix := range prefixes {
+			if result.Scanned == pageSize {
+				break
+			}
+			resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(objectType, prefix)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read from world state: %v", err)
+			}
+			err = migrateRecords(ctx, resultsIterator, objectType, pageSize, bookmark, done, result)
+			resultsIterator.Close()
+			if err != nil {
+				return nil, err
+			}
+			done, err = ctx.GetStub().CreateCompositeKey(objectType, prefix)
+			if err != nil {
+				return nil, fmt.Errorf("failed to create key prefix: %v", err)
+			}
+		}
+	}
+	if result.Scanned < pageSize {
+		result.Bookmark = ""
+	}
+
+	return result, nil
+}
+
+// resumePrefixes returns the attribute prefixes of bookmark from the longest
+// to the empty one, which together cover every composite key after it.
+func resumePrefixes(ctx contractapi.TransactionContextInterface, bookmark string) ([][]string, error) {
+	if bookmark == "" {
+		return [][]string{{}}, nil
+	}
+
+	_, attributes, err := ctx.GetStub().SplitCompositeKey(bookmark)
+	if err != nil {
+		return nil, fmt.Errorf("invalid bookmark: %v", err)
+	}
+	prefixes := make([][]string, 0, len(attributes))
+	for length := len(attributes) - 1; length >= 0; length-- {
+		prefixes = append(prefixes, attributes[:length])
+	}
+
+	return prefixes, nil
+}
+
+// migrateRecords migrates the records after bookmark until the page is full,
+// skipping the keys under the prefix done, which were already scanned.
+func migrateRecords(ctx contractapi.TransactionContextInterface, resultsIterator shim.StateQueryIteratorInterface, objectType string, pageSize int32, bookmark string, done string, result *MigrationResult) error {
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return fmt.Errorf("failed to iterate through results: %v", err)
+		}
+		key := queryResponse.Key
+		if bookmark != "" && key <= bookmark {
+			continue
+		}
+		if done != "" && strings.HasPrefix(key, done) {
+			continue
+		}
+		if result.Scanned == pageSize {
+			return nil
+		}
+		result.Scanned++
+		result.Bookmark = key
+
+		record, version, ok := decodeRecord(queryResponse.Value)
+		if !ok {
+			continue
+		}
+		migrated := false
+		if version < currentSchemaVersion {
+			untyped := record["docType"] == nil
+			upgradeRecord(record, version)
+
+			recordJSON, err := json.Marshal(record)
+			if err != nil {
+				return fmt.Errorf("failed to marshal record %s: %v", key, err)
+			}
+			err = ctx.GetStub().PutState(key, recordJSON)
+			if err != nil {
+				return fmt.Errorf("failed to put record %s: %v", key, err)
+			}
+
+			// Candidates registered before versioning are missing from the
+			// election index used by the paginated and tally queries.
+			if untyped && record["docType"] == candidateDocType {
+				electionID, _ := record["electionID"].(string)
+				err = putCandidateElectionIndex(ctx, electionID, key)
+				if err != nil {
+					return err
+				}
+			}
+			migrated = true
+		}
+
+		// Voters registered before the voter index existed are missing from
+		// GetVotersWithPagination.
+		if objectType == "" && record["docType"] == voterDocType {
+			indexed, err := hasVoterIndex(ctx, key)
+			if err != nil {
+				return err
+			}
+			if !indexed {
+				err = putVoterIndex(ctx, key)
+				if err != nil {
+					return err
+				}
+				migrated = true
+			}
+		}
+		if migrated {
+			result.Migrated++
+		}
+	}
+
+	return nil
+}
+
+// unmarshalAsset decodes a stored asset into v, upgrading records written
+// with an older schema version in memory first.
+func unmarshalAsset(data []byte, v interface{}) error {
+	var header struct {
+		SchemaVersion int `json:"schemaVersion"`
+	}
+	if err := json.Unmarshal(data, &header); err != nil || header.SchemaVersion >= currentSchemaVersion {
+		return json.Unmarshal(data, v)
+	}
+
+	record, version, ok := decodeRecord(data)
+	if !ok {
+		return json.Unmarshal(data, v)
+	}
+	upgradeRecord(record, version)
+
+	upgraded, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(upgraded, v)
+}
+
+func decodeRecord(data []byte) (map[string]interface{}, int, bool) {
+	var record map[string]interface{}
+	if err := json.Unmarshal(data, &record); err != nil {
+		return nil, 0, false
+	}
+	version, _ := record["schemaVersion"].(float64)
+	return record, int(version), true
+}
+
+func upgradeRecord(record map[string]interface{}, version int) {
+	for ; version < currentSchemaVersion; version++ {
+		schemaUpgrades[version](record)
+	}
+	record["schemaVersion"] = currentSchemaVersion
+}
+
+// upgradeFromV0 adds the document type and status that voters and candidates
+// were first stored without.
+func upgradeFromV0(record map[string]interface{}) {
+	if record["docType"] == nil {
+		switch {
+		case record["hasVoted"] != nil:
+			record["docType"] = voterDocType
+		case record["electionID"] != nil && record["votes"] != nil:
+			record["docType"] = candidateDocType
+		}
+	}
+
+	status, _ := record["status"].(string)
+	if status != "" {
+		return
+	}
+	switch record["docType"] {
+	case voterDocType:
+		record["status"] = VoterStatusRegistered
+	case candidateDocType:
+		record["status"] = CandidateStatusActive
+	}
+}
@@ -0,0 +1,506 @@
+package chaincode
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"strings"
+
+	"github.com/hyperledger/fabric-contract-api-go/v2/contractapi"
+)
+
+// ballotNonceIndex records the nonces of the signed ballots and delegations
+// of each voter, so that neither can be submitted twice.
+const ballotNonceIndex = "election~voter~nonce"
+
+const trustedRootsDocType = "trustedRoots"
+
+// TrustedRoots are the root certificates, such as those of the national eID
+// authority, that voter certificates must chain to.
+type TrustedRoots struct {
+	DocType       string `json:"docType"`
+	SchemaVersion int    `json:"schemaVersion"`
+	// Certificates are the PEM encoded root certificates.
+	Certificates string `json:"certificates"`
+}
+
+// KeyChallenge is the statement a voter signs with a new signing key to
+// prove they hold it. KeyVersion counts the keys registered for the voter,
+// so a proof cannot be replayed once the key has changed.
+type KeyChallenge struct {
+	VoterID    string `json:"voterID"`
+	KeyHash    string `json:"keyHash"`
+	KeyVersion int    `json:"keyVersion"`
+}
+
+// BallotPayload is the content of a voter-signed ballot. The voter signs its
+// canonical JSON encoding, whose field order is fixed by this type and whose
+// empty optional fields are omitted. A ballot names either a candidate or the
+// selections of a contest ballot.
+type BallotPayload struct {
+	ElectionID     string              `json:"electionID"`
+	VoterID        string              `json:"voterID"`
+	CandidateID    string              `json:"candidateID,omitempty" metadata:",optional"`
+	Selections     []*ContestSelection `json:"selections,omitempty" metadata:",optional"`
+	DefinitionHash string              `json:"definitionHash,omitempty" metadata:",optional"`
+	// Nonce is chosen by the voter and must differ between their ballots.
+	Nonce string `json:"nonce"`
+}
+
+// RegisterVoterKey records the key the voter signs ballots with, given as a
+// PEM encoded public key or X.509 certificate, such as an eID certificate,
+// optionally followed by its intermediate certificates. Certificates must
+// chain to a root set with SetTrustedRoots. ECDSA, RSA and Ed25519 keys are
+// supported. signature is the base64 encoded signature of the challenge
+// returned by ComputeKeyChallenge, made with the new key. The key cannot be
+// changed while an election the voter can vote in is open.
+func (c *RegistryContract) RegisterVoterKey(ctx *RegistryContext, voterID string, keyPEM string, signature string) error {
+	err := ctx.AssertAdmin()
+	if err != nil {
+		return err
+	}
+	publicKey, chain, err := parseVoterKey(keyPEM)
+	if err != nil {
+		return err
+	}
+	err = verifyVoterCertificate(ctx, chain)
+	if err != nil {
+		return err
+	}
+
+	voter, err := ctx.ReadVoter(voterID)
+	if err != nil {
+		return err
+	}
+	if voter.PublicKey == keyPEM {
+		return fmt.Errorf("the signing key of voter %s is already registered", voterID)
+	}
+
+	challenge, err := keyChallenge(voter, keyPEM)
+	if err != nil {
+		return err
+	}
+	signatureBytes, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return fmt.Errorf("signature is not valid base64: %v", err)
+	}
+	if !verifySignature(publicKey, []byte(challenge), signatureBytes) {
+		return fmt.Errorf("the key challenge is not signed by the new key of voter %s", voterID)
+	}
+
+	err = assertNoOpenElection(ctx, voter)
+	if err != nil {
+		return err
+	}
+
+	voter.PublicKey = keyPEM
+	voter.KeyVersion++
+
+	voterJSON, err := json.Marshal(voter)
+	if err != nil {
+		return fmt.Errorf("failed to marshal voter: %v", err)
+	}
+	err = ctx.GetStub().PutState(voterID, voterJSON)
+	if err != nil {
+		return fmt.Errorf("failed to update voter: %v", err)
+	}
+
+	return nil
+}
+
+// ComputeKeyChallenge returns the text a voter signs with keyPEM to register
+// it with RegisterVoterKey.
+func (c *RegistryContract) ComputeKeyChallenge(ctx *RegistryContext, voterID string, keyPEM string) (string, error) {
+	voter, err := ctx.ReadVoter(voterID)
+	if err != nil {
+		return "", err
+	}
+
+	return keyChallenge(voter, keyPEM)
+}
+
+func keyChallenge(voter *Voter, keyPEM string) (string, error) {
+	hash := sha256.Sum256([]byte(keyPEM))
+	challengeJSON, err := json.Marshal(KeyChallenge{
+		VoterID:    voter.ID,
+		KeyHash:    hex.EncodeToString(hash[:]),
+		KeyVersion: voter.KeyVersion,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal key challenge: %v", err)
+	}
+
+	return string(challengeJSON), nil
+}
+
+// assertNoOpenElection rejects a key change while the voter can cast a ballot
+// in an open election, so the key that signs the voter's ballots cannot be
+// swapped during the vote.
+func assertNoOpenElection(ctx contractapi.TransactionContextInterface, voter *Voter) error {
+	values, err := getPartialCompositeKeyValues(ctx, electionDocType, []string{})
+	if err != nil {
+		return err
+	}
+
+	for _, value := range values {
+		var election Election
+		err = unmarshalAsset(value, &election)
+		if err != nil {
+			return fmt.Errorf("failed to unmarshal election: %v", err)
+		}
+		if election.Status != ElectionStatusOpen {
+			continue
+		}
+		if assertEligible(ctx, voter, &election) == nil {
+			return fmt.Errorf("election %s is open, the signing key of voter %s cannot change until it closes", election.ID, voter.ID)
+		}
+	}
+
+	return nil
+}
+
+// SetTrustedRoots replaces the root certificates that voter certificates
+// must chain to. rootsPEM holds one or more PEM encoded CA certificates.
+func (c *RegistryContract) SetTrustedRoots(ctx *RegistryContext, rootsPEM string) error {
+	err := ctx.AssertAdmin()
+	if err != nil {
+		return err
+	}
+
+	roots, err := parseCertificates([]byte(rootsPEM))
+	if err != nil {
+		return err
+	}
+	if len(roots) == 0 {
+		return fmt.Errorf("rootsPEM holds no certificates")
+	}
+	for _, root := range roots {
+		if !root.IsCA {
+			return fmt.Errorf("certificate %s is not a CA certificate", root.Subject)
+		}
+	}
+
+	key, err := ctx.GetStub().CreateCompositeKey(trustedRootsDocType, []string{})
+	if err != nil {
+		return fmt.Errorf("failed to create trusted roots key: %v", err)
+	}
+	rootsJSON, err := json.Marshal(TrustedRoots{
+		DocType:       trustedRootsDocType,
+		SchemaVersion: currentSchemaVersion,
+		Certificates:  rootsPEM,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal trusted roots: %v", err)
+	}
+	err = ctx.GetStub().PutState(key, rootsJSON)
+	if err != nil {
+		return fmt.Errorf("failed to put trusted roots: %v", err)
+	}
+
+	return nil
+}
+
+// GetTrustedRoots returns the root certificates voter certificates must
+// chain to, or nil if none are set.
+func (c *RegistryContract) GetTrustedRoots(ctx *RegistryContext) (*TrustedRoots, error) {
+	return getTrustedRoots(ctx)
+}
+
+func getTrustedRoots(ctx contractapi.TransactionContextInterface) (*TrustedRoots, error) {
+	key, err := ctx.GetStub().CreateCompositeKey(trustedRootsDocType, []string{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create trusted roots key: %v", err)
+	}
+	rootsJSON, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read trusted roots: %v", err)
+	}
+	if rootsJSON == nil {
+		return nil, nil
+	}
+
+	var roots TrustedRoots
+	err = unmarshalAsset(rootsJSON, &roots)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal trusted roots: %v", err)
+	}
+
+	return &roots, nil
+}
+
+// verifyVoterCertificate checks that the leaf of chain, followed by its
+// intermediates, chains to a trusted root and is valid at the transaction
+// time. Bare public keys have no chain and are not checked.
+func verifyVoterCertificate(ctx contractapi.TransactionContextInterface, chain []*x509.Certificate) error {
+	if len(chain) == 0 {
+		return nil
+	}
+
+	roots, err := getTrustedRoots(ctx)
+	if err != nil {
+		return err
+	}
+	if roots == nil {
+		return fmt.Errorf("no trusted roots are set to verify voter certificates against")
+	}
+	rootCerts, err := parseCertificates([]byte(roots.Certificates))
+	if err != nil {
+		return err
+	}
+	timestamp, err := txTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	options := x509.VerifyOptions{
+		Roots:         x509.NewCertPool(),
+		Intermediates: x509.NewCertPool(),
+		CurrentTime:   timestamp,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	}
+	for _, root := range rootCerts {
+		options.Roots.AddCert(root)
+	}
+	for _, intermediate := range chain[1:] {
+		options.Intermediates.AddCert(intermediate)
+	}
+	_, err = chain[0].Verify(options)
+	if err != nil {
+		return fmt.Errorf("the voter certificate is not trusted at %s: %v", timestamp.Format("2006-01-02T15:04:05Z07:00"), err)
+	}
+
+	return nil
+}
+
+// ComputeBallotPayload returns the canonical encoding of payload, which is
+// the exact text the voter signs.
+func (c *BallotContract) ComputeBallotPayload(ctx *BallotContext, payload BallotPayload) (string, error) {
+	return canonicalPayload(payload, "ballot")
+}
+
+// canonicalPayload returns the JSON encoding of a payload the voter signs,
+// whose field order is fixed by its type.
+func canonicalPayload(payload interface{}, kind string) (string, error) {
+	payloadJSON, err := json.Marshal(payload)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal %s payload: %v", kind, err)
+	}
+
+	return string(payloadJSON), nil
+}
+
+// CastSignedBallot casts the ballot in payload after verifying signature, the
+// base64 encoded signature of payload by the voter's registered key. Payload
+// must be the canonical encoding returned by ComputeBallotPayload.
+func (c *BallotContract) CastSignedBallot(ctx *BallotContext, payload string, signature string) error {
+	var ballot BallotPayload
+	err := decodeSignedPayload(payload, &ballot, "ballot")
+	if err != nil {
+		return err
+	}
+	if len(signature) == 0 {
+		return fmt.Errorf("signature cannot be empty")
+	}
+	err = useVoterNonce(ctx, ballot.ElectionID, ballot.VoterID, ballot.Nonce)
+	if err != nil {
+		return err
+	}
+
+	vote := &Vote{Signature: signature, SignedPayload: payload}
+	if len(ballot.Selections) > 0 {
+		if ballot.CandidateID != "" {
+			return fmt.Errorf("a ballot cannot name both a candidate and contest selections")
+		}
+		return castContestBallot(ctx, ballot.VoterID, ballot.ElectionID, ballot.Selections, vote, ballot.DefinitionHash)
+	}
+
+	candidate, err := activeCandidate(ctx, ballot.CandidateID)
+	if err != nil {
+		return err
+	}
+	if candidate.ElectionID != ballot.ElectionID {
+		return fmt.Errorf("candidate %s does not run in election %s", candidate.ID, ballot.ElectionID)
+	}
+	vote.CandidateID = candidate.ID
+
+	return castBallot(ctx, ballot.VoterID, ballot.ElectionID, vote, ballot.DefinitionHash)
+}
+
+// decodeSignedPayload decodes the JSON of a voter-signed payload into value.
+// The payload must be the canonical encoding of value, so that the voter's
+// signature covers exactly what is decoded.
+func decodeSignedPayload(payload string, value interface{}, kind string) error {
+	decoder := json.NewDecoder(strings.NewReader(payload))
+	decoder.DisallowUnknownFields()
+	err := decoder.Decode(value)
+	if err != nil {
+		return fmt.Errorf("failed to unmarshal %s payload: %v", kind, err)
+	}
+	canonical, err := canonicalPayload(value, kind)
+	if err != nil {
+		return err
+	}
+	if canonical != payload {
+		return fmt.Errorf("%s payload is not in canonical form", kind)
+	}
+
+	return nil
+}
+
+// useVoterNonce records that voterID used nonce in a signed ballot or
+// delegation of the election, rejecting a nonce that was already used so
+// that a signed payload cannot be submitted twice.
+func useVoterNonce(ctx contractapi.TransactionContextInterface, electionID string, voterID string, nonce string) error {
+	if len(nonce) == 0 {
+		return fmt.Errorf("nonce cannot be empty")
+	}
+
+	nonceKey, err := ctx.GetStub().CreateCompositeKey(ballotNonceIndex, []string{electionID, voterID, nonce})
+	if err != nil {
+		return fmt.Errorf("failed to create ballot nonce key: %v", err)
+	}
+	used, err := ctx.GetStub().GetState(nonceKey)
+	if err != nil {
+		return fmt.Errorf("failed to read ballot nonce: %v", err)
+	}
+	if used != nil {
+		return fmt.Errorf("payload with nonce %s was already submitted", nonce)
+	}
+	err = ctx.GetStub().PutState(nonceKey, []byte{0x00})
+	if err != nil {
+		return fmt.Errorf("failed to put ballot nonce: %v", err)
+	}
+
+	return nil
+}
+
+// assertBallotSignature verifies the voter's signature of a signed ballot, and
+// rejects unsigned ballots in elections that require signatures.
+func assertBallotSignature(ctx contractapi.TransactionContextInterface, voter *Voter, election *Election, vote *Vote) error {
+	if vote.Signature == "" {
+		if election != nil && election.Rules.RequireSignedBallots {
+			return fmt.Errorf("election %s only accepts ballots signed by the voter", election.ID)
+		}
+		return nil
+	}
+
+	return verifyVoterSignature(ctx, voter, vote.SignedPayload, vote.Signature, "ballot")
+}
+
+// verifyVoterSignature checks that signature, base64 encoded, is the
+// signature of payload by the registered key of voter, and that the key's
+// certificate still chains to a trusted root.
+func verifyVoterSignature(ctx contractapi.TransactionContextInterface, voter *Voter, payload string, signature string, kind string) error {
+	if voter.PublicKey == "" {
+		return fmt.Errorf("voter %s has no registered signing key", voter.ID)
+	}
+
+	publicKey, chain, err := parseVoterKey(voter.PublicKey)
+	if err != nil {
+		return err
+	}
+	err = verifyVoterCertificate(ctx, chain)
+	if err != nil {
+		return fmt.Errorf("voter %s: %v", voter.ID, err)
+	}
+
+	signatureBytes, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return fmt.Errorf("signature is not valid base64: %v", err)
+	}
+	if !verifySignature(publicKey, []byte(payload), signatureBytes) {
+		return fmt.Errorf("%s signature does not match the key of voter %s", kind, voter.ID)
+	}
+
+	return nil
+}
+
+func verifySignature(publicKey crypto.PublicKey, message []byte, signature []byte) bool {
+	digest := sha256.Sum256(message)
+
+	switch key := publicKey.(type) {
+	case *ecdsa.PublicKey:
+		return ecdsa.VerifyASN1(key, digest[:], signature)
+	case *rsa.PublicKey:
+		if rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature) == nil {
+			return true
+		}
+		return rsa.VerifyPSS(key, crypto.SHA256, digest[:], signature, nil) == nil
+	case ed25519.PublicKey:
+		return ed25519.Verify(key, message, signature)
+	default:
+		return false
+	}
+}
+
+// parseVoterKey returns the public key of a PEM encoded public key or
+// certificate. For a certificate it also returns the chain: the certificate
+// followed by any intermediate certificates after it.
+func parseVoterKey(keyPEM string) (crypto.PublicKey, []*x509.Certificate, error) {
+	block, rest := pem.Decode([]byte(keyPEM))
+	if block == nil {
+		return nil, nil, fmt.Errorf("signing key is not PEM encoded")
+	}
+
+	var publicKey crypto.PublicKey
+	var chain []*x509.Certificate
+	var err error
+	switch block.Type {
+	case "CERTIFICATE":
+		chain, err = parseCertificates([]byte(keyPEM))
+		if err != nil {
+			return nil, nil, err
+		}
+		publicKey = chain[0].PublicKey
+	case "PUBLIC KEY":
+		if len(strings.TrimSpace(string(rest))) > 0 {
+			return nil, nil, fmt.Errorf("a public key cannot be followed by other PEM blocks")
+		}
+		publicKey, err = x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to parse public key: %v", err)
+		}
+	default:
+		return nil, nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+
+	switch publicKey.(type) {
+	case *ecdsa.PublicKey, *rsa.PublicKey, ed25519.PublicKey:
+		return publicKey, chain, nil
+	default:
+		return nil, nil, fmt.Errorf("unsupported signing key type %T", publicKey)
+	}
+}
+
+// parseCertificates parses a sequence of PEM encoded certificates.
+func parseCertificates(data []byte) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			return nil, fmt.Errorf("unexpected PEM block %q in certificate chain", block.Type)
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse certificate: %v", err)
+		}
+		certs = append(certs, cert)
+	}
+	if len(strings.TrimSpace(string(data))) > 0 {
+		return nil, fmt.Errorf("certificates are not PEM encoded")
+	}
+
+	return certs, nil
+}
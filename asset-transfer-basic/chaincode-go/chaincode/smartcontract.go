@@ -3,6 +3,7 @@ package chaincode
 import (
 	"encoding/json"
 	"fmt"
+	"time"
 
 	"github.com/hyperledger/fabric-contract-api-go/v2/contractapi"
 )
@@ -11,45 +12,131 @@ type SmartContract struct {
 	contractapi.Contract
 }
 
+const (
+	voterDocType     = "voter"
+	candidateDocType = "candidate"
+	voteDocType      = "vote"
+)
+
+const VoterStatusRegistered = "Registered"
+
 type Voter struct {
-	ID       string `json:"id"`
-	Name     string `json:"name"`
-	HasVoted bool   `json:"hasVoted"`
+	DocType       string `json:"docType"`
+	SchemaVersion int    `json:"schemaVersion"`
+	ID            string `json:"id"`
+	Name          string `json:"name"`
+	Status        string `json:"status"`
+	HasVoted      bool   `json:"hasVoted"`
+	PrecinctID    string `json:"precinctID,omitempty" metadata:",optional"`
+	// PublicKey is the PEM encoded certificate or public key the voter signs
+	// ballots with.
+	PublicKey string `json:"publicKey,omitempty" metadata:",optional"`
+	// KeyVersion counts the signing keys registered for the voter.
+	KeyVersion int `json:"keyVersion,omitempty" metadata:",optional"`
 }
 
+const (
+	CandidateStatusActive    = "Active"
+	CandidateStatusWithdrawn = "Withdrawn"
+)
+
 type Candidate struct {
-	ID         string `json:"id"`
-	Name       string `json:"name"`
-	ElectionID string `json:"electionID"`
-	Votes      int    `json:"votes"`
+	DocType          string    `json:"docType"`
+	SchemaVersion    int       `json:"schemaVersion"`
+	ID               string    `json:"id"`
+	Name             string    `json:"name"`
+	Party            string    `json:"party"`
+	ElectionID       string    `json:"electionID"`
+	Votes            int       `json:"votes"`
+	Status           string    `json:"status"`
+	WithdrawalReason string    `json:"withdrawalReason,omitempty" metadata:",optional"`
+	WithdrawnAt      time.Time `json:"withdrawnAt,omitempty" metadata:",optional"`
+	// RegisteredAt is the timestamp of the transaction that registered the
+	// candidate. Candidates registered before it was recorded have none.
+	RegisteredAt time.Time `json:"registeredAt,omitempty" metadata:",optional"`
 }
 
 type Vote struct {
-	VoterID     string `json:"VoterID"`
-	CandidateID string `json:"candidateID"`
+	DocType       string `json:"docType"`
+	SchemaVersion int    `json:"schemaVersion"`
+	ID            string `json:"id"`
+	ElectionID    string `json:"electionID"`
+	CandidateID   string `json:"candidateID"`
+	WriteIn       string `json:"writeIn,omitempty" metadata:",optional"`
+	SupersededBy  string `json:"supersededBy,omitempty" metadata:",optional"`
+	// DefinitionHash is the ballot definition the vote was cast against.
+	DefinitionHash string `json:"definitionHash,omitempty" metadata:",optional"`
+	// Selections are the choices of a ballot covering the election's
+	// contests. Such a ballot has no CandidateID.
+	Selections []*ContestSelection `json:"selections,omitempty" metadata:",optional"`
+	// Ranked marks selections listed in order of preference, of which only
+	// the first counts for a candidate's votes.
+	Ranked bool `json:"ranked,omitempty" metadata:",optional"`
+	// PrecinctID is the precinct the voter was registered in when voting.
+	PrecinctID string `json:"precinctID,omitempty" metadata:",optional"`
+	// Signature is the voter's signature of SignedPayload, for ballots cast
+	// with CastSignedBallot. Both are kept in the VoterBallot record instead
+	// of the ballot; they are still arguments of the casting transaction.
+	Signature     string `json:"-" metadata:",optional"`
+	SignedPayload string `json:"-" metadata:",optional"`
 }
 
-func (s *SmartContract) RegisterVoter(ctx contractapi.TransactionContextInterface, voterID string, name string) error {
-	voter := Voter{
-		ID:       voterID,
-		Name:     name,
-		HasVoted: false,
+// RegisterVoter registers a voter without a precinct. Such voters can only
+// vote in contests that are not scoped to a constituency.
+func (c *RegistryContract) RegisterVoter(ctx *RegistryContext, voterID string, name string) error {
+	return registerVoter(ctx, voterID, name, "")
+}
+
+// RegisterVoterInPrecinct registers a voter in precinctID, which decides the
+// contests on the voter's ballot.
+func (c *RegistryContract) RegisterVoterInPrecinct(ctx *RegistryContext, voterID string, name string, precinctID string) error {
+	if len(precinctID) == 0 {
+		return fmt.Errorf("precinctID cannot be empty")
 	}
+	return registerVoter(ctx, voterID, name, precinctID)
+}
 
-	voterJSON, err := json.Marshal(voter)
+// registerVoter adds a voter to the roll. An existing voter cannot be
+// registered again, which would reset their vote and signing key.
+func registerVoter(ctx *RegistryContext, voterID string, name string, precinctID string) error {
+	err := ctx.AssertAdmin()
 	if err != nil {
-		return fmt.Errorf("failed to marshal voter: %v", err)
+		return err
 	}
 
-	return ctx.GetStub().PutState(voterID, voterJSON)
+	record := &VoterRecord{ID: voterID, Name: name, PrecinctID: precinctID}
+	return registerVoterRecord(ctx, record, make(map[string]bool))
 }
 
-func (s *SmartContract) RegisterCandidate(ctx contractapi.TransactionContextInterface, candidateID string, name string, electionID string) error {
+func (c *RegistryContract) RegisterCandidate(ctx *RegistryContext, candidateID string, name string, electionID string, party string) error {
+	election, err := getElection(ctx, electionID)
+	if err != nil {
+		return err
+	}
+	err = assertNotPaused(election)
+	if err != nil {
+		return err
+	}
+	err = assertCandidateListOpen(ctx, election)
+	if err != nil {
+		return err
+	}
+
+	timestamp, err := txTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
 	candidate := Candidate{
-		ID:         candidateID,
-		Name:       name,
-		ElectionID: electionID,
-		Votes:      0,
+		DocType:       candidateDocType,
+		SchemaVersion: currentSchemaVersion,
+		ID:            candidateID,
+		Name:          name,
+		Party:         party,
+		ElectionID:    electionID,
+		Votes:         0,
+		Status:        CandidateStatusActive,
+		RegisteredAt:  timestamp,
 	}
 
 	candidateJSON, err := json.Marshal(candidate)
@@ -57,54 +144,190 @@ func (s *SmartContract) RegisterCandidate(ctx contractapi.TransactionContextInte
 		return fmt.Errorf("failed to marshal candidate: %v", err)
 	}
 
-	return ctx.GetStub().PutState(candidateID, candidateJSON)
+	err = ctx.GetStub().PutState(candidateID, candidateJSON)
+	if err != nil {
+		return fmt.Errorf("failed to put candidate: %v", err)
+	}
+
+	return putCandidateElectionIndex(ctx, electionID, candidateID)
 }
 
-func (s *SmartContract) CastVote(ctx contractapi.TransactionContextInterface, voterID string, candidateID string) error {
-	voterJSON, err := ctx.GetStub().GetState(voterID)
+func (c *BallotContract) CastVote(ctx *BallotContext, voterID string, candidateID string) error {
+	candidate, err := activeCandidate(ctx, candidateID)
 	if err != nil {
-		return fmt.Errorf("failed to read voter: %v", err)
+		return err
 	}
-	if voterJSON == nil {
-		return fmt.Errorf("voter %s does not exist", voterID)
+
+	return castVote(ctx, voterID, candidate.ElectionID, voteTarget{candidateID: candidate.ID}, "")
+}
+
+// CastBallot casts a vote like CastVote, and checks that the voter was shown
+// the ballot definition with definitionHash.
+func (c *BallotContract) CastBallot(ctx *BallotContext, voterID string, candidateID string, definitionHash string) error {
+	if len(definitionHash) == 0 {
+		return fmt.Errorf("definitionHash cannot be empty")
 	}
 
-	var voter Voter
-	err = json.Unmarshal(voterJSON, &voter)
+	candidate, err := activeCandidate(ctx, candidateID)
 	if err != nil {
-		return fmt.Errorf("failed to unmarshal voter: %v", err)
+		return err
 	}
 
-	if voter.HasVoted {
-		return fmt.Errorf("voter %s has already voted", voterID)
-	}
+	return castVote(ctx, voterID, candidate.ElectionID, voteTarget{candidateID: candidate.ID}, definitionHash)
+}
 
+// activeCandidate returns the candidate a vote is cast for, who must not have
+// withdrawn.
+func activeCandidate(ctx contractapi.TransactionContextInterface, candidateID string) (*Candidate, error) {
 	candidateJSON, err := ctx.GetStub().GetState(candidateID)
 	if err != nil {
-		return fmt.Errorf("failed to read candidate: %v", err)
+		return nil, fmt.Errorf("failed to read candidate: %v", err)
 	}
 	if candidateJSON == nil {
-		return fmt.Errorf("candidate %s does not exist", candidateID)
+		return nil, fmt.Errorf("candidate %s does not exist", candidateID)
 	}
 
 	var candidate Candidate
-	err = json.Unmarshal(candidateJSON, &candidate)
+	err = unmarshalAsset(candidateJSON, &candidate)
 	if err != nil {
-		return fmt.Errorf("failed to unmarshal candidate: %v", err)
+		return nil, fmt.Errorf("failed to unmarshal candidate: %v", err)
 	}
+	if candidate.Status == CandidateStatusWithdrawn {
+		return nil, fmt.Errorf("candidate %s has withdrawn", candidateID)
+	}
+
+	return &candidate, nil
+}
 
-	candidate.Votes++
-	candidateJSON, err = json.Marshal(candidate)
+// voteTarget is what a ballot is counted for: a registered candidate or a
+// normalized write-in name.
+type voteTarget struct {
+	candidateID string
+	writeIn     string
+}
+
+// targetsOf returns what vote is counted for: every candidate selected on a
+// contest ballot, the first preference in each contest of a ranked ballot,
+// or its single candidate or write-in.
+func targetsOf(vote *Vote) []voteTarget {
+	if len(vote.Selections) == 0 {
+		return []voteTarget{{candidateID: vote.CandidateID, writeIn: vote.WriteIn}}
+	}
+
+	var targets []voteTarget
+	for _, selection := range vote.Selections {
+		for i, candidateID := range selection.CandidateIDs {
+			if vote.Ranked && i > 0 {
+				break
+			}
+			targets = append(targets, voteTarget{candidateID: candidateID})
+		}
+	}
+	return targets
+}
+
+func castVote(ctx contractapi.TransactionContextInterface, voterID string, electionID string, target voteTarget, definitionHash string) error {
+	vote := &Vote{CandidateID: target.candidateID, WriteIn: target.writeIn}
+	return castBallot(ctx, voterID, electionID, vote, definitionHash)
+}
+
+// castBallot records vote, whose choices are already validated, for voterID
+// and counts it for each of its targets.
+func castBallot(ctx contractapi.TransactionContextInterface, voterID string, electionID string, vote *Vote, definitionHash string) error {
+	voter, err := readVoter(ctx, voterID)
 	if err != nil {
-		return fmt.Errorf("failed to marshal candidate: %v", err)
+		return err
 	}
-	err = ctx.GetStub().PutState(candidateID, candidateJSON)
+
+	// Elections that only exist off-chain have no lifecycle to enforce.
+	election, err := getElection(ctx, electionID)
+	if err != nil {
+		return err
+	}
+	err = assertNotPaused(election)
+	if err != nil {
+		return err
+	}
+	if election != nil && election.Status != ElectionStatusOpen {
+		return fmt.Errorf("election %s is not open for voting", electionID)
+	}
+	if election != nil && election.Rules.Mode == ElectionModeCoercionResistant {
+		return fmt.Errorf("election %s only accepts coercion-resistant ballots", electionID)
+	}
+	err = assertEligible(ctx, voter, election)
+	if err != nil {
+		return err
+	}
+	err = assertBallotSignature(ctx, voter, election, vote)
+	if err != nil {
+		return err
+	}
+	vote.DefinitionHash, err = assertBallotDefinition(ctx, election, voter, vote, definitionHash)
+	if err != nil {
+		return err
+	}
+	targets := targetsOf(vote)
+
+	weight, err := votingWeight(ctx, election, voterID)
+	if err != nil {
+		return err
+	}
+
+	vote.ID, err = ballotID(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Count changes are collected per target and applied once at the end,
+	// because writes are not visible to later reads in the same transaction.
+	deltas := make(map[voteTarget]int)
+
+	var previous *Vote
+	if voter.HasVoted {
+		if election != nil && election.Rules.AllowRevote {
+			previous, err = getVoterVote(ctx, electionID, voterID)
+			if err != nil {
+				return err
+			}
+		}
+		if previous == nil {
+			return fmt.Errorf("voter %s has already voted", voterID)
+		}
+
+		err = supersedeVote(ctx, previous, vote.ID)
+		if err != nil {
+			return err
+		}
+		previousWeight, err := voteWeight(ctx, election, previous)
+		if err != nil {
+			return err
+		}
+		for _, target := range targetsOf(previous) {
+			deltas[target] -= previousWeight
+		}
+	} else if election != nil && election.Rules.AllowDelegation {
+		// Voting directly overrides a delegation, so take this voter's
+		// weight back from whoever voted on their behalf.
+		err = reclaimDelegatedWeight(ctx, election, voterID, weight, deltas)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, target := range targets {
+		deltas[target] += weight
+	}
+	err = applyVoteDeltas(ctx, election, electionID, deltas)
 	if err != nil {
-		return fmt.Errorf("failed to update candidate: %v", err)
+		return err
+	}
+	err = putVoteWeight(ctx, election, vote, weight)
+	if err != nil {
+		return err
 	}
 
 	voter.HasVoted = true
-	voterJSON, err = json.Marshal(voter)
+	voterJSON, err := json.Marshal(voter)
 	if err != nil {
 		return fmt.Errorf("failed to marshal voter: %v", err)
 	}
@@ -113,10 +336,52 @@ func (s *SmartContract) CastVote(ctx contractapi.TransactionContextInterface, vo
 		return fmt.Errorf("failed to update voter: %v", err)
 	}
 
+	vote.PrecinctID = voter.PrecinctID
+	return putVote(ctx, voterID, electionID, vote)
+}
+
+// putVote stores the ballot in the world state without the voter, and links
+// it to voterID in the private voter ballot record.
+func putVote(ctx contractapi.TransactionContextInterface, voterID string, electionID string, vote *Vote) error {
+	vote.DocType = voteDocType
+	vote.SchemaVersion = currentSchemaVersion
+	vote.ElectionID = electionID
+
+	err := storeVote(ctx, vote)
+	if err != nil {
+		return err
+	}
+
+	return putVoterBallot(ctx, &VoterBallot{
+		DocType:       voterBallotDocType,
+		SchemaVersion: currentSchemaVersion,
+		ElectionID:    electionID,
+		VoterID:       voterID,
+		BallotID:      vote.ID,
+		Signature:     vote.Signature,
+		SignedPayload: vote.SignedPayload,
+	})
+}
+
+func storeVote(ctx contractapi.TransactionContextInterface, vote *Vote) error {
+	voteKey, err := ctx.GetStub().CreateCompositeKey(voteDocType, []string{vote.ElectionID, vote.ID})
+	if err != nil {
+		return fmt.Errorf("failed to create vote key: %v", err)
+	}
+
+	voteJSON, err := json.Marshal(vote)
+	if err != nil {
+		return fmt.Errorf("failed to marshal vote: %v", err)
+	}
+	err = ctx.GetStub().PutState(voteKey, voteJSON)
+	if err != nil {
+		return fmt.Errorf("failed to put vote: %v", err)
+	}
+
 	return nil
 }
 
-func (s *SmartContract) GetVoteCount(ctx contractapi.TransactionContextInterface, candidateID string) (int, error) {
+func (c *ElectionContract) GetVoteCount(ctx *ElectionContext, candidateID string) (int, error) {
 	candidateJSON, err := ctx.GetStub().GetState(candidateID)
 	if err != nil {
 		return 0, fmt.Errorf("failed to read candidate: %v", err)
@@ -126,7 +391,7 @@ func (s *SmartContract) GetVoteCount(ctx contractapi.TransactionContextInterface
 	}
 
 	var candidate Candidate
-	err = json.Unmarshal(candidateJSON, &candidate)
+	err = unmarshalAsset(candidateJSON, &candidate)
 	if err != nil {
 		return 0, fmt.Errorf("failed to unmarshal candidate: %v", err)
 	}
@@ -149,7 +414,7 @@ func (s *SmartContract) GetAllAssets(ctx contractapi.TransactionContextInterface
 		}
 
 		var candidate Candidate
-		err = json.Unmarshal(queryResponse.Value, &candidate)
+		err = unmarshalAsset(queryResponse.Value, &candidate)
 		if err != nil {
 			return nil, err
 		}
@@ -159,38 +424,103 @@ func (s *SmartContract) GetAllAssets(ctx contractapi.TransactionContextInterface
 	return candidates, nil
 }
 
-func (s *SmartContract) GetCandidatesByElection(ctx contractapi.TransactionContextInterface, electionID string) ([]*Candidate, error) {
+func (c *RegistryContract) GetCandidatesByElection(ctx *RegistryContext, electionID string) ([]*Candidate, error) {
+	return getCandidatesByElection(ctx, electionID)
+}
+
+func getCandidatesByElection(ctx contractapi.TransactionContextInterface, electionID string) ([]*Candidate, error) {
 	if len(electionID) == 0 {
 		return nil, fmt.Errorf("electionID cannot be empty")
 	}
 
-	resultsIterator, err := ctx.GetStub().GetStateByRange("", "")
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(candidateElectionIndex, []string{electionID})
 	if err != nil {
-		return nil, fmt.Errorf("failed to read from world state: %v", err)
+		return nil, fmt.Errorf("failed to read candidate index: %v", err)
 	}
 	defer resultsIterator.Close()
 
-	var candidates []*Candidate
-	for resultsIterator.HasNext() {
-		queryResponse, err := resultsIterator.Next()
+	candidates, err := candidatesFromIndex(ctx, resultsIterator)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("no candidates found for election ID: %s", electionID)
+	}
+
+	election, err := getElection(ctx, electionID)
+	if err != nil {
+		return nil, err
+	}
+
+	return orderCandidates(election, candidates, ""), nil
+}
+
+// applyVoteDeltas adds each delta to the vote count of its candidate or
+// write-in. Elections with weighted ballots count privately until they close.
+func applyVoteDeltas(ctx contractapi.TransactionContextInterface, election *Election, electionID string, deltas map[voteTarget]int) error {
+	targets := sortedTargets(deltas)
+	if weightedBallots(election) {
+		return addWeightedTally(ctx, electionID, targets, deltas)
+	}
+
+	return applyPublicVoteDeltas(ctx, electionID, targets, deltas)
+}
+
+// applyPublicVoteDeltas adds each delta to the vote count in the world state.
+func applyPublicVoteDeltas(ctx contractapi.TransactionContextInterface, electionID string, targets []voteTarget, deltas map[voteTarget]int) error {
+	for _, target := range targets {
+		if target.writeIn != "" {
+			err := addWriteInVotes(ctx, electionID, target.writeIn, deltas[target])
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		candidateJSON, err := ctx.GetStub().GetState(target.candidateID)
 		if err != nil {
-			return nil, fmt.Errorf("failed to iterate through results: %v", err)
+			return fmt.Errorf("failed to read candidate: %v", err)
+		}
+		if candidateJSON == nil {
+			return fmt.Errorf("candidate %s does not exist", target.candidateID)
 		}
 
 		var candidate Candidate
-		err = json.Unmarshal(queryResponse.Value, &candidate)
+		err = unmarshalAsset(candidateJSON, &candidate)
 		if err != nil {
-			return nil, fmt.Errorf("failed to unmarshal candidate: %v", err)
+			return fmt.Errorf("failed to unmarshal candidate: %v", err)
 		}
 
-		if candidate.ElectionID == electionID {
-			candidates = append(candidates, &candidate)
+		candidate.Votes += deltas[target]
+		candidateJSON, err = json.Marshal(candidate)
+		if err != nil {
+			return fmt.Errorf("failed to marshal candidate: %v", err)
+		}
+		err = ctx.GetStub().PutState(candidate.ID, candidateJSON)
+		if err != nil {
+			return fmt.Errorf("failed to update candidate: %v", err)
 		}
 	}
 
-	if len(candidates) == 0 {
-		return nil, fmt.Errorf("no candidates found for election ID: %s", electionID)
+	return nil
+}
+
+// readVoter returns the registered voter stored under voterID.
+func readVoter(ctx contractapi.TransactionContextInterface, voterID string) (*Voter, error) {
+	voterJSON, err := ctx.GetStub().GetState(voterID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read voter: %v", err)
+	}
+	if voterJSON == nil {
+		return nil, fmt.Errorf("voter %s does not exist", voterID)
 	}
 
-	return candidates, nil
+	var voter Voter
+	err = unmarshalAsset(voterJSON, &voter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal voter: %v", err)
+	}
+
+	return &voter, nil
 }
@@ -0,0 +1,270 @@
+package chaincode
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/hyperledger/fabric-contract-api-go/v2/contractapi"
+)
+
+// weightCollection is the private data collection holding voter weights, the
+// weight of each ballot and the running tally of elections whose ballots
+// carry weights, see collections_config.json.
+const weightCollection = "electionWeights"
+
+const (
+	// voterWeightDocType keys the weight of a voter in a weighted election.
+	voterWeightDocType = "weight"
+	// ballotWeightDocType keys the number of votes a ballot counts for.
+	ballotWeightDocType = "ballotWeight"
+	// weightedTallyDocType keys the running vote count of a candidate or
+	// write-in while the election is open.
+	weightedTallyDocType = "weightedTally"
+)
+
+// voterWeightField is the transient field SetVoterWeight reads the weight
+// from, so that it is not recorded in the transaction.
+const voterWeightField = "weight"
+
+// SetVoterWeight records the weight of a voter, such as a share count, in a
+// weighted election. The weight is passed as a decimal number in the
+// transient weight field. Weights can only be set before the election opens.
+func (c *RegistryContract) SetVoterWeight(ctx *RegistryContext, electionID string, voterID string) error {
+	err := ctx.AssertAdmin()
+	if err != nil {
+		return err
+	}
+
+	transient, err := ctx.GetStub().GetTransient()
+	if err != nil {
+		return fmt.Errorf("failed to read transient data: %v", err)
+	}
+	weightText, ok := transient[voterWeightField]
+	if !ok {
+		return fmt.Errorf("the voter weight must be passed in the transient %s field", voterWeightField)
+	}
+	weight, err := strconv.Atoi(string(weightText))
+	if err != nil {
+		return fmt.Errorf("weight is not a number: %v", err)
+	}
+	if weight <= 0 {
+		return fmt.Errorf("weight must be greater than zero")
+	}
+
+	election, err := ctx.ReadElection(electionID)
+	if err != nil {
+		return err
+	}
+	err = assertNotPaused(election)
+	if err != nil {
+		return err
+	}
+	if !election.Rules.Weighted {
+		return fmt.Errorf("election %s is not a weighted election", electionID)
+	}
+	if election.Status != ElectionStatusCreated {
+		return fmt.Errorf("election %s is %s, weights can only be set before it opens", electionID, election.Status)
+	}
+
+	_, err = ctx.ReadVoter(voterID)
+	if err != nil {
+		return err
+	}
+
+	return putPrivateCount(ctx, voterWeightDocType, []string{electionID, voterID}, weight)
+}
+
+// ReadVoterWeight returns the weight of a voter in a weighted election.
+func (c *RegistryContract) ReadVoterWeight(ctx *RegistryContext, electionID string, voterID string) (int, error) {
+	err := ctx.AssertAdmin()
+	if err != nil {
+		return 0, err
+	}
+
+	election, err := ctx.ReadElection(electionID)
+	if err != nil {
+		return 0, err
+	}
+
+	return registeredWeight(ctx, election, voterID)
+}
+
+// registeredWeight is the weight of voterID's own vote: the registered weight
+// in weighted elections and one otherwise.
+func registeredWeight(ctx contractapi.TransactionContextInterface, election *Election, voterID string) (int, error) {
+	if election == nil || !election.Rules.Weighted {
+		return 1, nil
+	}
+
+	weight, found, err := getPrivateCount(ctx, voterWeightDocType, []string{election.ID, voterID})
+	if err != nil {
+		return 0, err
+	}
+	if !found {
+		return 0, fmt.Errorf("voter %s has no weight in election %s", voterID, election.ID)
+	}
+
+	return weight, nil
+}
+
+// weightedBallots reports whether ballots of the election can count for more
+// than one vote. Their weights are kept out of the world state, and so is
+// the tally until the election closes, since each change of a candidate's
+// count would reveal the weight of the ballot behind it.
+func weightedBallots(election *Election) bool {
+	return election != nil && (election.Rules.Weighted || election.Rules.AllowDelegation)
+}
+
+// voteWeight is the number of votes a ballot counts for.
+func voteWeight(ctx contractapi.TransactionContextInterface, election *Election, vote *Vote) (int, error) {
+	if !weightedBallots(election) {
+		return 1, nil
+	}
+
+	weight, found, err := getPrivateCount(ctx, ballotWeightDocType, []string{election.ID, vote.ID})
+	if err != nil {
+		return 0, err
+	}
+	if !found {
+		return 1, nil
+	}
+
+	return weight, nil
+}
+
+func putVoteWeight(ctx contractapi.TransactionContextInterface, election *Election, vote *Vote, weight int) error {
+	if !weightedBallots(election) {
+		return nil
+	}
+
+	return putPrivateCount(ctx, ballotWeightDocType, []string{election.ID, vote.ID}, weight)
+}
+
+// addWeightedTally adds deltas to the private running tally of the election.
+func addWeightedTally(ctx contractapi.TransactionContextInterface, electionID string, targets []voteTarget, deltas map[voteTarget]int) error {
+	for _, target := range targets {
+		attributes := weightedTallyAttributes(electionID, target)
+		votes, _, err := getPrivateCount(ctx, weightedTallyDocType, attributes)
+		if err != nil {
+			return err
+		}
+		err = putPrivateCount(ctx, weightedTallyDocType, attributes, votes+deltas[target])
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// assertResultsPublished rejects breaking down the tally of an election whose
+// ballots carry weights before the election closes, since per-district or
+// per-contest counts would reveal the weights behind the ballots just like
+// the running tally.
+func assertResultsPublished(election *Election) error {
+	if !weightedBallots(election) {
+		return nil
+	}
+	if election.Status == ElectionStatusCreated || election.Status == ElectionStatusOpen {
+		return fmt.Errorf("election %s is %s, its results are private until it closes", election.ID, election.Status)
+	}
+
+	return nil
+}
+
+// publishWeightedTally moves the private running tally of the election into
+// the candidates and write-ins once voting has ended.
+func publishWeightedTally(ctx contractapi.TransactionContextInterface, election *Election) error {
+	resultsIterator, err := ctx.GetStub().GetPrivateDataByPartialCompositeKey(weightCollection, weightedTallyDocType, []string{election.ID})
+	if err != nil {
+		return fmt.Errorf("failed to read weighted tally: %v", err)
+	}
+	defer resultsIterator.Close()
+
+	deltas := make(map[voteTarget]int)
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return fmt.Errorf("failed to iterate through results: %v", err)
+		}
+
+		_, keyParts, err := ctx.GetStub().SplitCompositeKey(queryResponse.Key)
+		if err != nil {
+			return fmt.Errorf("failed to split weighted tally key: %v", err)
+		}
+		if len(keyParts) != 3 {
+			return fmt.Errorf("malformed weighted tally key: %s", queryResponse.Key)
+		}
+		votes, err := strconv.Atoi(string(queryResponse.Value))
+		if err != nil {
+			return fmt.Errorf("failed to parse weighted tally: %v", err)
+		}
+
+		target := voteTarget{candidateID: keyParts[2]}
+		if keyParts[1] == "writeIn" {
+			target = voteTarget{writeIn: keyParts[2]}
+		}
+		deltas[target] += votes
+	}
+
+	return applyPublicVoteDeltas(ctx, election.ID, sortedTargets(deltas), deltas)
+}
+
+func weightedTallyAttributes(electionID string, target voteTarget) []string {
+	if target.writeIn != "" {
+		return []string{electionID, "writeIn", target.writeIn}
+	}
+	return []string{electionID, "candidate", target.candidateID}
+}
+
+func sortedTargets(deltas map[voteTarget]int) []voteTarget {
+	targets := make([]voteTarget, 0, len(deltas))
+	for target, delta := range deltas {
+		if delta != 0 {
+			targets = append(targets, target)
+		}
+	}
+	sort.Slice(targets, func(i, j int) bool {
+		if targets[i].candidateID != targets[j].candidateID {
+			return targets[i].candidateID < targets[j].candidateID
+		}
+		return targets[i].writeIn < targets[j].writeIn
+	})
+
+	return targets
+}
+
+func getPrivateCount(ctx contractapi.TransactionContextInterface, objectType string, attributes []string) (int, bool, error) {
+	key, err := ctx.GetStub().CreateCompositeKey(objectType, attributes)
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to create %s key: %v", objectType, err)
+	}
+	value, err := ctx.GetStub().GetPrivateData(weightCollection, key)
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to read %s: %v", objectType, err)
+	}
+	if value == nil {
+		return 0, false, nil
+	}
+
+	count, err := strconv.Atoi(string(value))
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to parse %s: %v", objectType, err)
+	}
+
+	return count, true, nil
+}
+
+func putPrivateCount(ctx contractapi.TransactionContextInterface, objectType string, attributes []string, count int) error {
+	key, err := ctx.GetStub().CreateCompositeKey(objectType, attributes)
+	if err != nil {
+		return fmt.Errorf("failed to create %s key: %v", objectType, err)
+	}
+	err = ctx.GetStub().PutPrivateData(weightCollection, key, []byte(strconv.Itoa(count)))
+	if err != nil {
+		return fmt.Errorf("failed to put %s: %v", objectType, err)
+	}
+
+	return nil
+}
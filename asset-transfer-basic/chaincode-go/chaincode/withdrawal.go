@@ -0,0 +1,78 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Policies for the votes already cast for a candidate who withdraws.
+const (
+	// WithdrawnVotesKeep counts the votes as if the candidate had not withdrawn.
+	WithdrawnVotesKeep = ""
+	// WithdrawnVotesVoid excludes the votes from the tally.
+	WithdrawnVotesVoid = "void"
+	// WithdrawnVotesSeparate reports the votes apart from the other candidates.
+	WithdrawnVotesSeparate = "separate"
+)
+
+// WithdrawCandidate takes a candidate off the ballot. No further votes can be
+// cast for the candidate. Withdrawal is not possible once the election results
+// are certified.
+func (c *RegistryContract) WithdrawCandidate(ctx *RegistryContext, candidateID string, reason string) error {
+	err := ctx.AssertAdmin()
+	if err != nil {
+		return err
+	}
+	if len(reason) == 0 {
+		return fmt.Errorf("reason cannot be empty")
+	}
+
+	candidateJSON, err := ctx.GetStub().GetState(candidateID)
+	if err != nil {
+		return fmt.Errorf("failed to read candidate: %v", err)
+	}
+	if candidateJSON == nil {
+		return fmt.Errorf("candidate %s does not exist", candidateID)
+	}
+
+	var candidate Candidate
+	err = unmarshalAsset(candidateJSON, &candidate)
+	if err != nil {
+		return fmt.Errorf("failed to unmarshal candidate: %v", err)
+	}
+	if candidate.Status == CandidateStatusWithdrawn {
+		return fmt.Errorf("candidate %s has already withdrawn", candidateID)
+	}
+
+	election, err := getElection(ctx, candidate.ElectionID)
+	if err != nil {
+		return err
+	}
+	err = assertNotPaused(election)
+	if err != nil {
+		return err
+	}
+	if election != nil && election.Status == ElectionStatusCertified {
+		return fmt.Errorf("election %s is certified, candidates can no longer withdraw", candidate.ElectionID)
+	}
+
+	timestamp, err := txTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	candidate.Status = CandidateStatusWithdrawn
+	candidate.WithdrawalReason = reason
+	candidate.WithdrawnAt = timestamp
+
+	candidateJSON, err = json.Marshal(candidate)
+	if err != nil {
+		return fmt.Errorf("failed to marshal candidate: %v", err)
+	}
+	err = ctx.GetStub().PutState(candidateID, candidateJSON)
+	if err != nil {
+		return fmt.Errorf("failed to update candidate: %v", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,244 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/v2/contractapi"
+	"golang.org/x/text/unicode/norm"
+)
+
+const writeInDocType = "writeIn"
+
+// WriteInTallyID is the tally entry under which write-in votes are counted
+// until officials adjudicate them.
+const WriteInTallyID = "write-in"
+
+// WriteInAdjudication records officials assigning a write-in to a candidate.
+type WriteInAdjudication struct {
+	CandidateID string    `json:"candidateID"`
+	Votes       int       `json:"votes"`
+	Actor       string    `json:"actor"`
+	Note        string    `json:"note"`
+	TxID        string    `json:"txId"`
+	Timestamp   time.Time `json:"timestamp"`
+}
+
+// WriteIn holds the votes cast for one normalized write-in name. Votes are
+// the votes not yet assigned to a registered candidate.
+type WriteIn struct {
+	DocType       string                 `json:"docType"`
+	SchemaVersion int                    `json:"schemaVersion"`
+	ElectionID    string                 `json:"electionID"`
+	Name          string                 `json:"name"`
+	Votes         int                    `json:"votes"`
+	Adjudications []*WriteInAdjudication `json:"adjudications,omitempty" metadata:",optional"`
+}
+
+// CastWriteInVote casts a vote for a name that is not on the ballot.
+func (c *BallotContract) CastWriteInVote(ctx *BallotContext, voterID string, electionID string, name string) error {
+	election, err := ctx.ReadElection(electionID)
+	if err != nil {
+		return err
+	}
+	if !election.Rules.AllowWriteIns {
+		return fmt.Errorf("election %s does not allow write-in votes", electionID)
+	}
+
+	writeIn := normalizeWriteIn(name)
+	if len(writeIn) == 0 {
+		return fmt.Errorf("write-in name cannot be empty")
+	}
+
+	return castVote(ctx, voterID, electionID, voteTarget{writeIn: writeIn}, "")
+}
+
+// AdjudicateWriteIn assigns the votes of a write-in to a registered
+// candidate. Calling it for several spellings of the same name merges them
+// into that candidate. Adjudication happens after the election closes and
+// before its results are certified.
+func (c *ElectionContract) AdjudicateWriteIn(ctx *ElectionContext, electionID string, name string, candidateID string, note string) error {
+	err := ctx.AssertAdmin()
+	if err != nil {
+		return err
+	}
+
+	election, err := ctx.ReadElection(electionID)
+	if err != nil {
+		return err
+	}
+	err = assertNotPaused(election)
+	if err != nil {
+		return err
+	}
+	if election.Status != ElectionStatusClosed {
+		return fmt.Errorf("election %s is %s, write-ins can only be adjudicated once it is closed", electionID, election.Status)
+	}
+
+	writeIn, err := getWriteIn(ctx, electionID, normalizeWriteIn(name))
+	if err != nil {
+		return err
+	}
+	if writeIn == nil {
+		return fmt.Errorf("no write-in votes for %q in election %s", name, electionID)
+	}
+	if writeIn.Votes == 0 {
+		return fmt.Errorf("write-in %q has no votes left to adjudicate", writeIn.Name)
+	}
+
+	candidateJSON, err := ctx.GetStub().GetState(candidateID)
+	if err != nil {
+		return fmt.Errorf("failed to read candidate: %v", err)
+	}
+	if candidateJSON == nil {
+		return fmt.Errorf("candidate %s does not exist", candidateID)
+	}
+	var candidate Candidate
+	err = unmarshalAsset(candidateJSON, &candidate)
+	if err != nil {
+		return fmt.Errorf("failed to unmarshal candidate: %v", err)
+	}
+	if candidate.ElectionID != electionID {
+		return fmt.Errorf("candidate %s does not run in election %s", candidateID, electionID)
+	}
+
+	actor, err := clientActor(ctx)
+	if err != nil {
+		return err
+	}
+	timestamp, err := txTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	candidate.Votes += writeIn.Votes
+	candidateJSON, err = json.Marshal(candidate)
+	if err != nil {
+		return fmt.Errorf("failed to marshal candidate: %v", err)
+	}
+	err = ctx.GetStub().PutState(candidateID, candidateJSON)
+	if err != nil {
+		return fmt.Errorf("failed to update candidate: %v", err)
+	}
+
+	writeIn.Adjudications = append(writeIn.Adjudications, &WriteInAdjudication{
+		CandidateID: candidateID,
+		Votes:       writeIn.Votes,
+		Actor:       actor,
+		Note:        note,
+		TxID:        ctx.GetStub().GetTxID(),
+		Timestamp:   timestamp,
+	})
+	writeIn.Votes = 0
+
+	return putWriteIn(ctx, writeIn)
+}
+
+// GetWriteIns returns every write-in name voted for in the election together
+// with its adjudication trail.
+func (c *ElectionContract) GetWriteIns(ctx *ElectionContext, electionID string) ([]*WriteIn, error) {
+	values, err := getPartialCompositeKeyValues(ctx, writeInDocType, []string{electionID})
+	if err != nil {
+		return nil, err
+	}
+
+	var writeIns []*WriteIn
+	for _, value := range values {
+		var writeIn WriteIn
+		err = unmarshalAsset(value, &writeIn)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal write-in: %v", err)
+		}
+		writeIns = append(writeIns, &writeIn)
+	}
+
+	return writeIns, nil
+}
+
+// normalizeWriteIn folds the different ways of writing the same name into
+// one form: Unicode NFKC, lower case and single spaces.
+func normalizeWriteIn(name string) string {
+	name = norm.NFKC.String(name)
+	return strings.ToLower(strings.Join(strings.Fields(name), " "))
+}
+
+func addWriteInVotes(ctx contractapi.TransactionContextInterface, electionID string, name string, delta int) error {
+	writeIn, err := getWriteIn(ctx, electionID, name)
+	if err != nil {
+		return err
+	}
+	if writeIn == nil {
+		writeIn = &WriteIn{
+			DocType:       writeInDocType,
+			SchemaVersion: currentSchemaVersion,
+			ElectionID:    electionID,
+			Name:          name,
+		}
+	}
+
+	writeIn.Votes += delta
+	return putWriteIn(ctx, writeIn)
+}
+
+func unadjudicatedWriteInVotes(ctx contractapi.TransactionContextInterface, electionID string) (int, error) {
+	values, err := getPartialCompositeKeyValues(ctx, writeInDocType, []string{electionID})
+	if err != nil {
+		return 0, err
+	}
+
+	votes := 0
+	for _, value := range values {
+		var writeIn WriteIn
+		err = unmarshalAsset(value, &writeIn)
+		if err != nil {
+			return 0, fmt.Errorf("failed to unmarshal write-in: %v", err)
+		}
+		votes += writeIn.Votes
+	}
+
+	return votes, nil
+}
+
+func getWriteIn(ctx contractapi.TransactionContextInterface, electionID string, name string) (*WriteIn, error) {
+	key, err := ctx.GetStub().CreateCompositeKey(writeInDocType, []string{electionID, name})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create write-in key: %v", err)
+	}
+
+	writeInJSON, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read write-in: %v", err)
+	}
+	if writeInJSON == nil {
+		return nil, nil
+	}
+
+	var writeIn WriteIn
+	err = unmarshalAsset(writeInJSON, &writeIn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal write-in: %v", err)
+	}
+
+	return &writeIn, nil
+}
+
+func putWriteIn(ctx contractapi.TransactionContextInterface, writeIn *WriteIn) error {
+	key, err := ctx.GetStub().CreateCompositeKey(writeInDocType, []string{writeIn.ElectionID, writeIn.Name})
+	if err != nil {
+		return fmt.Errorf("failed to create write-in key: %v", err)
+	}
+
+	writeInJSON, err := json.Marshal(writeIn)
+	if err != nil {
+		return fmt.Errorf("failed to marshal write-in: %v", err)
+	}
+
+	err = ctx.GetStub().PutState(key, writeInJSON)
+	if err != nil {
+		return fmt.Errorf("failed to put write-in: %v", err)
+	}
+
+	return nil
+}
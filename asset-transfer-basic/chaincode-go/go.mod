@@ -7,6 +7,7 @@ require (
 	github.com/hyperledger/fabric-contract-api-go/v2 v2.0.0
 	github.com/hyperledger/fabric-protos-go-apiv2 v0.3.3
 	github.com/stretchr/testify v1.9.0
+	golang.org/x/text v0.14.0
 	google.golang.org/protobuf v1.34.2
 )
 
@@ -30,7 +31,6 @@ require (
 	golang.org/x/mod v0.17.0 // indirect
 	golang.org/x/net v0.24.0 // indirect
 	golang.org/x/sys v0.19.0 // indirect
-	golang.org/x/text v0.14.0 // indirect
 	google.golang.org/genproto v0.0.0-20230410155749-daa745c078e1 // indirect
 	google.golang.org/grpc v1.64.0 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
@@ -0,0 +1,175 @@
+// Command jcj-tally is the off-chain tooling for credential elections. It
+// generates the tallying authority key, issues and encrypts credentials, and
+// filters the ballots exported from the ledger before the surviving ballot
+// IDs are submitted with SubmitFilteredTally. The filter runs with the whole
+// private key in one process, see package jcj for what that means for
+// ballot secrecy.
+//
+//	jcj-tally keygen
+//	jcj-tally credential -pub <public key>
+//	jcj-tally encrypt -pub <public key> -credential <credential>
+//	jcj-tally filter -key <private key> -roll roll.json -ballots ballots.json
+//
+// roll.json and ballots.json are the responses of the GetCredentialRoll and
+// GetCoercionResistantBallots chaincode functions.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"rest-api-go/pkg/jcj"
+)
+
+// credentialRecord is an entry of the credential roll. Only the encrypted
+// credential is read; the roll still names the voter of each entry, which
+// the holder of the private key can match to the counted ballots.
+type credentialRecord struct {
+	EncryptedCredential string `json:"encryptedCredential"`
+}
+
+type credentialBallot struct {
+	ID                  string `json:"id"`
+	EncryptedCredential string `json:"encryptedCredential"`
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		log.Fatalf("usage: jcj-tally keygen|credential|encrypt|filter [flags]")
+	}
+
+	var err error
+	switch os.Args[1] {
+	case "keygen":
+		err = keygen()
+	case "credential":
+		err = credential(os.Args[2:])
+	case "encrypt":
+		err = encrypt(os.Args[2:])
+	case "filter":
+		err = filter(os.Args[2:])
+	default:
+		err = fmt.Errorf("unknown command %q", os.Args[1])
+	}
+	if err != nil {
+		log.Fatalln(err)
+	}
+}
+
+func keygen() error {
+	key, err := jcj.GenerateKey()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("private key: %s\npublic key:  %s\n", key, &key.PublicKey)
+	return nil
+}
+
+// credential issues a new credential together with an encryption of it. The
+// registrar posts the encryption with RegisterVoterCredential; a voter who
+// needs a fake credential simply keeps the credential line.
+func credential(args []string) error {
+	flags := flag.NewFlagSet("credential", flag.ExitOnError)
+	pub := flags.String("pub", "", "tallying authority public key")
+	flags.Parse(args)
+
+	publicKey, err := jcj.ParsePublicKey(*pub)
+	if err != nil {
+		return err
+	}
+	cred, err := jcj.NewCredential()
+	if err != nil {
+		return err
+	}
+	ciphertext, err := publicKey.Encrypt(cred)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("credential: %s\nencrypted:  %s\n", cred, ciphertext)
+	return nil
+}
+
+// encrypt produces the fresh encryption a voter attaches to a ballot.
+func encrypt(args []string) error {
+	flags := flag.NewFlagSet("encrypt", flag.ExitOnError)
+	pub := flags.String("pub", "", "tallying authority public key")
+	value := flags.String("credential", "", "real or fake credential")
+	flags.Parse(args)
+
+	publicKey, err := jcj.ParsePublicKey(*pub)
+	if err != nil {
+		return err
+	}
+	cred, err := jcj.ParseCredential(*value)
+	if err != nil {
+		return err
+	}
+	ciphertext, err := publicKey.Encrypt(cred)
+	if err != nil {
+		return err
+	}
+	fmt.Println(ciphertext)
+	return nil
+}
+
+func filter(args []string) error {
+	flags := flag.NewFlagSet("filter", flag.ExitOnError)
+	keyValue := flags.String("key", "", "tallying authority private key")
+	rollPath := flags.String("roll", "", "GetCredentialRoll response")
+	ballotsPath := flags.String("ballots", "", "GetCoercionResistantBallots response")
+	flags.Parse(args)
+
+	key, err := jcj.ParsePrivateKey(*keyValue)
+	if err != nil {
+		return err
+	}
+
+	var records []credentialRecord
+	if err := readJSON(*rollPath, &records); err != nil {
+		return err
+	}
+	roll := make([]*jcj.Ciphertext, 0, len(records))
+	for i, record := range records {
+		ciphertext, err := jcj.ParseCiphertext(record.EncryptedCredential)
+		if err != nil {
+			return fmt.Errorf("credential %d of the roll: %w", i, err)
+		}
+		roll = append(roll, ciphertext)
+	}
+
+	var cast []credentialBallot
+	if err := readJSON(*ballotsPath, &cast); err != nil {
+		return err
+	}
+	ballots := make([]jcj.Ballot, 0, len(cast))
+	for _, ballot := range cast {
+		ciphertext, err := jcj.ParseCiphertext(ballot.EncryptedCredential)
+		if err != nil {
+			// A ballot that cannot be parsed cannot carry a real credential.
+			log.Printf("skipping ballot %s: %v", ballot.ID, err)
+			continue
+		}
+		ballots = append(ballots, jcj.Ballot{ID: ballot.ID, Credential: ciphertext})
+	}
+
+	accepted, err := key.Filter(roll, ballots)
+	if err != nil {
+		return err
+	}
+	log.Printf("%d of %d ballots accepted", len(accepted), len(cast))
+
+	return json.NewEncoder(os.Stdout).Encode(accepted)
+}
+
+func readJSON(path string, v any) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return nil
+}
@@ -1,8 +1,8 @@
 package config
 
 import (
-	"fmt"
 	"gopkg.in/yaml.v3"
+	"log"
 	"os"
 	"path/filepath"
 )
@@ -10,6 +10,7 @@ import (
 type Config struct {
 	Database      Postgres `yaml:"postgres"`
 	RedisDatabase Redis    `yaml:"redisDatabase"`
+	Fabric        Fabric   `yaml:"fabric"`
 }
 
 type Postgres struct {
@@ -27,19 +28,27 @@ type Redis struct {
 	RedisDB  int    `yaml:"db"`
 }
 
+// Fabric names the channel and chaincode the services write to directly, and
+// the organizations that endorse the elections they create.
+type Fabric struct {
+	Channel       string   `yaml:"channel"`
+	Chaincode     string   `yaml:"chaincode"`
+	Organizations []string `yaml:"organizations"`
+}
+
 var Cfg Config
 
 func init() {
 	wd, err := os.Getwd()
 	if err != nil {
-		fmt.Errorf("Failed to get working directory: %w", err)
+		log.Printf("Failed to get working directory: %v", err)
 	}
 	configPath := filepath.Join(wd, "config.yml")
 	data, err := os.ReadFile(configPath)
 	if err != nil {
-		fmt.Errorf("Failed to read configuration file: %w", err)
+		log.Printf("Failed to read configuration file: %v", err)
 	}
 	if err := yaml.Unmarshal(data, &Cfg); err != nil {
-		fmt.Errorf("Failed to unmarshal YAML data: %w", err)
+		log.Printf("Failed to unmarshal YAML data: %v", err)
 	}
 }
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"rest-api-go/internal/models"
+	"strings"
 )
 
 type IUserRepository interface {
@@ -21,6 +22,7 @@ type ChainCodeProxy struct {
 type UserBuilder interface {
 	SetIDNP(string) UserBuilder
 	SetName(string) UserBuilder
+	SetPrecinct(string) UserBuilder
 	Validate() error
 	Build() (*models.User, error)
 }
@@ -52,6 +54,11 @@ func (b *VoterUserBuilder) SetName(name string) UserBuilder {
 	return b
 }
 
+func (b *VoterUserBuilder) SetPrecinct(precinct string) UserBuilder {
+	b.user.Precinct = precinct
+	return b
+}
+
 func (b *VoterUserBuilder) Validate() error {
 	if b.user.Idnp == "" {
 		return errors.New("IDNP cannot be empty")
@@ -81,14 +88,26 @@ func NewChaincodeProxy(userRepo IUserRepository) *ChainCodeProxy {
 }
 
 func (proxy *ChainCodeProxy) ValidateAndForward(function string, args []string, forward func() (string, error)) (string, error) {
-	if function == "RegisterVoter" {
+	// Voter registration is also reachable through the registry contract namespace.
+	function = strings.TrimPrefix(function, "registry:")
+	if function == "RegisterVoter" || function == "RegisterVoterInPrecinct" {
 		if len(args) == 0 {
 			return "", errors.New("missing user IDNP")
 		}
+		precinct := ""
+		if function == "RegisterVoterInPrecinct" {
+			if len(args) < 3 {
+				return "", errors.New("expected IDNP, name and precinct")
+			}
+			precinct = args[2]
+		} else if len(args) < 2 {
+			return "", errors.New("expected IDNP and name")
+		}
 		builder := NewVoterUserBuilder(proxy.userRepo)
 		_, err := builder.
 			SetIDNP(args[0]).
 			SetName(args[1]).
+			SetPrecinct(precinct).
 			Build()
 
 		if err != nil {
@@ -1,6 +1,7 @@
 package controller
 
 import (
+	"errors"
 	"github.com/gofiber/fiber/v2"
 	"log"
 	"net/http"
@@ -13,6 +14,30 @@ type RegisterCandidatesRequest struct {
 	Candidates []models.Candidate `json:"candidates"`
 }
 
+type WithdrawCandidateRequest struct {
+	Reason string `json:"reason"`
+}
+
+type RegisterContestRequest struct {
+	Title          string `json:"title"`
+	ConstituencyID *uint  `json:"constituencyId"`
+	MinSelections  int    `json:"minSelections"`
+	MaxSelections  int    `json:"maxSelections"`
+	CandidateIDs   []uint `json:"candidateIds"`
+}
+
+type CastBallotRequest struct {
+	VoterID    string                    `json:"voterId"`
+	Selections []models.ContestSelection `json:"selections"`
+}
+
+// CastSignedBallotRequest carries the canonical ballot text the voter signed
+// and the base64 encoded signature.
+type CastSignedBallotRequest struct {
+	Payload   string `json:"payload"`
+	Signature string `json:"signature"`
+}
+
 type ElectionController struct {
 	electionService service.ElectionService
 }
@@ -89,14 +114,20 @@ func (ctrl *ElectionController) RegisterCandidates(ctx *fiber.Ctx) error {
 		return ctx.Status(http.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
 	}
 
-	if err := ctrl.electionService.RegisterCandidates(req.ElectionID, req.Candidates); err != nil {
+	candidates, err := ctrl.electionService.RegisterCandidates(req.ElectionID, req.Candidates)
+	if err != nil {
 		log.Printf("Failed to register candidates %v", err)
 		return ctx.Status(http.StatusInternalServerError).JSON(fiber.Map{
-			"message": "Failed to register candidates",
-			"error":   err.Error(),
+			"message":    "Failed to register candidates",
+			"error":      err.Error(),
+			"candidates": candidates,
 		})
 	}
-	return nil
+	log.Printf("register candidates request successful for election ID: %d", req.ElectionID)
+	return ctx.Status(http.StatusOK).JSON(fiber.Map{
+		"message":    "Candidates registered successfully",
+		"candidates": candidates,
+	})
 }
 
 func (ctrl *ElectionController) GetCandidatesByElectionId(ctx *fiber.Ctx) error {
@@ -105,7 +136,10 @@ func (ctrl *ElectionController) GetCandidatesByElectionId(ctx *fiber.Ctx) error
 		log.Printf("invalid request body")
 		return ctx.Status(http.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
 	}
-	candidates, err := ctrl.electionService.GetCandidates(uint(id))
+	candidates, err := ctrl.electionService.GetCandidates(uint(id), ctx.Query("voter"))
+	if errors.Is(err, service.ErrNotEligible) {
+		return ctx.Status(http.StatusForbidden).JSON(fiber.Map{"error": err.Error()})
+	}
 	if err != nil {
 		log.Printf("can't get candidates for specific election")
 		return ctx.Status(http.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
@@ -116,3 +150,202 @@ func (ctrl *ElectionController) GetCandidatesByElectionId(ctx *fiber.Ctx) error
 		"candidates": candidates,
 	})
 }
+
+func (ctrl *ElectionController) WithdrawCandidate(ctx *fiber.Ctx) error {
+	id, err := ctx.ParamsInt("id")
+	if err != nil {
+		return ctx.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid candidate ID",
+			"error":   err.Error(),
+		})
+	}
+
+	var req WithdrawCandidateRequest
+	err = ctx.BodyParser(&req)
+	if err != nil {
+		log.Printf("Invalid request body")
+		return ctx.Status(http.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	candidate, err := ctrl.electionService.WithdrawCandidate(uint(id), req.Reason)
+	if err != nil {
+		log.Printf("Failed to withdraw candidate %v", err)
+		return ctx.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"message": "Failed to withdraw candidate",
+			"error":   err.Error(),
+		})
+	}
+	log.Printf("withdraw candidate request successful for ID: %d", id)
+	return ctx.Status(http.StatusOK).JSON(fiber.Map{
+		"message":   "Candidate withdrawn successfully",
+		"candidate": candidate,
+	})
+}
+
+func (ctrl *ElectionController) RegisterContest(ctx *fiber.Ctx) error {
+	id, err := ctx.ParamsInt("id")
+	if err != nil {
+		return ctx.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid election ID",
+			"error":   err.Error(),
+		})
+	}
+
+	var req RegisterContestRequest
+	err = ctx.BodyParser(&req)
+	if err != nil {
+		log.Printf("Invalid request body")
+		return ctx.Status(http.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	contest := models.Contest{
+		Title:          req.Title,
+		ConstituencyID: req.ConstituencyID,
+		MinSelections:  req.MinSelections,
+		MaxSelections:  req.MaxSelections,
+	}
+	registered, err := ctrl.electionService.RegisterContest(uint(id), contest, req.CandidateIDs)
+	if err != nil {
+		log.Printf("Failed to register contest %v", err)
+		return ctx.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"message": "Failed to register contest",
+			"error":   err.Error(),
+		})
+	}
+	log.Printf("register contest request successful for election ID: %d", id)
+	return ctx.Status(http.StatusOK).JSON(fiber.Map{
+		"message": "Contest registered successfully",
+		"contest": registered,
+	})
+}
+
+func (ctrl *ElectionController) GetContests(ctx *fiber.Ctx) error {
+	id, err := ctx.ParamsInt("id")
+	if err != nil {
+		return ctx.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid election ID",
+			"error":   err.Error(),
+		})
+	}
+
+	contests, err := ctrl.electionService.GetContests(uint(id))
+	if err != nil {
+		log.Printf("can't get contests for specific election")
+		return ctx.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	log.Printf("get contests request successful for election ID: %d", id)
+	return ctx.Status(http.StatusOK).JSON(fiber.Map{
+		"message":  "Election contests fetched successfully",
+		"contests": contests,
+	})
+}
+
+func (ctrl *ElectionController) CastBallot(ctx *fiber.Ctx) error {
+	id, err := ctx.ParamsInt("id")
+	if err != nil {
+		return ctx.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid election ID",
+			"error":   err.Error(),
+		})
+	}
+
+	var req CastBallotRequest
+	err = ctx.BodyParser(&req)
+	if err != nil {
+		log.Printf("Invalid request body")
+		return ctx.Status(http.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	err = ctrl.electionService.CastBallot(uint(id), req.VoterID, req.Selections)
+	if errors.Is(err, service.ErrNotEligible) {
+		return ctx.Status(http.StatusForbidden).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err != nil {
+		log.Printf("Failed to cast ballot %v", err)
+		return ctx.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"message": "Failed to cast ballot",
+			"error":   err.Error(),
+		})
+	}
+	log.Printf("cast ballot request successful for election ID: %d", id)
+	return ctx.Status(http.StatusOK).JSON(fiber.Map{"message": "Ballot cast successfully"})
+}
+
+func (ctrl *ElectionController) CastSignedBallot(ctx *fiber.Ctx) error {
+	id, err := ctx.ParamsInt("id")
+	if err != nil {
+		return ctx.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid election ID",
+			"error":   err.Error(),
+		})
+	}
+
+	var req CastSignedBallotRequest
+	err = ctx.BodyParser(&req)
+	if err != nil {
+		log.Printf("Invalid request body")
+		return ctx.Status(http.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	err = ctrl.electionService.CastSignedBallot(uint(id), req.Payload, req.Signature)
+	if errors.Is(err, service.ErrNotEligible) {
+		return ctx.Status(http.StatusForbidden).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err != nil {
+		log.Printf("Failed to cast signed ballot %v", err)
+		return ctx.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"message": "Failed to cast ballot",
+			"error":   err.Error(),
+		})
+	}
+	log.Printf("cast signed ballot request successful for election ID: %d", id)
+	return ctx.Status(http.StatusOK).JSON(fiber.Map{"message": "Ballot cast successfully"})
+}
+
+func (ctrl *ElectionController) GetContestResults(ctx *fiber.Ctx) error {
+	id, err := ctx.ParamsInt("id")
+	if err != nil {
+		return ctx.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid election ID",
+			"error":   err.Error(),
+		})
+	}
+
+	results, err := ctrl.electionService.GetContestResults(uint(id))
+	if err != nil {
+		log.Printf("Failed to fetch contest results %v", err)
+		return ctx.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to fetch results",
+			"error":   err.Error(),
+		})
+	}
+	log.Printf("get contest results request successful for election ID: %d", id)
+	return ctx.Status(http.StatusOK).JSON(fiber.Map{
+		"message": "Election results fetched successfully",
+		"results": results,
+	})
+}
+
+func (ctrl *ElectionController) GetDistrictResults(ctx *fiber.Ctx) error {
+	id, err := ctx.ParamsInt("id")
+	if err != nil {
+		return ctx.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid election ID",
+			"error":   err.Error(),
+		})
+	}
+
+	results, err := ctrl.electionService.GetDistrictResults(uint(id))
+	if err != nil {
+		log.Printf("Failed to fetch district results %v", err)
+		return ctx.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to fetch results",
+			"error":   err.Error(),
+		})
+	}
+	log.Printf("get district results request successful for election ID: %d", id)
+	return ctx.Status(http.StatusOK).JSON(fiber.Map{
+		"message": "Election results fetched successfully",
+		"results": results,
+	})
+}
@@ -0,0 +1,70 @@
+package controller
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"log"
+	"net/http"
+	"rest-api-go/internal/models"
+	"rest-api-go/internal/service"
+)
+
+type GeographyController struct {
+	geographyService service.GeographyService
+}
+
+func NewGeographyController(service service.GeographyService) *GeographyController {
+	return &GeographyController{geographyService: service}
+}
+
+func (ctrl *GeographyController) RegisterConstituency(ctx *fiber.Ctx) error {
+	var constituency models.Constituency
+
+	err := ctx.BodyParser(&constituency)
+	if err != nil {
+		log.Printf("Invalid request body")
+		return ctx.Status(http.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	err = ctrl.geographyService.RegisterConstituency(&constituency)
+	if err != nil {
+		log.Printf("Failed to register constituency %v", err)
+		return ctx.Status(http.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	log.Printf("register constituency request successful for code: %s", constituency.Code)
+	return ctx.Status(http.StatusOK).JSON(fiber.Map{
+		"message":      "Constituency successfully created",
+		"constituency": constituency,
+	})
+}
+
+func (ctrl *GeographyController) RegisterPrecinct(ctx *fiber.Ctx) error {
+	var precinct models.Precinct
+
+	err := ctx.BodyParser(&precinct)
+	if err != nil {
+		log.Printf("Invalid request body")
+		return ctx.Status(http.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	err = ctrl.geographyService.RegisterPrecinct(&precinct)
+	if err != nil {
+		log.Printf("Failed to register precinct %v", err)
+		return ctx.Status(http.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	log.Printf("register precinct request successful for code: %s", precinct.Code)
+	return ctx.Status(http.StatusOK).JSON(fiber.Map{
+		"message":  "Precinct successfully created",
+		"precinct": precinct,
+	})
+}
+
+func (ctrl *GeographyController) GetConstituencies(ctx *fiber.Ctx) error {
+	constituencies, err := ctrl.geographyService.GetConstituencies()
+	if err != nil {
+		log.Printf("Failed to fetch constituencies: %v", err)
+		return ctx.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	log.Printf("get all constituencies request successful")
+	return ctx.Status(http.StatusOK).JSON(fiber.Map{
+		"message":        "Constituencies retrieved successfully",
+		"constituencies": constituencies,
+	})
+}
@@ -0,0 +1,99 @@
+package controller
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"log"
+	"net/http"
+	"rest-api-go/internal/service"
+)
+
+type VoterAttributesRequest struct {
+	DateOfBirth string `json:"dateOfBirth"`
+	Citizenship string `json:"citizenship"`
+	Region      string `json:"region"`
+}
+
+type VoterKeyRequest struct {
+	SigningKey string `json:"signingKey"`
+	// Signature is the base64 encoded signature of the key challenge.
+	Signature string `json:"signature"`
+}
+
+type VoterController struct {
+	voterService service.VoterService
+}
+
+func NewVoterController(service service.VoterService) *VoterController {
+	return &VoterController{voterService: service}
+}
+
+func (ctrl *VoterController) SetVoterAttributes(ctx *fiber.Ctx) error {
+	idnp := ctx.Params("idnp")
+
+	var req VoterAttributesRequest
+	err := ctx.BodyParser(&req)
+	if err != nil {
+		log.Printf("Invalid request body")
+		return ctx.Status(http.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	user, err := ctrl.voterService.SetVoterAttributes(idnp, req.DateOfBirth, req.Citizenship, req.Region)
+	if err != nil {
+		log.Printf("Failed to set voter attributes %v", err)
+		return ctx.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"message": "Failed to set voter attributes",
+			"error":   err.Error(),
+		})
+	}
+	log.Printf("set voter attributes request successful for voter: %s", idnp)
+	return ctx.Status(http.StatusOK).JSON(fiber.Map{
+		"message": "Voter attributes updated successfully",
+		"voter":   user,
+	})
+}
+
+func (ctrl *VoterController) GetKeyChallenge(ctx *fiber.Ctx) error {
+	idnp := ctx.Params("idnp")
+
+	var req VoterKeyRequest
+	err := ctx.BodyParser(&req)
+	if err != nil {
+		log.Printf("Invalid request body")
+		return ctx.Status(http.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	challenge, err := ctrl.voterService.KeyChallenge(idnp, req.SigningKey)
+	if err != nil {
+		log.Printf("Failed to compute key challenge %v", err)
+		return ctx.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"message": "Failed to compute key challenge",
+			"error":   err.Error(),
+		})
+	}
+	return ctx.Status(http.StatusOK).JSON(fiber.Map{"challenge": challenge})
+}
+
+func (ctrl *VoterController) RegisterVoterKey(ctx *fiber.Ctx) error {
+	idnp := ctx.Params("idnp")
+
+	var req VoterKeyRequest
+	err := ctx.BodyParser(&req)
+	if err != nil {
+		log.Printf("Invalid request body")
+		return ctx.Status(http.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	user, err := ctrl.voterService.RegisterVoterKey(idnp, req.SigningKey, req.Signature)
+	if err != nil {
+		log.Printf("Failed to register voter key %v", err)
+		return ctx.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"message": "Failed to register voter key",
+			"error":   err.Error(),
+		})
+	}
+	log.Printf("register voter key request successful for voter: %s", idnp)
+	return ctx.Status(http.StatusOK).JSON(fiber.Map{
+		"message": "Voter signing key registered successfully",
+		"voter":   user,
+	})
+}
@@ -1,6 +1,10 @@
 package models
 
-import "gorm.io/gorm"
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
 
 type Election struct {
 	ID                   uint   `gorm:"primaryKey; autoIncrement:true;unique"`
@@ -13,6 +17,59 @@ type Election struct {
 	NumberOfCandidates   string `gorm:"not null" json:"numberOfCandidates"`
 	NumberOfSelection    string `gorm:"not null" json:"numberOfSelection"`
 	AuthMethod           string `gorm:"not null" json:"authMethod"`
+
+	Eligibility EligibilityRules `json:"eligibility" gorm:"embedded;embeddedPrefix:eligibility_"`
+
+	Contests []Contest `json:"contests"`
+}
+
+// EligibilityRules restrict who can be issued a ballot in an election. The
+// same rules are enforced by the ledger when the ballot is cast.
+type EligibilityRules struct {
+	// MinimumAge is the age a voter must have reached on ElectionDay,
+	// formatted as YYYY-MM-DD.
+	MinimumAge   int      `json:"minimumAge"`
+	ElectionDay  string   `json:"electionDay"`
+	Citizenships []string `json:"citizenships" gorm:"serializer:json"`
+	Regions      []string `json:"regions" gorm:"serializer:json"`
+}
+
+// Contest is one race of an election, such as mayor or a referendum question.
+// Voters cast a single ballot covering every contest of the election.
+type Contest struct {
+	gorm.Model
+	ElectionID    uint   `json:"electionID"`
+	Title         string `json:"title" gorm:"not null"`
+	MinSelections int    `json:"minSelections" gorm:"not null"`
+	MaxSelections int    `json:"maxSelections" gorm:"not null"`
+
+	// ContestID is the UUID the contest is created under on the ledger.
+	ContestID string `json:"contestId" gorm:"type:uuid;uniqueIndex"`
+
+	// ConstituencyID scopes the contest to the voters of one constituency.
+	ConstituencyID *uint `json:"constituencyId"`
+
+	Candidates []Candidate `json:"candidates" gorm:"many2many:contest_candidates"`
+}
+
+// ContestSelection is a voter's choice in one contest, by ledger IDs.
+type ContestSelection struct {
+	ContestID    string   `json:"contestID"`
+	CandidateIDs []string `json:"candidateIDs"`
+}
+
+// ContestResult is the tally of one contest as reported by the ledger.
+type ContestResult struct {
+	ContestID string           `json:"contestID"`
+	Title     string           `json:"title"`
+	Tally     []CandidateTally `json:"tally"`
+}
+
+type CandidateTally struct {
+	CandidateID string `json:"candidateID"`
+	Votes       int    `json:"votes"`
+	Withdrawn   bool   `json:"withdrawn,omitempty"`
+	VoidedVotes int    `json:"voidedVotes,omitempty"`
 }
 
 type Candidate struct {
@@ -23,4 +80,16 @@ type Candidate struct {
 	Party      string `json:"party" gorm:"not null"`
 	Photo      string `json:"photo" gorm:"type:text"`
 	ElectionID uint   `json:"electionID"`
+
+	// CandidateID is the UUID the candidate is registered under on the ledger.
+	CandidateID string `json:"candidateId" gorm:"type:uuid;uniqueIndex"`
+
+	Status           string     `json:"status" gorm:"not null;default:Active"`
+	WithdrawalReason string     `json:"withdrawalReason"`
+	WithdrawnAt      *time.Time `json:"withdrawnAt"`
 }
+
+const (
+	CandidateStatusActive    = "Active"
+	CandidateStatusWithdrawn = "Withdrawn"
+)
@@ -0,0 +1,32 @@
+package models
+
+// Constituency is an electoral district. Its code is the ID it is created
+// under on the ledger.
+type Constituency struct {
+	ID        uint       `gorm:"primaryKey; autoIncrement:true;unique"`
+	Code      string     `gorm:"not null;unique" json:"code"`
+	Name      string     `gorm:"not null" json:"name"`
+	Precincts []Precinct `json:"precincts"`
+}
+
+// Precinct is the polling district a voter is registered in.
+type Precinct struct {
+	ID             uint   `gorm:"primaryKey; autoIncrement:true;unique"`
+	Code           string `gorm:"not null;unique" json:"code"`
+	Name           string `gorm:"not null" json:"name"`
+	ConstituencyID uint   `gorm:"not null" json:"constituencyId"`
+}
+
+// DistrictTally is the tally of one constituency, or of one of its precincts
+// when PrecinctID is set, as reported by the ledger.
+type DistrictTally struct {
+	ConstituencyID string           `json:"constituencyID"`
+	PrecinctID     string           `json:"precinctID,omitempty"`
+	Tally          []CandidateTally `json:"tally"`
+}
+
+type DistrictResults struct {
+	ElectionID     string          `json:"electionID"`
+	Constituencies []DistrictTally `json:"constituencies"`
+	Precincts      []DistrictTally `json:"precincts"`
+}
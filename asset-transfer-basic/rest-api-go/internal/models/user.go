@@ -6,4 +6,17 @@ type User struct {
 	Name       string `gorm:"not null" json:"name"`
 	Role       string `json:"role"`
 	Registered bool   `json:"registered"`
+
+	// Precinct is the code of the precinct the voter is registered in.
+	Precinct string `json:"precinct"`
+
+	// Eligibility attributes, recorded by the registrar. DateOfBirth is
+	// formatted as YYYY-MM-DD.
+	DateOfBirth string `json:"dateOfBirth"`
+	Citizenship string `json:"citizenship"`
+	Region      string `json:"region"`
+
+	// SigningKey is the PEM encoded certificate or public key the voter signs
+	// ballots with.
+	SigningKey string `json:"signingKey" gorm:"type:text"`
 }
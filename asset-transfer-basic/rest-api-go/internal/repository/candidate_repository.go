@@ -7,8 +7,11 @@ import (
 )
 
 type ICandidateRepository interface {
-	RegisterCandidates(candidates []models.Candidate) error
+	RegisterCandidate(candidate *models.Candidate, commit func() error) error
 	GetCandidatesByElectionId(electionId uint) ([]models.Candidate, error)
+	GetCandidateById(id uint) (*models.Candidate, error)
+	GetCandidatesWithoutCandidateID() ([]models.Candidate, error)
+	UpdateCandidate(candidate *models.Candidate, commit func() error) error
 }
 
 type CandidateRepository struct {
@@ -19,15 +22,19 @@ func NewCandidateRepository(dbClient any) *CandidateRepository {
 	return &CandidateRepository{dbClient: dbClient}
 }
 
-func (repo *CandidateRepository) RegisterCandidates(candidates []models.Candidate) error {
+// RegisterCandidate inserts the candidate and calls commit before the insert
+// is committed, so the row is rolled back if commit fails.
+func (repo *CandidateRepository) RegisterCandidate(candidate *models.Candidate, commit func() error) error {
 	db, ok := repo.dbClient.(*gorm.DB)
 	if !ok {
 		return fmt.Errorf("invalid dbClient type: expected *gorm.DB")
 	}
-	if err := db.Create(&candidates).Error; err != nil {
-		return fmt.Errorf("failed to register candidates: %w", err)
-	}
-	return nil
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(candidate).Error; err != nil {
+			return fmt.Errorf("failed to register candidate: %w", err)
+		}
+		return commit()
+	})
 }
 
 func (repo *CandidateRepository) GetCandidatesByElectionId(electionId uint) ([]models.Candidate, error) {
@@ -44,3 +51,50 @@ func (repo *CandidateRepository) GetCandidatesByElectionId(electionId uint) ([]m
 
 	return candidates, nil
 }
+
+func (repo *CandidateRepository) GetCandidateById(id uint) (*models.Candidate, error) {
+	db, ok := repo.dbClient.(*gorm.DB)
+	if !ok {
+		return nil, fmt.Errorf("invalid dbClient type: expected *gorm.DB")
+	}
+
+	var candidate models.Candidate
+	res := db.First(&candidate, id)
+	if res.Error != nil {
+		return nil, res.Error
+	}
+
+	return &candidate, nil
+}
+
+// GetCandidatesWithoutCandidateID returns the candidates stored before
+// candidates were given a ledger UUID.
+func (repo *CandidateRepository) GetCandidatesWithoutCandidateID() ([]models.Candidate, error) {
+	db, ok := repo.dbClient.(*gorm.DB)
+	if !ok {
+		return nil, fmt.Errorf("invalid dbClient type: expected *gorm.DB")
+	}
+
+	var candidates []models.Candidate
+	res := db.Where("candidate_id IS NULL").Order("id").Find(&candidates)
+	if res.Error != nil {
+		return nil, res.Error
+	}
+
+	return candidates, nil
+}
+
+// UpdateCandidate saves the candidate and calls commit before the update is
+// committed, so the change is rolled back if commit fails.
+func (repo *CandidateRepository) UpdateCandidate(candidate *models.Candidate, commit func() error) error {
+	db, ok := repo.dbClient.(*gorm.DB)
+	if !ok {
+		return fmt.Errorf("invalid dbClient type: expected *gorm.DB")
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(candidate).Error; err != nil {
+			return fmt.Errorf("failed to update candidate: %w", err)
+		}
+		return commit()
+	})
+}
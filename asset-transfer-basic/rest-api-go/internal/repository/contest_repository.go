@@ -0,0 +1,50 @@
+package repository
+
+import (
+	"fmt"
+	"gorm.io/gorm"
+	"rest-api-go/internal/models"
+)
+
+type IContestRepository interface {
+	RegisterContest(contest *models.Contest, commit func() error) error
+	GetContestsByElectionId(electionId uint) ([]models.Contest, error)
+}
+
+type ContestRepository struct {
+	dbClient any
+}
+
+func NewContestRepository(dbClient any) *ContestRepository {
+	return &ContestRepository{dbClient: dbClient}
+}
+
+// RegisterContest inserts the contest with its candidates and calls commit
+// before the insert is committed, so the rows are rolled back if commit fails.
+func (repo *ContestRepository) RegisterContest(contest *models.Contest, commit func() error) error {
+	db, ok := repo.dbClient.(*gorm.DB)
+	if !ok {
+		return fmt.Errorf("invalid dbClient type: expected *gorm.DB")
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("Candidates.*").Create(contest).Error; err != nil {
+			return fmt.Errorf("failed to register contest: %w", err)
+		}
+		return commit()
+	})
+}
+
+func (repo *ContestRepository) GetContestsByElectionId(electionId uint) ([]models.Contest, error) {
+	db, ok := repo.dbClient.(*gorm.DB)
+	if !ok {
+		return nil, fmt.Errorf("invalid dbClient type: expected *gorm.DB")
+	}
+
+	var contests []models.Contest
+	res := db.Preload("Candidates").Where("election_id = ?", electionId).Find(&contests)
+	if res.Error != nil {
+		return nil, res.Error
+	}
+
+	return contests, nil
+}
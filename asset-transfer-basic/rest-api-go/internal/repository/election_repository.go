@@ -8,7 +8,7 @@ import (
 )
 
 type IElectionRepository interface {
-	RegisterElection(election *models.Election) error
+	RegisterElection(election *models.Election, commit func() error) error
 	GetAllElection() ([]models.Election, error)
 	GetElectionById(id uint) (*models.Election, error)
 }
@@ -21,16 +21,19 @@ func NewElectionRepository(dbClient any) *ElectionRepository {
 	return &ElectionRepository{dbClient: dbClient}
 }
 
-func (repo *ElectionRepository) RegisterElection(election *models.Election) error {
+// RegisterElection inserts the election and calls commit before the insert
+// is committed, so the row is rolled back if commit fails.
+func (repo *ElectionRepository) RegisterElection(election *models.Election, commit func() error) error {
 	db, ok := repo.dbClient.(*gorm.DB)
 	if !ok {
 		return fmt.Errorf("invalid dbClient type: expected *gorm.DB")
 	}
-	res := db.Create(&election)
-	if res.Error != nil {
-		return nil
-	}
-	return nil
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(election).Error; err != nil {
+			return fmt.Errorf("failed to register election: %w", err)
+		}
+		return commit()
+	})
 }
 
 func (repo *ElectionRepository) GetAllElection() ([]models.Election, error) {
@@ -54,7 +57,7 @@ func (repo *ElectionRepository) GetElectionById(id uint) (*models.Election, erro
 	}
 
 	var election models.Election
-	result := db.First(&election, id)
+	result := db.Preload("Contests.Candidates").First(&election, id)
 	if result.Error != nil {
 		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
 			return nil, fmt.Errorf("election with id %d not found", id)
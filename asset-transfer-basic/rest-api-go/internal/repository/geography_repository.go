@@ -0,0 +1,84 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"gorm.io/gorm"
+	"rest-api-go/internal/models"
+)
+
+type IGeographyRepository interface {
+	RegisterConstituency(constituency *models.Constituency, commit func() error) error
+	RegisterPrecinct(precinct *models.Precinct, commit func() error) error
+	GetConstituencies() ([]models.Constituency, error)
+	GetConstituencyById(id uint) (*models.Constituency, error)
+}
+
+type GeographyRepository struct {
+	dbClient any
+}
+
+func NewGeographyRepository(dbClient any) *GeographyRepository {
+	return &GeographyRepository{dbClient: dbClient}
+}
+
+// RegisterConstituency inserts the constituency and calls commit before the
+// insert is committed, so the row is rolled back if commit fails.
+func (repo *GeographyRepository) RegisterConstituency(constituency *models.Constituency, commit func() error) error {
+	db, ok := repo.dbClient.(*gorm.DB)
+	if !ok {
+		return fmt.Errorf("invalid dbClient type: expected *gorm.DB")
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(constituency).Error; err != nil {
+			return fmt.Errorf("failed to register constituency: %w", err)
+		}
+		return commit()
+	})
+}
+
+// RegisterPrecinct inserts the precinct and calls commit before the insert is
+// committed, so the row is rolled back if commit fails.
+func (repo *GeographyRepository) RegisterPrecinct(precinct *models.Precinct, commit func() error) error {
+	db, ok := repo.dbClient.(*gorm.DB)
+	if !ok {
+		return fmt.Errorf("invalid dbClient type: expected *gorm.DB")
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(precinct).Error; err != nil {
+			return fmt.Errorf("failed to register precinct: %w", err)
+		}
+		return commit()
+	})
+}
+
+func (repo *GeographyRepository) GetConstituencies() ([]models.Constituency, error) {
+	db, ok := repo.dbClient.(*gorm.DB)
+	if !ok {
+		return nil, fmt.Errorf("invalid dbClient type: expected *gorm.DB")
+	}
+
+	var constituencies []models.Constituency
+	res := db.Preload("Precincts").Find(&constituencies)
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	return constituencies, nil
+}
+
+func (repo *GeographyRepository) GetConstituencyById(id uint) (*models.Constituency, error) {
+	db, ok := repo.dbClient.(*gorm.DB)
+	if !ok {
+		return nil, fmt.Errorf("invalid dbClient type: expected *gorm.DB")
+	}
+
+	var constituency models.Constituency
+	result := db.First(&constituency, id)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("constituency with id %d not found", id)
+		}
+		return nil, result.Error
+	}
+	return &constituency, nil
+}
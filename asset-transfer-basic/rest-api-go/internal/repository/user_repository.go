@@ -7,6 +7,11 @@ import (
 	"rest-api-go/internal/models"
 )
 
+type IUserRepository interface {
+	GetUser(idnp string) (*models.User, error)
+	UpdateUser(user *models.User) error
+}
+
 type UserRepository struct {
 	dbClient any
 }
@@ -64,3 +69,14 @@ func (repo *UserRepository) AddUser(user *models.User) error {
 	}
 	return db.Create(user).Error
 }
+
+func (repo *UserRepository) UpdateUser(user *models.User) error {
+	db, ok := repo.dbClient.(*gorm.DB)
+	if !ok {
+		return fmt.Errorf("invalid dbClient type: expected *gorm.DB")
+	}
+	if err := db.Save(user).Error; err != nil {
+		return fmt.Errorf("failed to update user: %w", err)
+	}
+	return nil
+}
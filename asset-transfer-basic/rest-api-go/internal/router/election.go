@@ -12,4 +12,11 @@ func RegisterElectionRoutes(r *fiber.App, electionCtrl *controller.ElectionContr
 	route.Get("/:id", electionCtrl.GetElectionById)
 	route.Post("/candidates", electionCtrl.RegisterCandidates)
 	route.Get("/candidates/:electionId", electionCtrl.GetCandidatesByElectionId)
+	route.Post("/candidates/:id/withdraw", electionCtrl.WithdrawCandidate)
+	route.Post("/:id/contests", electionCtrl.RegisterContest)
+	route.Get("/:id/contests", electionCtrl.GetContests)
+	route.Post("/:id/ballot", electionCtrl.CastBallot)
+	route.Post("/:id/ballot/signed", electionCtrl.CastSignedBallot)
+	route.Get("/:id/results", electionCtrl.GetContestResults)
+	route.Get("/:id/results/districts", electionCtrl.GetDistrictResults)
 }
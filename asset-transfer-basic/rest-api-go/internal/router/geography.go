@@ -0,0 +1,13 @@
+package router
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"rest-api-go/internal/controller"
+)
+
+func RegisterGeographyRoutes(r *fiber.App, geographyCtrl *controller.GeographyController) {
+	route := r.Group("/geography")
+	route.Post("/constituencies", geographyCtrl.RegisterConstituency)
+	route.Get("/constituencies", geographyCtrl.GetConstituencies)
+	route.Post("/precincts", geographyCtrl.RegisterPrecinct)
+}
@@ -0,0 +1,13 @@
+package router
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"rest-api-go/internal/controller"
+)
+
+func RegisterVoterRoutes(r *fiber.App, voterCtrl *controller.VoterController) {
+	route := r.Group("/voters")
+	route.Put("/:idnp/attributes", voterCtrl.SetVoterAttributes)
+	route.Post("/:idnp/key/challenge", voterCtrl.GetKeyChallenge)
+	route.Put("/:idnp/key", voterCtrl.RegisterVoterKey)
+}
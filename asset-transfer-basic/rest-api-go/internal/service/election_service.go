@@ -1,33 +1,97 @@
 package service
 
 import (
+	"encoding/json"
 	"fmt"
+	"github.com/google/uuid"
+	"log"
 	"rest-api-go/internal/models"
 	"rest-api-go/internal/repository"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 )
 
 type ElectionService interface {
 	RegisterElection(election *models.Election) error
 	GetAllElections() ([]models.Election, error)
 	GetElectionById(id uint) (*models.Election, error)
-	RegisterCandidates(electionID uint, candidates []models.Candidate) error
-	GetCandidates(electionId uint) ([]models.Candidate, error)
+	RegisterCandidates(electionID uint, candidates []models.Candidate) ([]models.Candidate, error)
+	BackfillCandidateIDs() error
+	GetCandidates(electionId uint, voterID string) ([]models.Candidate, error)
+	WithdrawCandidate(candidateId uint, reason string) (*models.Candidate, error)
+	RegisterContest(electionId uint, contest models.Contest, candidateIds []uint) (*models.Contest, error)
+	GetContests(electionId uint) ([]models.Contest, error)
+	CastBallot(electionId uint, voterID string, selections []models.ContestSelection) error
+	CastSignedBallot(electionId uint, payload, signature string) error
+	GetContestResults(electionId uint) ([]models.ContestResult, error)
+	GetDistrictResults(electionId uint) (*models.DistrictResults, error)
+}
+
+// Ledger submits the transactions that keep the ledger in step with the
+// registration database.
+type Ledger interface {
+	CreateElection(electionID, title string) error
+	SetEligibilityRules(electionID string, eligibility models.EligibilityRules) error
+	EligibilityRules(electionID string) (*models.EligibilityRules, error)
+	RegisterCandidate(candidateID, name, electionID, party string) error
+	WithdrawCandidate(candidateID, reason string) error
+	// CandidateOrder returns the IDs of the election's candidates in the
+	// order they are presented to voterID, or to any voter when it is empty.
+	CandidateOrder(electionID, voterID string) ([]string, error)
+	CreateContest(contestID, electionID, title, constituencyID string, candidateIDs []string, minSelections, maxSelections int) error
+	CastContestBallot(voterID, electionID string, selections []models.ContestSelection) error
+	ContestResults(electionID string) ([]models.ContestResult, error)
+	DistrictResults(electionID string) (*models.DistrictResults, error)
+	CreateConstituency(constituencyID, name string) error
+	CreatePrecinct(precinctID, name, constituencyID string) error
+	SetVoterAttributes(voterID, dateOfBirth, citizenship, region string) error
+	// KeyChallenge returns the text the voter signs with keyPEM to prove
+	// they hold it.
+	KeyChallenge(voterID, keyPEM string) (string, error)
+	RegisterVoterKey(voterID, keyPEM, signature string) error
+	CastSignedBallot(payload, signature string) error
 }
 
 type ElectionServiceImpl struct {
 	ElectionRepository   repository.IElectionRepository
 	CandidatesRepository repository.ICandidateRepository
+	ContestRepository    repository.IContestRepository
+	GeographyRepository  repository.IGeographyRepository
+	UserRepository       repository.IUserRepository
+	Ledger               Ledger
 }
 
-func NewElectionServiceImpl(electionRepository repository.IElectionRepository, candidateRepository repository.ICandidateRepository) (service ElectionService) {
-	return &ElectionServiceImpl{ElectionRepository: electionRepository, CandidatesRepository: candidateRepository}
+func NewElectionServiceImpl(electionRepository repository.IElectionRepository, candidateRepository repository.ICandidateRepository, contestRepository repository.IContestRepository, geographyRepository repository.IGeographyRepository, userRepository repository.IUserRepository, ledger Ledger) (service ElectionService) {
+	return &ElectionServiceImpl{
+		ElectionRepository:   electionRepository,
+		CandidatesRepository: candidateRepository,
+		ContestRepository:    contestRepository,
+		GeographyRepository:  geographyRepository,
+		UserRepository:       userRepository,
+		Ledger:               ledger,
+	}
 }
 
+// RegisterElection records the election in the database and creates it on
+// the ledger under its database ID, together with its eligibility rules. The
+// election is only kept in the database once the ledger has accepted both.
 func (electionRepo *ElectionServiceImpl) RegisterElection(election *models.Election) (err error) {
-	err = electionRepo.ElectionRepository.RegisterElection(election)
+	err = validateEligibilityRules(election.Eligibility)
 	if err != nil {
 		return err
 	}
+	err = electionRepo.ElectionRepository.RegisterElection(election, func() error {
+		electionID := strconv.FormatUint(uint64(election.ID), 10)
+		if err := electionRepo.Ledger.CreateElection(electionID, election.Title); err != nil {
+			return err
+		}
+		return electionRepo.Ledger.SetEligibilityRules(electionID, election.Eligibility)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to register election %s: %w", election.Title, err)
+	}
 	return nil
 }
 
@@ -47,22 +111,252 @@ func (electionRepo *ElectionServiceImpl) GetElectionById(id uint) (*models.Elect
 	return election, nil
 }
 
-func (electionRepo *ElectionServiceImpl) RegisterCandidates(electionID uint, candidates []models.Candidate) error {
+// RegisterCandidates assigns each candidate a UUID and records it both in the
+// database and on the ledger under that ID. A candidate is only kept in the
+// database once the ledger has accepted it; the candidates registered before
+// a failure are returned together with the error.
+func (electionRepo *ElectionServiceImpl) RegisterCandidates(electionID uint, candidates []models.Candidate) ([]models.Candidate, error) {
 	election, err := electionRepo.ElectionRepository.GetElectionById(electionID)
 	if err != nil {
-		return fmt.Errorf("could not find election with id %d: %w", electionID, err)
+		return nil, fmt.Errorf("could not find election with id %d: %w", electionID, err)
 	}
 
-	for i := range candidates {
-		candidates[i].ElectionID = election.ID
+	registered := make([]models.Candidate, 0, len(candidates))
+	for _, candidate := range candidates {
+		candidate.ElectionID = election.ID
+		candidate.CandidateID = uuid.NewString()
+
+		err := electionRepo.CandidatesRepository.RegisterCandidate(&candidate, func() error {
+			return electionRepo.Ledger.RegisterCandidate(
+				candidate.CandidateID,
+				strings.TrimSpace(candidate.FirstName+" "+candidate.LastName),
+				strconv.FormatUint(uint64(election.ID), 10),
+				candidate.Party,
+			)
+		})
+		if err != nil {
+			return registered, fmt.Errorf("failed to register candidate %s %s: %w", candidate.FirstName, candidate.LastName, err)
+		}
+		registered = append(registered, candidate)
+	}
+	return registered, nil
+}
+
+// BackfillCandidateIDs assigns a UUID to every candidate stored before
+// candidates had one and registers the candidate on the ledger under it. A
+// candidate keeps no UUID if the ledger rejects it, so it is retried on the
+// next run.
+func (electionRepo *ElectionServiceImpl) BackfillCandidateIDs() error {
+	candidates, err := electionRepo.CandidatesRepository.GetCandidatesWithoutCandidateID()
+	if err != nil {
+		return fmt.Errorf("failed to fetch candidates without a UUID: %w", err)
 	}
 
-	if err := electionRepo.CandidatesRepository.RegisterCandidates(candidates); err != nil {
-		return fmt.Errorf("failed to register candidates: %w", err)
+	for i := range candidates {
+		candidate := &candidates[i]
+		candidate.CandidateID = uuid.NewString()
+
+		err := electionRepo.CandidatesRepository.UpdateCandidate(candidate, func() error {
+			return electionRepo.Ledger.RegisterCandidate(
+				candidate.CandidateID,
+				strings.TrimSpace(candidate.FirstName+" "+candidate.LastName),
+				strconv.FormatUint(uint64(candidate.ElectionID), 10),
+				candidate.Party,
+			)
+		})
+		if err != nil {
+			return fmt.Errorf("failed to register candidate %d: %w", candidate.ID, err)
+		}
 	}
 	return nil
 }
 
-func (electionRepo *ElectionServiceImpl) GetCandidates(electionId uint) ([]models.Candidate, error) {
-	return electionRepo.CandidatesRepository.GetCandidatesByElectionId(electionId)
+// GetCandidates returns the candidates of the election in the order the
+// election's ordering policy on the ledger gives for voterID. Candidates the
+// ledger does not know are listed last. When voterID is set, the ballot is
+// only issued if the voter meets the election's eligibility rules.
+func (electionRepo *ElectionServiceImpl) GetCandidates(electionId uint, voterID string) ([]models.Candidate, error) {
+	if voterID != "" {
+		if err := electionRepo.assertEligible(electionId, voterID); err != nil {
+			return nil, err
+		}
+	}
+
+	candidates, err := electionRepo.CandidatesRepository.GetCandidatesByElectionId(electionId)
+	if err != nil {
+		return nil, err
+	}
+
+	order, err := electionRepo.Ledger.CandidateOrder(strconv.FormatUint(uint64(electionId), 10), voterID)
+	if err != nil {
+		log.Printf("can't read candidate order of election %d from the ledger: %v", electionId, err)
+		return candidates, nil
+	}
+
+	position := make(map[string]int, len(order))
+	for i, candidateID := range order {
+		position[candidateID] = i
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		pi, oki := position[candidates[i].CandidateID]
+		pj, okj := position[candidates[j].CandidateID]
+		if oki != okj {
+			return oki
+		}
+		return oki && pi < pj
+	})
+	return candidates, nil
+}
+
+// WithdrawCandidate marks the candidate as withdrawn in the database and on
+// the ledger. The database is left unchanged if the ledger rejects the
+// withdrawal, for instance because the election is already certified.
+func (electionRepo *ElectionServiceImpl) WithdrawCandidate(candidateId uint, reason string) (*models.Candidate, error) {
+	if reason == "" {
+		return nil, fmt.Errorf("withdrawal reason cannot be empty")
+	}
+
+	candidate, err := electionRepo.CandidatesRepository.GetCandidateById(candidateId)
+	if err != nil {
+		return nil, fmt.Errorf("could not find candidate with id %d: %w", candidateId, err)
+	}
+	if candidate.Status == models.CandidateStatusWithdrawn {
+		return nil, fmt.Errorf("candidate %d has already withdrawn", candidateId)
+	}
+	if candidate.CandidateID == "" {
+		return nil, fmt.Errorf("candidate %d is not registered on the ledger", candidateId)
+	}
+
+	withdrawnAt := time.Now().UTC()
+	candidate.Status = models.CandidateStatusWithdrawn
+	candidate.WithdrawalReason = reason
+	candidate.WithdrawnAt = &withdrawnAt
+
+	err = electionRepo.CandidatesRepository.UpdateCandidate(candidate, func() error {
+		return electionRepo.Ledger.WithdrawCandidate(candidate.CandidateID, reason)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to withdraw candidate %d: %w", candidateId, err)
+	}
+	return candidate, nil
+}
+
+// RegisterContest assigns the contest a UUID and records it with its
+// candidates both in the database and on the ledger. The candidates must
+// already be registered in the same election. A contest with a constituency
+// is only on the ballot of the voters registered there.
+func (electionRepo *ElectionServiceImpl) RegisterContest(electionId uint, contest models.Contest, candidateIds []uint) (*models.Contest, error) {
+	election, err := electionRepo.ElectionRepository.GetElectionById(electionId)
+	if err != nil {
+		return nil, fmt.Errorf("could not find election with id %d: %w", electionId, err)
+	}
+	if contest.Title == "" {
+		return nil, fmt.Errorf("contest title cannot be empty")
+	}
+
+	constituencyCode := ""
+	if contest.ConstituencyID != nil {
+		constituency, err := electionRepo.GeographyRepository.GetConstituencyById(*contest.ConstituencyID)
+		if err != nil {
+			return nil, err
+		}
+		constituencyCode = constituency.Code
+	}
+
+	contest.ElectionID = election.ID
+	contest.ContestID = uuid.NewString()
+	contest.Candidates = make([]models.Candidate, 0, len(candidateIds))
+	ledgerIDs := make([]string, 0, len(candidateIds))
+	for _, candidateId := range candidateIds {
+		candidate, err := electionRepo.CandidatesRepository.GetCandidateById(candidateId)
+		if err != nil {
+			return nil, fmt.Errorf("could not find candidate with id %d: %w", candidateId, err)
+		}
+		if candidate.ElectionID != election.ID {
+			return nil, fmt.Errorf("candidate %d does not run in election %d", candidateId, election.ID)
+		}
+		contest.Candidates = append(contest.Candidates, *candidate)
+		ledgerIDs = append(ledgerIDs, candidate.CandidateID)
+	}
+
+	err = electionRepo.ContestRepository.RegisterContest(&contest, func() error {
+		return electionRepo.Ledger.CreateContest(
+			contest.ContestID,
+			strconv.FormatUint(uint64(election.ID), 10),
+			contest.Title,
+			constituencyCode,
+			ledgerIDs,
+			contest.MinSelections,
+			contest.MaxSelections,
+		)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to register contest %s: %w", contest.Title, err)
+	}
+	return &contest, nil
+}
+
+func (electionRepo *ElectionServiceImpl) GetContests(electionId uint) ([]models.Contest, error) {
+	return electionRepo.ContestRepository.GetContestsByElectionId(electionId)
+}
+
+// CastBallot submits the voter's selections for every contest of the election
+// as a single ledger transaction, so either the whole ballot counts or none
+// of it does.
+func (electionRepo *ElectionServiceImpl) CastBallot(electionId uint, voterID string, selections []models.ContestSelection) error {
+	if voterID == "" {
+		return fmt.Errorf("voter id cannot be empty")
+	}
+	if len(selections) == 0 {
+		return fmt.Errorf("ballot has no selections")
+	}
+	if err := electionRepo.assertEligible(electionId, voterID); err != nil {
+		return err
+	}
+	return electionRepo.Ledger.CastContestBallot(voterID, strconv.FormatUint(uint64(electionId), 10), selections)
+}
+
+// CastSignedBallot submits a ballot signed by the voter. The payload is the
+// canonical ballot text the voter signed and is passed to the ledger as is,
+// which verifies the signature against the voter's registered key.
+func (electionRepo *ElectionServiceImpl) CastSignedBallot(electionId uint, payload, signature string) error {
+	var ballot struct {
+		ElectionID string `json:"electionID"`
+		VoterID    string `json:"voterID"`
+	}
+	if err := json.Unmarshal([]byte(payload), &ballot); err != nil {
+		return fmt.Errorf("invalid ballot payload: %w", err)
+	}
+	if ballot.ElectionID != strconv.FormatUint(uint64(electionId), 10) {
+		return fmt.Errorf("ballot is for election %s, not %d", ballot.ElectionID, electionId)
+	}
+	if signature == "" {
+		return fmt.Errorf("signature cannot be empty")
+	}
+	if err := electionRepo.assertEligible(electionId, ballot.VoterID); err != nil {
+		return err
+	}
+	return electionRepo.Ledger.CastSignedBallot(payload, signature)
+}
+
+func (electionRepo *ElectionServiceImpl) GetContestResults(electionId uint) ([]models.ContestResult, error) {
+	return electionRepo.Ledger.ContestResults(strconv.FormatUint(uint64(electionId), 10))
+}
+
+func (electionRepo *ElectionServiceImpl) GetDistrictResults(electionId uint) (*models.DistrictResults, error) {
+	return electionRepo.Ledger.DistrictResults(strconv.FormatUint(uint64(electionId), 10))
+}
+
+// assertEligible evaluates the eligibility rules recorded for the election on
+// the ledger, which are the ones the ballot is checked against when cast.
+func (electionRepo *ElectionServiceImpl) assertEligible(electionId uint, voterID string) error {
+	rules, err := electionRepo.Ledger.EligibilityRules(strconv.FormatUint(uint64(electionId), 10))
+	if err != nil {
+		return fmt.Errorf("could not read the eligibility rules of election %d from the ledger: %w", electionId, err)
+	}
+	user, err := electionRepo.UserRepository.GetUser(voterID)
+	if err != nil {
+		return fmt.Errorf("could not find voter %s: %w", voterID, err)
+	}
+	return checkEligibility(user, *rules)
 }
@@ -0,0 +1,60 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"rest-api-go/internal/models"
+	"slices"
+	"time"
+)
+
+// ErrNotEligible is returned when a voter does not meet the eligibility rules
+// of an election.
+var ErrNotEligible = errors.New("voter is not eligible")
+
+const dateLayout = "2006-01-02"
+
+// checkEligibility evaluates the election's rules for the voter in the same
+// way as the ledger does when the ballot is cast.
+func checkEligibility(user *models.User, rules models.EligibilityRules) error {
+	if rules.MinimumAge > 0 {
+		if user.DateOfBirth == "" {
+			return fmt.Errorf("%w: no date of birth on record", ErrNotEligible)
+		}
+		born, err := time.Parse(dateLayout, user.DateOfBirth)
+		if err != nil {
+			return fmt.Errorf("invalid date of birth %q", user.DateOfBirth)
+		}
+		on, err := time.Parse(dateLayout, rules.ElectionDay)
+		if err != nil {
+			return fmt.Errorf("invalid election day %q", rules.ElectionDay)
+		}
+
+		age := on.Year() - born.Year()
+		if on.Month() < born.Month() || (on.Month() == born.Month() && on.Day() < born.Day()) {
+			age--
+		}
+		if age < rules.MinimumAge {
+			return fmt.Errorf("%w: younger than %d on election day", ErrNotEligible, rules.MinimumAge)
+		}
+	}
+	if len(rules.Citizenships) > 0 && !slices.Contains(rules.Citizenships, user.Citizenship) {
+		return fmt.Errorf("%w: citizenship is not accepted", ErrNotEligible)
+	}
+	if len(rules.Regions) > 0 && !slices.Contains(rules.Regions, user.Region) {
+		return fmt.Errorf("%w: does not reside in an eligible region", ErrNotEligible)
+	}
+	return nil
+}
+
+func validateEligibilityRules(rules models.EligibilityRules) error {
+	if rules.MinimumAge < 0 {
+		return fmt.Errorf("minimum age cannot be negative")
+	}
+	if rules.MinimumAge > 0 {
+		if _, err := time.Parse(dateLayout, rules.ElectionDay); err != nil {
+			return fmt.Errorf("a minimum age requires an election day formatted as YYYY-MM-DD")
+		}
+	}
+	return nil
+}
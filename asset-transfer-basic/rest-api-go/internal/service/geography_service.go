@@ -0,0 +1,52 @@
+package service
+
+import (
+	"fmt"
+	"rest-api-go/internal/models"
+	"rest-api-go/internal/repository"
+)
+
+type GeographyService interface {
+	RegisterConstituency(constituency *models.Constituency) error
+	RegisterPrecinct(precinct *models.Precinct) error
+	GetConstituencies() ([]models.Constituency, error)
+}
+
+type GeographyServiceImpl struct {
+	GeographyRepository repository.IGeographyRepository
+	Ledger              Ledger
+}
+
+func NewGeographyServiceImpl(geographyRepository repository.IGeographyRepository, ledger Ledger) GeographyService {
+	return &GeographyServiceImpl{GeographyRepository: geographyRepository, Ledger: ledger}
+}
+
+// RegisterConstituency records the constituency in the database and on the
+// ledger, where it is identified by its code.
+func (geographySvc *GeographyServiceImpl) RegisterConstituency(constituency *models.Constituency) error {
+	if constituency.Code == "" || constituency.Name == "" {
+		return fmt.Errorf("constituency code and name cannot be empty")
+	}
+	return geographySvc.GeographyRepository.RegisterConstituency(constituency, func() error {
+		return geographySvc.Ledger.CreateConstituency(constituency.Code, constituency.Name)
+	})
+}
+
+// RegisterPrecinct records the precinct in the database and on the ledger,
+// where it is identified by its code.
+func (geographySvc *GeographyServiceImpl) RegisterPrecinct(precinct *models.Precinct) error {
+	if precinct.Code == "" || precinct.Name == "" {
+		return fmt.Errorf("precinct code and name cannot be empty")
+	}
+	constituency, err := geographySvc.GeographyRepository.GetConstituencyById(precinct.ConstituencyID)
+	if err != nil {
+		return err
+	}
+	return geographySvc.GeographyRepository.RegisterPrecinct(precinct, func() error {
+		return geographySvc.Ledger.CreatePrecinct(precinct.Code, precinct.Name, constituency.Code)
+	})
+}
+
+func (geographySvc *GeographyServiceImpl) GetConstituencies() ([]models.Constituency, error) {
+	return geographySvc.GeographyRepository.GetConstituencies()
+}
@@ -0,0 +1,88 @@
+package service
+
+import (
+	"fmt"
+	"rest-api-go/internal/models"
+	"rest-api-go/internal/repository"
+	"time"
+)
+
+type VoterService interface {
+	SetVoterAttributes(idnp, dateOfBirth, citizenship, region string) (*models.User, error)
+	KeyChallenge(idnp, keyPEM string) (string, error)
+	RegisterVoterKey(idnp, keyPEM, signature string) (*models.User, error)
+}
+
+type VoterServiceImpl struct {
+	UserRepository repository.IUserRepository
+	Ledger         Ledger
+}
+
+func NewVoterServiceImpl(userRepository repository.IUserRepository, ledger Ledger) VoterService {
+	return &VoterServiceImpl{UserRepository: userRepository, Ledger: ledger}
+}
+
+// SetVoterAttributes records the voter's eligibility attributes on the ledger
+// and then in the database.
+func (voterSvc *VoterServiceImpl) SetVoterAttributes(idnp, dateOfBirth, citizenship, region string) (*models.User, error) {
+	if dateOfBirth != "" {
+		if _, err := time.Parse(dateLayout, dateOfBirth); err != nil {
+			return nil, fmt.Errorf("date of birth must be formatted as YYYY-MM-DD")
+		}
+	}
+
+	user, err := voterSvc.UserRepository.GetUser(idnp)
+	if err != nil {
+		return nil, fmt.Errorf("could not find voter %s: %w", idnp, err)
+	}
+
+	err = voterSvc.Ledger.SetVoterAttributes(idnp, dateOfBirth, citizenship, region)
+	if err != nil {
+		return nil, err
+	}
+
+	user.DateOfBirth = dateOfBirth
+	user.Citizenship = citizenship
+	user.Region = region
+	if err := voterSvc.UserRepository.UpdateUser(user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// KeyChallenge returns the text the voter signs with keyPEM before the key
+// can be registered.
+func (voterSvc *VoterServiceImpl) KeyChallenge(idnp, keyPEM string) (string, error) {
+	if keyPEM == "" {
+		return "", fmt.Errorf("signing key cannot be empty")
+	}
+	return voterSvc.Ledger.KeyChallenge(idnp, keyPEM)
+}
+
+// RegisterVoterKey enrolls the certificate or public key the voter signs
+// ballots with, on the ledger and then in the database. signature is the
+// voter's signature of the key challenge with that key.
+func (voterSvc *VoterServiceImpl) RegisterVoterKey(idnp, keyPEM, signature string) (*models.User, error) {
+	if keyPEM == "" {
+		return nil, fmt.Errorf("signing key cannot be empty")
+	}
+	if signature == "" {
+		return nil, fmt.Errorf("key challenge signature cannot be empty")
+	}
+
+	user, err := voterSvc.UserRepository.GetUser(idnp)
+	if err != nil {
+		return nil, fmt.Errorf("could not find voter %s: %w", idnp, err)
+	}
+
+	err = voterSvc.Ledger.RegisterVoterKey(idnp, keyPEM, signature)
+	if err != nil {
+		return nil, err
+	}
+
+	user.SigningKey = keyPEM
+	if err := voterSvc.UserRepository.UpdateUser(user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
@@ -36,11 +36,11 @@ func main() {
 		config.Cfg.RedisDatabase.RedisDB,
 	)
 
-	postgresConnection := postgres.ConnectionPostgres{connectionStringPostgres}
+	postgresConnection := postgres.ConnectionPostgres{ConnectionString: connectionStringPostgres}
 	con := pkg.DBConnection{Db: postgresConnection}
 	DBPostgres := con.DBConnect()
 
-	redisConnection := redis.ConnectionRedis{connectionStringRedis}
+	redisConnection := redis.ConnectionRedis{ConnectionString: connectionStringRedis}
 	con1 := pkg.DBConnection{Db: redisConnection}
 	RedisClient := con1.DBConnect()
 
@@ -64,13 +64,14 @@ func main() {
 	if err != nil {
 		fmt.Println("Error initializing setup for Org1: ", err)
 	}
-	r := fiber.New()
+	r := fiber.New(fiber.Config{StreamRequestBody: true})
 	r.Use(cors.New(cors.Config{
 		AllowHeaders: "Origin,Content-Type,Accept,Content-Length,Accept-Language,Accept-Encoding,Connection,Access-Control-Allow-Origin",
 		AllowOrigins: "*",
 		AllowMethods: "GET,POST,HEAD,PUT,DELETE,PATCH,OPTIONS",
 	}))
-	registerRoutes(r, DBPostgres)
+	ledger := web.NewLedgerClient(*orgSetup, config.Cfg.Fabric.Channel, config.Cfg.Fabric.Chaincode, config.Cfg.Fabric.Organizations)
+	registerRoutes(r, DBPostgres, ledger)
 	web.Serve(web.OrgSetup(*orgSetup), r)
 
 	if err := r.Listen(":3000"); err != nil {
@@ -78,11 +79,23 @@ func main() {
 	}
 }
 
-func registerRoutes(r *fiber.App, dbClient any) {
+func registerRoutes(r *fiber.App, dbClient any, ledger service.Ledger) {
 	electionRepo := repository.NewElectionRepository(dbClient)
 	candidatesRepo := repository.NewCandidateRepository(dbClient)
-	electionSvc := service.NewElectionServiceImpl(electionRepo, candidatesRepo)
+	contestRepo := repository.NewContestRepository(dbClient)
+	geographyRepo := repository.NewGeographyRepository(dbClient)
+	userRepo := repository.NewUserRepository(dbClient)
+	electionSvc := service.NewElectionServiceImpl(electionRepo, candidatesRepo, contestRepo, geographyRepo, userRepo, ledger)
 	electionCtrl := controller.NewElectionController(electionSvc)
+	if err := electionSvc.BackfillCandidateIDs(); err != nil {
+		fmt.Println("Error registering candidates without a UUID: ", err)
+	}
+	geographySvc := service.NewGeographyServiceImpl(geographyRepo, ledger)
+	geographyCtrl := controller.NewGeographyController(geographySvc)
+	voterSvc := service.NewVoterServiceImpl(userRepo, ledger)
+	voterCtrl := controller.NewVoterController(voterSvc)
 
 	router.RegisterElectionRoutes(r, electionCtrl)
+	router.RegisterGeographyRoutes(r, geographyCtrl)
+	router.RegisterVoterRoutes(r, voterCtrl)
 }
@@ -0,0 +1,281 @@
+// Package jcj implements the credential handling borrowed from JCJ voting:
+// ElGamal encryption of voter credentials and plaintext-equivalence tests
+// used to filter out ballots cast with fake credentials before tallying.
+//
+// The group is the 2048-bit MODP group from RFC 3526 restricted to its
+// prime-order subgroup of quadratic residues. The tallying authority is a
+// single key holder that runs the whole filter, so it can link every counted
+// ballot to its entry on the credential roll, and thus to the voter, and
+// nobody else can check its result. This is not coercion-resistant: that
+// needs the key shared between independent talliers and the roll and ballots
+// passed through a verifiable mix before any comparison, which is out of
+// scope.
+package jcj
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+const groupPrimeHex = "FFFFFFFFFFFFFFFFC90FDAA22168C234C4C6628B80DC1CD1" +
+	"29024E088A67CC74020BBEA63B139B22514A08798E3404DD" +
+	"EF9519B3CD3A431B302B0A6DF25F14374FE1356D6D51C245" +
+	"E485B576625E7EC6F44C42E9A637ED6B0BFF5CB6F406B7ED" +
+	"EE386BFB5A899FA5AE9F24117C4B1FE649286651ECE45B3D" +
+	"C2007CB8A163BF0598DA48361C55D39A69163FA8FD24CF5F" +
+	"83655D23DCA3AD961C62F356208552BB9ED529077096966D" +
+	"670C354E4ABC9804F1746C08CA18217C32905E462E36CE3B" +
+	"E39E772C180E86039B2783A2EC07A28FB5C55DF06F4C52C9" +
+	"DE2BCBF6955817183995497CEA956AE515D2261898FA0510" +
+	"15728E5A8AACAA68FFFFFFFFFFFFFFFF"
+
+var (
+	p, _ = new(big.Int).SetString(groupPrimeHex, 16)
+	// q is the order of the subgroup of quadratic residues.
+	q = new(big.Int).Rsh(new(big.Int).Sub(p, big.NewInt(1)), 1)
+	// g = 2^2 generates the subgroup of quadratic residues.
+	g = big.NewInt(4)
+
+	elementSize = (p.BitLen() + 7) / 8
+	one         = big.NewInt(1)
+)
+
+var ErrMalformedCiphertext = errors.New("malformed ciphertext")
+
+// PrivateKey is the tallying authority's decryption key.
+type PrivateKey struct {
+	x *big.Int
+	PublicKey
+}
+
+// PublicKey is used by registrars and voters to encrypt credentials.
+type PublicKey struct {
+	y *big.Int
+}
+
+// Credential is a voter credential. Real and fake credentials are drawn from
+// the same distribution and cannot be told apart without the private key and
+// the credential roll.
+type Credential struct {
+	s *big.Int
+}
+
+// Ciphertext is an ElGamal encryption of g^credential.
+type Ciphertext struct {
+	a, b *big.Int
+}
+
+func GenerateKey() (*PrivateKey, error) {
+	x, err := randomExponent()
+	if err != nil {
+		return nil, err
+	}
+
+	return &PrivateKey{x: x, PublicKey: PublicKey{y: new(big.Int).Exp(g, x, p)}}, nil
+}
+
+// NewCredential returns a fresh random credential. Registrars use it to issue
+// real credentials and voters use it to produce fake ones.
+func NewCredential() (*Credential, error) {
+	s, err := randomExponent()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Credential{s: s}, nil
+}
+
+// Encrypt returns a fresh randomized encryption of credential under pub.
+func (pub *PublicKey) Encrypt(credential *Credential) (*Ciphertext, error) {
+	r, err := randomExponent()
+	if err != nil {
+		return nil, err
+	}
+
+	m := new(big.Int).Exp(g, credential.s, p)
+	a := new(big.Int).Exp(g, r, p)
+	b := new(big.Int).Exp(pub.y, r, p)
+	b.Mul(b, m).Mod(b, p)
+
+	return &Ciphertext{a: a, b: b}, nil
+}
+
+// ReEncrypt returns a fresh encryption of the same credential as c, which
+// cannot be linked to c without the private key.
+func (pub *PublicKey) ReEncrypt(c *Ciphertext) (*Ciphertext, error) {
+	r, err := randomExponent()
+	if err != nil {
+		return nil, err
+	}
+
+	a := new(big.Int).Exp(g, r, p)
+	a.Mul(a, c.a).Mod(a, p)
+	b := new(big.Int).Exp(pub.y, r, p)
+	b.Mul(b, c.b).Mod(b, p)
+
+	return &Ciphertext{a: a, b: b}, nil
+}
+
+// PlaintextEquivalent reports whether c1 and c2 encrypt the same credential.
+// The quotient of the ciphertexts is blinded with a random exponent before
+// decryption, so nothing but the equality is revealed.
+func (priv *PrivateKey) PlaintextEquivalent(c1, c2 *Ciphertext) (bool, error) {
+	z, err := randomExponent()
+	if err != nil {
+		return false, err
+	}
+
+	a := new(big.Int).ModInverse(c2.a, p)
+	a.Mul(a, c1.a).Mod(a, p).Exp(a, z, p)
+	b := new(big.Int).ModInverse(c2.b, p)
+	b.Mul(b, c1.b).Mod(b, p).Exp(b, z, p)
+
+	// b / a^x is the identity exactly when both ciphertexts hide the same
+	// plaintext.
+	shared := new(big.Int).Exp(a, priv.x, p)
+	shared.ModInverse(shared, p)
+	return shared.Mul(shared, b).Mod(shared, p).Cmp(one) == 0, nil
+}
+
+// Ballot is the part of a cast ballot needed for filtering.
+type Ballot struct {
+	ID         string
+	Credential *Ciphertext
+}
+
+// Filter returns the IDs of the ballots to be counted, in the order they
+// were cast. ballots must be in the order they were cast. Only the last
+// ballot cast with each credential is kept, and ballots whose credential is
+// not on roll are dropped. The caller holds the full key, and learns which
+// entry of the roll each counted ballot matches.
+func (priv *PrivateKey) Filter(roll []*Ciphertext, ballots []Ballot) ([]string, error) {
+	// Duplicates are removed while the ballots are still in cast order.
+	var latest []Ballot
+	for i, ballot := range ballots {
+		superseded := false
+		for _, later := range ballots[i+1:] {
+			equivalent, err := priv.PlaintextEquivalent(ballot.Credential, later.Credential)
+			if err != nil {
+				return nil, err
+			}
+			if equivalent {
+				superseded = true
+				break
+			}
+		}
+		if !superseded {
+			latest = append(latest, ballot)
+		}
+	}
+
+	var accepted []string
+	for _, ballot := range latest {
+		for _, entry := range roll {
+			equivalent, err := priv.PlaintextEquivalent(ballot.Credential, entry)
+			if err != nil {
+				return nil, err
+			}
+			if equivalent {
+				accepted = append(accepted, ballot.ID)
+				break
+			}
+		}
+	}
+
+	return accepted, nil
+}
+
+func (priv *PrivateKey) String() string {
+	return encodeElement(priv.x)
+}
+
+func (pub *PublicKey) String() string {
+	return encodeElement(pub.y)
+}
+
+func (credential *Credential) String() string {
+	return encodeElement(credential.s)
+}
+
+// String encodes the ciphertext in the "<hex>.<hex>" form stored on the ledger.
+func (c *Ciphertext) String() string {
+	return encodeElement(c.a) + "." + encodeElement(c.b)
+}
+
+func ParsePrivateKey(value string) (*PrivateKey, error) {
+	x, err := decodeElement(value, q)
+	if err != nil {
+		return nil, fmt.Errorf("malformed private key: %w", err)
+	}
+
+	return &PrivateKey{x: x, PublicKey: PublicKey{y: new(big.Int).Exp(g, x, p)}}, nil
+}
+
+func ParsePublicKey(value string) (*PublicKey, error) {
+	y, err := decodeElement(value, p)
+	if err != nil {
+		return nil, fmt.Errorf("malformed public key: %w", err)
+	}
+
+	return &PublicKey{y: y}, nil
+}
+
+func ParseCredential(value string) (*Credential, error) {
+	s, err := decodeElement(value, q)
+	if err != nil {
+		return nil, fmt.Errorf("malformed credential: %w", err)
+	}
+
+	return &Credential{s: s}, nil
+}
+
+func ParseCiphertext(value string) (*Ciphertext, error) {
+	parts := strings.Split(value, ".")
+	if len(parts) != 2 {
+		return nil, ErrMalformedCiphertext
+	}
+	a, err := decodeElement(parts[0], p)
+	if err != nil {
+		return nil, ErrMalformedCiphertext
+	}
+	b, err := decodeElement(parts[1], p)
+	if err != nil {
+		return nil, ErrMalformedCiphertext
+	}
+
+	return &Ciphertext{a: a, b: b}, nil
+}
+
+func randomExponent() (*big.Int, error) {
+	for {
+		k, err := rand.Int(rand.Reader, q)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate random exponent: %w", err)
+		}
+		if k.Sign() > 0 {
+			return k, nil
+		}
+	}
+}
+
+func encodeElement(value *big.Int) string {
+	return hex.EncodeToString(value.FillBytes(make([]byte, elementSize)))
+}
+
+func decodeElement(value string, limit *big.Int) (*big.Int, error) {
+	raw, err := hex.DecodeString(value)
+	if err != nil {
+		return nil, err
+	}
+
+	element := new(big.Int).SetBytes(raw)
+	if element.Sign() <= 0 || element.Cmp(limit) >= 0 {
+		return nil, errors.New("value out of range")
+	}
+
+	return element, nil
+}
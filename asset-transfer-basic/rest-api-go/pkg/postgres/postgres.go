@@ -24,6 +24,9 @@ func (con ConnectionPostgres) Connect() any {
 		&models.User{},
 		&models.Election{},
 		&models.Candidate{},
+		&models.Contest{},
+		&models.Constituency{},
+		&models.Precinct{},
 	)
 	if err != nil {
 		return nil
@@ -41,6 +41,18 @@ func (o OrgHandlerFactory) CreateHandler(action string) http.HandlerFunc {
 		return o.setups.QueryHandler
 	case "invoke":
 		return o.setups.Invoke
+	case "history":
+		return o.setups.HistoryHandler
+	case "disputes":
+		return o.setups.DisputeHandler
+	case "ballot":
+		return o.setups.BallotHandler
+	case "status":
+		return o.setups.StatusHandler
+	case "voter-import":
+		return o.setups.VoterImportHandler
+	case "voter-import-status":
+		return o.setups.VoterImportStatusHandler
 	default:
 		return func(w http.ResponseWriter, r *http.Request) {
 			http.Error(w, "Invalid action", http.StatusNotFound)
@@ -63,6 +75,42 @@ func Serve(setups OrgSetup, r *fiber.App) {
 		return nil
 	})
 
+	r.Get("/history", func(c *fiber.Ctx) error {
+		handler := factory.CreateHandler("history")
+		adaptHandlerFuncToFiber(handler, c)
+		return nil
+	})
+
+	r.Get("/disputes", func(c *fiber.Ctx) error {
+		handler := factory.CreateHandler("disputes")
+		adaptHandlerFuncToFiber(handler, c)
+		return nil
+	})
+
+	r.Get("/ballot", func(c *fiber.Ctx) error {
+		handler := factory.CreateHandler("ballot")
+		adaptHandlerFuncToFiber(handler, c)
+		return nil
+	})
+
+	r.Get("/status", func(c *fiber.Ctx) error {
+		handler := factory.CreateHandler("status")
+		adaptHandlerFuncToFiber(handler, c)
+		return nil
+	})
+
+	r.Post("/voters/import", func(c *fiber.Ctx) error {
+		handler := factory.CreateHandler("voter-import")
+		adaptHandlerFuncToFiber(handler, c)
+		return nil
+	})
+
+	r.Get("/voters/import", func(c *fiber.Ctx) error {
+		handler := factory.CreateHandler("voter-import-status")
+		adaptHandlerFuncToFiber(handler, c)
+		return nil
+	})
+
 	//http.HandleFunc("/query", factory.CreateHandler("query"))
 	//http.HandleFunc("/invoke", factory.CreateHandler("invoke"))
 	fmt.Println("Listening (http://localhost:3000/)...")
@@ -117,6 +165,11 @@ func toHTTPRequest(c *fiber.Ctx) *http.Request {
 	c.Request().Header.VisitAll(func(key, value []byte) {
 		req.Header.Add(string(key), string(value))
 	})
-	req.Body = io.NopCloser(bytes.NewReader(c.Body()))
+	// Large uploads are streamed to the handler instead of being buffered.
+	if stream := c.Context().RequestBodyStream(); stream != nil {
+		req.Body = io.NopCloser(stream)
+	} else {
+		req.Body = io.NopCloser(bytes.NewReader(c.Body()))
+	}
 	return req
 }
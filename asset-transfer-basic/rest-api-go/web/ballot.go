@@ -0,0 +1,42 @@
+package web
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+// BallotHandler returns the ballot definition of electionid, or the version
+// given by version. Voting clients pass the definition hash back with
+// CastBallot, so the document is read from the ledger exactly as stored.
+func (setup OrgSetup) BallotHandler(w http.ResponseWriter, r *http.Request) {
+	fmt.Println("Received Ballot request")
+	queryParams := r.URL.Query()
+	chainCodeName := queryParams.Get("chaincodeid")
+	channelID := queryParams.Get("channelid")
+	electionID := queryParams.Get("electionid")
+	version := queryParams.Get("version")
+	fmt.Printf("channel: %s, chaincode: %s, election: %s, version: %s\n", channelID, chainCodeName, electionID, version)
+
+	if electionID == "" {
+		http.Error(w, "Error: missing election id", http.StatusBadRequest)
+		return
+	}
+
+	function, args := "ReadBallotDefinition", []string{electionID}
+	if version != "" {
+		if _, err := strconv.Atoi(version); err != nil {
+			http.Error(w, fmt.Sprintf("Error: invalid version %q", version), http.StatusBadRequest)
+			return
+		}
+		function, args = "ReadBallotDefinitionVersion", []string{electionID, version}
+	}
+
+	query := &SimpleQuery{setup: setup}
+	response, err := query.Query(chainCodeName, channelID, function, args)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error: %s", err), http.StatusInternalServerError)
+		return
+	}
+	fmt.Fprintf(w, "Response: %s", response)
+}
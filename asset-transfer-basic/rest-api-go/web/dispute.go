@@ -0,0 +1,38 @@
+package web
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// DisputeHandler returns a single dispute when id is given, or every dispute
+// filed against electionid. Disputes are read from the ledger uncached so the
+// current step of the workflow is always visible.
+func (setup OrgSetup) DisputeHandler(w http.ResponseWriter, r *http.Request) {
+	fmt.Println("Received Dispute request")
+	queryParams := r.URL.Query()
+	chainCodeName := queryParams.Get("chaincodeid")
+	channelID := queryParams.Get("channelid")
+	disputeID := queryParams.Get("id")
+	electionID := queryParams.Get("electionid")
+	fmt.Printf("channel: %s, chaincode: %s, dispute: %s, election: %s\n", channelID, chainCodeName, disputeID, electionID)
+
+	var function, arg string
+	switch {
+	case disputeID != "":
+		function, arg = "ReadDispute", disputeID
+	case electionID != "":
+		function, arg = "GetDisputesByElection", electionID
+	default:
+		http.Error(w, "Error: missing dispute id or election id", http.StatusBadRequest)
+		return
+	}
+
+	query := &SimpleQuery{setup: setup}
+	response, err := query.Query(chainCodeName, channelID, function, []string{arg})
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error: %s", err), http.StatusInternalServerError)
+		return
+	}
+	fmt.Fprintf(w, "Response: %s", response)
+}
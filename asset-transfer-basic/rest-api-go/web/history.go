@@ -0,0 +1,44 @@
+package web
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// historyFunctions maps the asset types exposed over REST to the chaincode
+// functions returning their change history.
+var historyFunctions = map[string]string{
+	"voter":     "GetVoterHistory",
+	"candidate": "GetCandidateHistory",
+	"election":  "GetElectionHistory",
+}
+
+// HistoryHandler returns the ledger history of a voter, candidate or election.
+// History is always read from the ledger so auditors never see a cached view.
+func (setup OrgSetup) HistoryHandler(w http.ResponseWriter, r *http.Request) {
+	fmt.Println("Received History request")
+	queryParams := r.URL.Query()
+	chainCodeName := queryParams.Get("chaincodeid")
+	channelID := queryParams.Get("channelid")
+	asset := queryParams.Get("asset")
+	id := queryParams.Get("id")
+	fmt.Printf("channel: %s, chaincode: %s, asset: %s, id: %s\n", channelID, chainCodeName, asset, id)
+
+	function, ok := historyFunctions[asset]
+	if !ok {
+		http.Error(w, fmt.Sprintf("Error: unknown asset type %q", asset), http.StatusBadRequest)
+		return
+	}
+	if id == "" {
+		http.Error(w, "Error: missing asset id", http.StatusBadRequest)
+		return
+	}
+
+	query := &SimpleQuery{setup: setup}
+	response, err := query.Query(chainCodeName, channelID, function, []string{id})
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error: %s", err), http.StatusInternalServerError)
+		return
+	}
+	fmt.Fprintf(w, "Response: %s", response)
+}
@@ -41,6 +41,9 @@ func (setup *OrgSetup) Invoke(w http.ResponseWriter, r *http.Request) {
 
 	response, err := chainCodeProxy.ValidateAndForward(function, args, forward)
 	if err != nil {
+		if isPausedError(err) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
 		fmt.Fprintf(w, "Error: %s", err)
 		return
 	}
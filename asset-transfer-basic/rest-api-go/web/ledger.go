@@ -0,0 +1,281 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/hyperledger/fabric-gateway/pkg/client"
+	"rest-api-go/internal/models"
+	"strconv"
+)
+
+// LedgerClient submits transactions on a fixed channel and chaincode for the
+// services that keep the registration database and the ledger in step.
+// Elections are created on the ledger with organizations, whose peers must
+// endorse every later change to them.
+type LedgerClient struct {
+	setup         OrgSetup
+	channelID     string
+	chainCodeName string
+	organizations []string
+}
+
+func NewLedgerClient(setup OrgSetup, channelID, chainCodeName string, organizations []string) *LedgerClient {
+	return &LedgerClient{setup: setup, channelID: channelID, chainCodeName: chainCodeName, organizations: organizations}
+}
+
+func (lc *LedgerClient) CreateElection(electionID, title string) error {
+	if len(lc.organizations) == 0 {
+		return fmt.Errorf("no organizations are configured to endorse elections")
+	}
+	network := lc.setup.Gateway.GetNetwork(lc.channelID)
+	contract := network.GetContract(lc.chainCodeName)
+
+	organizationsJSON, err := json.Marshal(lc.organizations)
+	if err != nil {
+		return err
+	}
+	_, err = contract.SubmitTransaction("CreateElection", electionID, title, string(organizationsJSON))
+	if err != nil {
+		return fmt.Errorf("Error submitting transaction: %s", err)
+	}
+	return nil
+}
+
+// electionRules is the ElectionRules argument of SetElectionRules. The
+// elections created through this service use the default voting options and
+// only restrict eligibility.
+type electionRules struct {
+	AllowRevote          bool                    `json:"allowRevote"`
+	Mode                 string                  `json:"mode"`
+	CredentialPublicKey  string                  `json:"credentialPublicKey"`
+	AllowDelegation      bool                    `json:"allowDelegation"`
+	MaxDelegationDepth   int                     `json:"maxDelegationDepth"`
+	Weighted             bool                    `json:"weighted"`
+	AllowWriteIns        bool                    `json:"allowWriteIns"`
+	WithdrawnVotes       string                  `json:"withdrawnVotes"`
+	Ordering             string                  `json:"ordering"`
+	Eligibility          models.EligibilityRules `json:"eligibility"`
+	RequireSignedBallots bool                    `json:"requireSignedBallots"`
+}
+
+func (lc *LedgerClient) SetEligibilityRules(electionID string, eligibility models.EligibilityRules) error {
+	network := lc.setup.Gateway.GetNetwork(lc.channelID)
+	contract := network.GetContract(lc.chainCodeName)
+
+	// The ledger omits empty lists, and null is not a valid list.
+	if eligibility.Citizenships == nil {
+		eligibility.Citizenships = []string{}
+	}
+	if eligibility.Regions == nil {
+		eligibility.Regions = []string{}
+	}
+	rulesJSON, err := json.Marshal(electionRules{Eligibility: eligibility})
+	if err != nil {
+		return err
+	}
+	_, err = contract.SubmitTransaction("SetElectionRules", electionID, string(rulesJSON))
+	if err != nil {
+		return fmt.Errorf("Error submitting transaction: %s", err)
+	}
+	return nil
+}
+
+func (lc *LedgerClient) EligibilityRules(electionID string) (*models.EligibilityRules, error) {
+	network := lc.setup.Gateway.GetNetwork(lc.channelID)
+	contract := network.GetContract(lc.chainCodeName)
+
+	response, err := contract.EvaluateTransaction("ReadElection", electionID)
+	if err != nil {
+		return nil, err
+	}
+
+	var election struct {
+		Rules electionRules `json:"rules"`
+	}
+	if err := json.Unmarshal(response, &election); err != nil {
+		return nil, fmt.Errorf("invalid election: %s", err)
+	}
+	return &election.Rules.Eligibility, nil
+}
+
+func (lc *LedgerClient) RegisterCandidate(candidateID, name, electionID, party string) error {
+	network := lc.setup.Gateway.GetNetwork(lc.channelID)
+	contract := network.GetContract(lc.chainCodeName)
+	_, err := contract.SubmitTransaction("RegisterCandidate", candidateID, name, electionID, party)
+	if err != nil {
+		return fmt.Errorf("Error submitting transaction: %s", err)
+	}
+	return nil
+}
+
+func (lc *LedgerClient) WithdrawCandidate(candidateID, reason string) error {
+	network := lc.setup.Gateway.GetNetwork(lc.channelID)
+	contract := network.GetContract(lc.chainCodeName)
+	_, err := contract.SubmitTransaction("WithdrawCandidate", candidateID, reason)
+	if err != nil {
+		return fmt.Errorf("Error submitting transaction: %s", err)
+	}
+	return nil
+}
+
+func (lc *LedgerClient) CandidateOrder(electionID, voterID string) ([]string, error) {
+	network := lc.setup.Gateway.GetNetwork(lc.channelID)
+	contract := network.GetContract(lc.chainCodeName)
+
+	function, args := "GetCandidatesByElection", []string{electionID}
+	if voterID != "" {
+		function, args = "GetCandidatesForVoter", []string{electionID, voterID}
+	}
+	response, err := contract.EvaluateTransaction(function, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	var candidates []struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(response, &candidates); err != nil {
+		return nil, fmt.Errorf("invalid candidate list: %s", err)
+	}
+	order := make([]string, 0, len(candidates))
+	for _, candidate := range candidates {
+		order = append(order, candidate.ID)
+	}
+	return order, nil
+}
+
+func (lc *LedgerClient) CreateContest(contestID, electionID, title, constituencyID string, candidateIDs []string, minSelections, maxSelections int) error {
+	network := lc.setup.Gateway.GetNetwork(lc.channelID)
+	contract := network.GetContract(lc.chainCodeName)
+
+	candidatesJSON, err := json.Marshal(candidateIDs)
+	if err != nil {
+		return err
+	}
+	_, err = contract.SubmitTransaction("CreateContest", electionID, contestID, title, constituencyID, string(candidatesJSON), strconv.Itoa(minSelections), strconv.Itoa(maxSelections))
+	if err != nil {
+		return fmt.Errorf("Error submitting transaction: %s", err)
+	}
+	return nil
+}
+
+func (lc *LedgerClient) CastContestBallot(voterID, electionID string, selections []models.ContestSelection) error {
+	network := lc.setup.Gateway.GetNetwork(lc.channelID)
+	contract := network.GetContract(lc.chainCodeName)
+
+	selectionsJSON, err := json.Marshal(selections)
+	if err != nil {
+		return err
+	}
+	_, err = contract.SubmitTransaction("CastContestBallot", voterID, electionID, string(selectionsJSON))
+	if err != nil {
+		return fmt.Errorf("Error submitting transaction: %s", err)
+	}
+	return nil
+}
+
+func (lc *LedgerClient) ContestResults(electionID string) ([]models.ContestResult, error) {
+	network := lc.setup.Gateway.GetNetwork(lc.channelID)
+	contract := network.GetContract(lc.chainCodeName)
+
+	response, err := contract.EvaluateTransaction("GetContestResults", electionID)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []models.ContestResult
+	if err := json.Unmarshal(response, &results); err != nil {
+		return nil, fmt.Errorf("invalid contest results: %s", err)
+	}
+	return results, nil
+}
+
+func (lc *LedgerClient) DistrictResults(electionID string) (*models.DistrictResults, error) {
+	network := lc.setup.Gateway.GetNetwork(lc.channelID)
+	contract := network.GetContract(lc.chainCodeName)
+
+	response, err := contract.EvaluateTransaction("GetDistrictResults", electionID)
+	if err != nil {
+		return nil, err
+	}
+
+	var results models.DistrictResults
+	if err := json.Unmarshal(response, &results); err != nil {
+		return nil, fmt.Errorf("invalid district results: %s", err)
+	}
+	return &results, nil
+}
+
+func (lc *LedgerClient) CreateConstituency(constituencyID, name string) error {
+	network := lc.setup.Gateway.GetNetwork(lc.channelID)
+	contract := network.GetContract(lc.chainCodeName)
+	_, err := contract.SubmitTransaction("CreateConstituency", constituencyID, name)
+	if err != nil {
+		return fmt.Errorf("Error submitting transaction: %s", err)
+	}
+	return nil
+}
+
+func (lc *LedgerClient) CreatePrecinct(precinctID, name, constituencyID string) error {
+	network := lc.setup.Gateway.GetNetwork(lc.channelID)
+	contract := network.GetContract(lc.chainCodeName)
+	_, err := contract.SubmitTransaction("CreatePrecinct", precinctID, name, constituencyID)
+	if err != nil {
+		return fmt.Errorf("Error submitting transaction: %s", err)
+	}
+	return nil
+}
+
+func (lc *LedgerClient) SetVoterAttributes(voterID, dateOfBirth, citizenship, region string) error {
+	network := lc.setup.Gateway.GetNetwork(lc.channelID)
+	contract := network.GetContract(lc.chainCodeName)
+
+	attributesJSON, err := json.Marshal(map[string]string{
+		"dateOfBirth": dateOfBirth,
+		"citizenship": citizenship,
+		"region":      region,
+	})
+	if err != nil {
+		return err
+	}
+	// The attributes travel as transient data, which is kept out of the
+	// transaction recorded on the ledger.
+	_, err = contract.Submit("SetVoterAttributes",
+		client.WithArguments(voterID),
+		client.WithTransient(map[string][]byte{"attributes": attributesJSON}),
+	)
+	if err != nil {
+		return fmt.Errorf("Error submitting transaction: %s", err)
+	}
+	return nil
+}
+
+func (lc *LedgerClient) KeyChallenge(voterID, keyPEM string) (string, error) {
+	network := lc.setup.Gateway.GetNetwork(lc.channelID)
+	contract := network.GetContract(lc.chainCodeName)
+	response, err := contract.EvaluateTransaction("ComputeKeyChallenge", voterID, keyPEM)
+	if err != nil {
+		return "", err
+	}
+	return string(response), nil
+}
+
+func (lc *LedgerClient) RegisterVoterKey(voterID, keyPEM, signature string) error {
+	network := lc.setup.Gateway.GetNetwork(lc.channelID)
+	contract := network.GetContract(lc.chainCodeName)
+	_, err := contract.SubmitTransaction("RegisterVoterKey", voterID, keyPEM, signature)
+	if err != nil {
+		return fmt.Errorf("Error submitting transaction: %s", err)
+	}
+	return nil
+}
+
+func (lc *LedgerClient) CastSignedBallot(payload, signature string) error {
+	network := lc.setup.Gateway.GetNetwork(lc.channelID)
+	contract := network.GetContract(lc.chainCodeName)
+	_, err := contract.SubmitTransaction("CastSignedBallot", payload, signature)
+	if err != nil {
+		return fmt.Errorf("Error submitting transaction: %s", err)
+	}
+	return nil
+}
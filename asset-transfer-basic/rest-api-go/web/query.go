@@ -6,6 +6,7 @@ import (
 	"github.com/redis/go-redis/v9"
 	"log"
 	"net/http"
+	"strconv"
 	"time"
 )
 
@@ -71,6 +72,13 @@ func (rcq *RedisCacheQueryDecorator) Query(chainCodeName, channelID, function st
 	return result, nil
 }
 
+// pageFunctions maps the asset types that can be paged with the asset
+// parameter to the chaincode functions returning a page of them.
+var pageFunctions = map[string]string{
+	"voter":     "registry:GetVotersWithPagination",
+	"candidate": "GetAllAssetsWithPagination",
+}
+
 func (setup OrgSetup) QueryHandler(w http.ResponseWriter, r *http.Request) {
 	fmt.Println("Received Query request")
 	queryParams := r.URL.Query()
@@ -78,6 +86,28 @@ func (setup OrgSetup) QueryHandler(w http.ResponseWriter, r *http.Request) {
 	channelID := queryParams.Get("channelid")
 	function := queryParams.Get("function")
 	args := r.URL.Query()["args"]
+	if asset := queryParams.Get("asset"); function == "" && asset != "" {
+		pageFunction, ok := pageFunctions[asset]
+		if !ok {
+			http.Error(w, fmt.Sprintf("Error: unknown asset type %q", asset), http.StatusBadRequest)
+			return
+		}
+		if queryParams.Get("limit") == "" {
+			http.Error(w, "Error: missing limit", http.StatusBadRequest)
+			return
+		}
+		function = pageFunction
+	}
+
+	// Paginated chaincode functions take the page size and bookmark as their
+	// trailing arguments.
+	if limit := queryParams.Get("limit"); limit != "" {
+		if _, err := strconv.ParseInt(limit, 10, 32); err != nil {
+			http.Error(w, fmt.Sprintf("Error: invalid limit %q", limit), http.StatusBadRequest)
+			return
+		}
+		args = append(args, limit, queryParams.Get("cursor"))
+	}
 	fmt.Printf("channel: %s, chaincode: %s, function: %s, args: %s\n", channelID, chainCodeName, function, args)
 
 	baseQuery := &SimpleQuery{setup: setup}
@@ -0,0 +1,35 @@
+package web
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// pausedErrorPrefix starts the chaincode error returned for transactions
+// rejected while the contract or an election is paused.
+const pausedErrorPrefix = "PAUSED"
+
+// StatusHandler reports whether the contract, and the election given by
+// electionid if any, is paused. The status is read from the ledger uncached
+// so that a pause is visible as soon as it is committed.
+func (setup OrgSetup) StatusHandler(w http.ResponseWriter, r *http.Request) {
+	fmt.Println("Received Status request")
+	queryParams := r.URL.Query()
+	chainCodeName := queryParams.Get("chaincodeid")
+	channelID := queryParams.Get("channelid")
+	electionID := queryParams.Get("electionid")
+	fmt.Printf("channel: %s, chaincode: %s, election: %s\n", channelID, chainCodeName, electionID)
+
+	query := &SimpleQuery{setup: setup}
+	response, err := query.Query(chainCodeName, channelID, "GetPauseStatus", []string{electionID})
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error: %s", err), http.StatusInternalServerError)
+		return
+	}
+	fmt.Fprintf(w, "Response: %s", response)
+}
+
+func isPausedError(err error) bool {
+	return strings.Contains(err.Error(), pausedErrorPrefix+":")
+}
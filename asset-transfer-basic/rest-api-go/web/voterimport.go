@@ -0,0 +1,371 @@
+package web
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"rest-api-go/internal/models"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	defaultImportChunkSize = 200
+	// maxImportChunkSize matches the batch limit enforced by the chaincode.
+	maxImportChunkSize = 500
+	// importLockTTL bounds how long a crashed upload keeps its import locked.
+	// A running upload refreshes the lock well before it expires.
+	importLockTTL = 10 * time.Minute
+
+	ImportStatusRunning   = "Running"
+	ImportStatusFailed    = "Failed"
+	ImportStatusCompleted = "Completed"
+)
+
+// VoterRecord is a single voter sent to RegisterVotersBatch.
+type VoterRecord struct {
+	ID         string `json:"id"`
+	Name       string `json:"name"`
+	PrecinctID string `json:"precinctID,omitempty"`
+}
+
+// BatchResult is the per-record outcome returned by RegisterVotersBatch.
+type BatchResult struct {
+	ID      string `json:"id"`
+	Success bool   `json:"success"`
+	Error   string `json:"error,omitempty"`
+}
+
+// VoterImport is the progress of a voter roll upload. Processed counts the
+// data rows of the file that have been submitted, so a failed upload can be
+// resumed by sending the same file again with the same import ID.
+type VoterImport struct {
+	ImportID  string         `json:"importId"`
+	Status    string         `json:"status"`
+	Processed int            `json:"processed"`
+	Succeeded int            `json:"succeeded"`
+	Failed    int            `json:"failed"`
+	Error     string         `json:"error,omitempty"`
+	Failures  []*BatchResult `json:"failures,omitempty"`
+}
+
+// VoterImportHandler streams a CSV voter roll of "id,name" rows to the ledger
+// in chunks through RegisterVotersBatch. Progress is kept in Redis under the
+// import ID returned in the response; passing importid resumes an upload
+// after the rows that were already submitted.
+func (setup OrgSetup) VoterImportHandler(w http.ResponseWriter, r *http.Request) {
+	fmt.Println("Received Voter Import request")
+	queryParams := r.URL.Query()
+	chainCodeName := queryParams.Get("chaincodeid")
+	channelID := queryParams.Get("channelid")
+	importID := queryParams.Get("importid")
+	fmt.Printf("channel: %s, chaincode: %s, import: %s\n", channelID, chainCodeName, importID)
+
+	chunkSize := defaultImportChunkSize
+	if chunk := queryParams.Get("chunk"); chunk != "" {
+		size, err := strconv.Atoi(chunk)
+		if err != nil || size <= 0 || size > maxImportChunkSize {
+			http.Error(w, fmt.Sprintf("Error: chunk must be between 1 and %d", maxImportChunkSize), http.StatusBadRequest)
+			return
+		}
+		chunkSize = size
+	}
+
+	redisClient, ok := setup.RedisClient.(*redis.Client)
+	if !ok {
+		http.Error(w, "Error: import progress store unavailable", http.StatusInternalServerError)
+		return
+	}
+	ctx := context.Background()
+
+	if importID == "" {
+		id, err := newImportID()
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Error: %s", err), http.StatusInternalServerError)
+			return
+		}
+		importID = id
+	}
+
+	lock, err := acquireImportLock(ctx, redisClient, importID)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error: %s", err), http.StatusInternalServerError)
+		return
+	}
+	if lock == nil {
+		http.Error(w, fmt.Sprintf("Error: import %s is already running", importID), http.StatusConflict)
+		return
+	}
+	importCtx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	go lock.hold(importCtx, cancel)
+	defer lock.release(ctx)
+
+	progress, err := loadVoterImport(ctx, redisClient, importID)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error: %s", err), http.StatusInternalServerError)
+		return
+	}
+	if progress.Status == ImportStatusCompleted {
+		http.Error(w, fmt.Sprintf("Error: import %s is already completed", importID), http.StatusConflict)
+		return
+	}
+	progress.Status = ImportStatusRunning
+	progress.Error = ""
+	saveVoterImport(ctx, redisClient, progress)
+
+	err = setup.importVoters(importCtx, redisClient, chainCodeName, channelID, r.Body, chunkSize, progress)
+	if err != nil {
+		log.Printf("voter import %s stopped after %d rows: %v", importID, progress.Processed, err)
+		progress.Status = ImportStatusFailed
+		progress.Error = err.Error()
+	} else {
+		progress.Status = ImportStatusCompleted
+	}
+	saveVoterImport(ctx, redisClient, progress)
+
+	if progress.Status == ImportStatusFailed {
+		w.WriteHeader(http.StatusBadGateway)
+	}
+	response, _ := json.Marshal(progress)
+	fmt.Fprintf(w, "%s", response)
+}
+
+// VoterImportStatusHandler returns the progress of a voter roll upload
+// together with the records the ledger rejected.
+func (setup OrgSetup) VoterImportStatusHandler(w http.ResponseWriter, r *http.Request) {
+	importID := r.URL.Query().Get("importid")
+	if importID == "" {
+		http.Error(w, "Error: missing import id", http.StatusBadRequest)
+		return
+	}
+
+	redisClient, ok := setup.RedisClient.(*redis.Client)
+	if !ok {
+		http.Error(w, "Error: import progress store unavailable", http.StatusInternalServerError)
+		return
+	}
+	ctx := context.Background()
+
+	progress, err := loadVoterImport(ctx, redisClient, importID)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error: %s", err), http.StatusInternalServerError)
+		return
+	}
+	if progress.Status == "" {
+		http.Error(w, fmt.Sprintf("Error: import %s does not exist", importID), http.StatusNotFound)
+		return
+	}
+
+	failures, err := redisClient.LRange(ctx, importKey(importID)+":failures", 0, -1).Result()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error: %s", err), http.StatusInternalServerError)
+		return
+	}
+	for _, failure := range failures {
+		var result BatchResult
+		if err := json.Unmarshal([]byte(failure), &result); err == nil {
+			progress.Failures = append(progress.Failures, &result)
+		}
+	}
+
+	response, _ := json.Marshal(progress)
+	fmt.Fprintf(w, "%s", response)
+}
+
+// importVoters reads the CSV body row by row and submits a batch every
+// chunkSize rows, skipping the rows an earlier run already submitted.
+func (setup OrgSetup) importVoters(ctx context.Context, redisClient *redis.Client, chainCodeName, channelID string, body io.Reader, chunkSize int, progress *VoterImport) error {
+	contract := setup.Gateway.GetNetwork(channelID).GetContract(chainCodeName)
+
+	reader := csv.NewReader(body)
+	// The precinct column is optional.
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	submit := func(chunk []*VoterRecord) error {
+		if ctx.Err() != nil {
+			return context.Cause(ctx)
+		}
+		chunkJSON, err := json.Marshal(chunk)
+		if err != nil {
+			return err
+		}
+		resultJSON, err := contract.SubmitTransaction("RegisterVotersBatch", string(chunkJSON))
+		if err != nil {
+			return fmt.Errorf("error submitting batch: %s", err)
+		}
+		var results []*BatchResult
+		if err := json.Unmarshal(resultJSON, &results); err != nil {
+			return fmt.Errorf("invalid batch response: %s", err)
+		}
+
+		records := make(map[string]*VoterRecord, len(chunk))
+		for _, record := range chunk {
+			records[record.ID] = record
+		}
+		for _, result := range results {
+			if result.Success {
+				record := records[result.ID]
+				user := &models.User{Idnp: result.ID, Name: record.Name, Precinct: record.PrecinctID, Role: "user", Registered: true}
+				if err := setup.UserRepo.AddUser(user); err != nil {
+					result.Success = false
+					result.Error = fmt.Sprintf("registered on the ledger but not in the local database: %s", err)
+				}
+			}
+			if result.Success {
+				progress.Succeeded++
+				continue
+			}
+			progress.Failed++
+			failureJSON, _ := json.Marshal(result)
+			redisClient.RPush(ctx, importKey(progress.ImportID)+":failures", failureJSON)
+		}
+
+		progress.Processed += len(chunk)
+		saveVoterImport(ctx, redisClient, progress)
+		return nil
+	}
+
+	row := 0
+	chunk := make([]*VoterRecord, 0, chunkSize)
+	for {
+		fields, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("invalid voter file: %s", err)
+		}
+		if len(fields) != 2 && len(fields) != 3 {
+			return fmt.Errorf("invalid voter file: expected id,name[,precinct] but got %d fields", len(fields))
+		}
+		if row == 0 && strings.EqualFold(fields[0], "id") && strings.EqualFold(fields[1], "name") {
+			continue
+		}
+		row++
+		if row <= progress.Processed {
+			continue
+		}
+
+		record := &VoterRecord{ID: fields[0], Name: fields[1]}
+		if len(fields) == 3 {
+			record.PrecinctID = fields[2]
+		}
+		chunk = append(chunk, record)
+		if len(chunk) == chunkSize {
+			if err := submit(chunk); err != nil {
+				return err
+			}
+			chunk = chunk[:0]
+		}
+	}
+	if len(chunk) > 0 {
+		return submit(chunk)
+	}
+	return nil
+}
+
+// importLock keeps two uploads of the same import from running at once. The
+// token tells this upload's lock apart from one taken after it expired.
+type importLock struct {
+	client *redis.Client
+	key    string
+	token  string
+}
+
+var (
+	refreshImportLock = redis.NewScript(`if redis.call("get", KEYS[1]) == ARGV[1] then return redis.call("pexpire", KEYS[1], ARGV[2]) end return 0`)
+	releaseImportLock = redis.NewScript(`if redis.call("get", KEYS[1]) == ARGV[1] then return redis.call("del", KEYS[1]) end return 0`)
+)
+
+// acquireImportLock locks the import, or returns nil if another upload holds
+// the lock.
+func acquireImportLock(ctx context.Context, redisClient *redis.Client, importID string) (*importLock, error) {
+	token, err := newImportID()
+	if err != nil {
+		return nil, err
+	}
+	lock := &importLock{client: redisClient, key: importKey(importID) + ":lock", token: token}
+	locked, err := redisClient.SetNX(ctx, lock.key, token, importLockTTL).Result()
+	if err != nil {
+		return nil, err
+	}
+	if !locked {
+		return nil, nil
+	}
+	return lock, nil
+}
+
+// hold extends the lock every third of importLockTTL until ctx is done. If
+// the lock is lost, the upload is cancelled before it submits another chunk.
+func (lock *importLock) hold(ctx context.Context, cancel context.CancelCauseFunc) {
+	ticker := time.NewTicker(importLockTTL / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			held, err := refreshImportLock.Run(ctx, lock.client, []string{lock.key}, lock.token, importLockTTL.Milliseconds()).Int()
+			if err != nil {
+				log.Printf("Failed to refresh lock %s: %v", lock.key, err)
+				continue
+			}
+			if held == 0 {
+				cancel(fmt.Errorf("lost the import lock %s", lock.key))
+				return
+			}
+		}
+	}
+}
+
+func (lock *importLock) release(ctx context.Context) {
+	if err := releaseImportLock.Run(ctx, lock.client, []string{lock.key}, lock.token).Err(); err != nil {
+		log.Printf("Failed to release lock %s: %v", lock.key, err)
+	}
+}
+
+func importKey(importID string) string {
+	return "voterimport:" + importID
+}
+
+func newImportID() (string, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", fmt.Errorf("failed to generate import id: %s", err)
+	}
+	return hex.EncodeToString(id), nil
+}
+
+func loadVoterImport(ctx context.Context, redisClient *redis.Client, importID string) (*VoterImport, error) {
+	progress := &VoterImport{ImportID: importID}
+	progressJSON, err := redisClient.Get(ctx, importKey(importID)).Result()
+	if err == redis.Nil {
+		return progress, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(progressJSON), progress); err != nil {
+		return nil, fmt.Errorf("invalid import progress: %s", err)
+	}
+	return progress, nil
+}
+
+func saveVoterImport(ctx context.Context, redisClient *redis.Client, progress *VoterImport) {
+	progressJSON, _ := json.Marshal(progress)
+	if err := redisClient.Set(ctx, importKey(progress.ImportID), progressJSON, 0).Err(); err != nil {
+		log.Printf("Failed to save progress of voter import %s: %v", progress.ImportID, err)
+	}
+}
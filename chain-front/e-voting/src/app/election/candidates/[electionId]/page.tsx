@@ -14,6 +14,7 @@ interface Candidate {
     CreatedAt: string;
     UpdatedAt: string;
     DeletedAt: string | null;
+    candidateId: string;
     firstName: string;
     lastName: string;
     age: number;
@@ -37,8 +38,10 @@ export default function CandidatesPage() {
     const { data, isLoading, error } = useQuery({
         queryKey: ['candidates', electionId],
         queryFn: async () => {
+            const voterIDNP = localStorage.getItem('voterIDNP') ?? '';
             const response = await axios.get<IGetCandidatesResponse>(
-                `http://localhost:3000/elections/candidates/${electionId}`
+                `http://localhost:3000/elections/candidates/${electionId}`,
+                { params: { voter: voterIDNP } }
             );
             return response.data;
         },
@@ -56,7 +59,7 @@ export default function CandidatesPage() {
         setErrorVoting('');
 
         try {
-            const url = `http://localhost:3000/invoke?channelid=mychannel&chaincodeid=basic&function=CastVote&args=${voterIDNP}&args=${candidate.age}${candidate.party}`
+            const url = `http://localhost:3000/invoke?channelid=mychannel&chaincodeid=basic&function=CastVote&args=${voterIDNP}&args=${candidate.candidateId}`
 
             await axios.post(url);
         } catch (error) {
@@ -12,7 +12,8 @@ import axios from "axios";
 export default function VoterRegistrationForm() {
     const [formData, setFormData] = useState({
         idnp: '',
-        fullName: ''
+        fullName: '',
+        precinct: ''
     })
 
     const [loading, setLoading] = useState(false);
@@ -55,7 +56,7 @@ export default function VoterRegistrationForm() {
         setError('');
 
         try {
-            const url = `http://localhost:3000/invoke?channelid=mychannel&chaincodeid=basic&function=RegisterVoter&args=${formData.idnp}&args=${formData.fullName}`;
+            const url = `http://localhost:3000/invoke?channelid=mychannel&chaincodeid=basic&function=RegisterVoter&args=${formData.idnp}&args=${formData.fullName}&args=${formData.precinct}`;
 
             const response = await axios.post(url);
 
@@ -63,7 +64,7 @@ export default function VoterRegistrationForm() {
             localStorage.setItem('voterFullName', formData.fullName);
             setSuccess(true);
             setTimeout(()=> router.push('/election'), 5000);
-            setFormData({ idnp: '', fullName: ''});
+            setFormData({ idnp: '', fullName: '', precinct: ''});
         } catch (err) {
             setError(err.message || 'Failed to register voter');
         } finally {
@@ -110,6 +111,19 @@ export default function VoterRegistrationForm() {
                         />
                     </div>
 
+                    <div className="flex flex-column gap-2">
+                        <label htmlFor="precinct" className="font-bold">
+                            Precinct
+                        </label>
+                        <InputText
+                            id="precinct"
+                            name="precinct"
+                            value={formData.precinct}
+                            onChange={handleChange}
+                            placeholder="Enter precinct code (optional)"
+                        />
+                    </div>
+
                     {error && (
                         <Message severity="error" text={error} className="w-full"/>
                     )}